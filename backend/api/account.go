@@ -1,13 +1,16 @@
 package api
 
 import (
-	"context"
+	"database/sql"
+	"fmt"
 	db "github/kasho/backend/db/sqlc"
 	"github/kasho/backend/utils"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/lib/pq"
 )
 
 type Account struct {
@@ -17,9 +20,23 @@ type Account struct {
 func (a Account) router(server *Server) {
 	a.server = server
 
-	serverGroup := server.router.Group("/account", AuthenticatedMiddleware())
-	serverGroup.POST("create", a.createAccount)
+	serverGroup := server.router.Group("/account", AuthenticatedMiddleware(server.queries))
 	serverGroup.GET("", a.getUserAccounts)
+	serverGroup.GET(":id/entries", a.listAccountEntries)
+	serverGroup.GET(":id/transactions", a.listAccountTransactions)
+	serverGroup.GET(":id/status-history", a.listAccountStatusChanges)
+
+	mutating := serverGroup.Group("", server.idempotency())
+	mutating.POST(":id/close", a.closeAccount)
+	mutating.POST(":id/reopen", a.reopenAccount)
+
+	verified := serverGroup.Group("", RequireVerifiedEmail(server.queries), server.idempotency())
+	verified.POST("create", a.createAccount)
+
+	adminGroup := server.admin.Group("/accounts")
+	adminGroup.GET("", RequirePermission(db.PermissionAccountsRead), a.adminListAccounts)
+	adminGroup.GET(":id", RequirePermission(db.PermissionAccountsRead), a.adminGetAccount)
+	adminGroup.PUT(":id/status", RequirePermission(db.PermissionAccountsStatus), a.setAccountStatus)
 }
 
 type AccountRequest struct {
@@ -29,47 +46,562 @@ type AccountRequest struct {
 func (a *Account) createAccount(c *gin.Context) {
 	userId, err := utils.GetActiveUser(c)
 	if err != nil {
+		abortWithError(c, err)
 		return
 	}
 
 	acc := new(AccountRequest)
 
 	if err := c.ShouldBindJSON(&acc); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 
 	arg := db.CreateAccountParams{
-		UserID: int32(userId),
+		UserID: accountOwner(userId),
 		Currency: acc.Currency,
 	}
 
-	account, err := a.server.queries.CreateAccount(context.Background(), arg)
+	var account db.Account
+
+	err = a.server.store.AuditTx(auditContext(c), func(q *db.Queries) (db.AuditRecord, error) {
+		var err error
+		account, err = q.CreateAccount(c.Request.Context(), arg)
+		return db.AuditRecord{
+			Action:     "account.create",
+			TargetType: "account",
+			TargetID:   strconv.FormatInt(account.ID, 10),
+			After:      account,
+		}, err
+	})
 	if err != nil {
-		if pgErr, ok := err.(*pq.Error); ok {
-			if pgErr.Code == "23505" {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Account already exists"})	
-				return	
-			}
+		if isUniqueViolation(err) {
+			abortWithError(c, newAPIError(http.StatusConflict, CodeAlreadyExists, "account already exists"))
+			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})	
+		abortWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, account)
+	a.server.metrics.AccountCreated(account.Currency)
+
+	c.JSON(http.StatusCreated, AccountResponse{}.toAccountResponse(&account))
+}
+
+type ListAccountsRequest struct {
+	PageRequest
+	DateRange
+	Currency string `form:"currency" binding:"omitempty,currency"`
+	// Balance bounds need a currency to be parsed in.
+	MinBalance string `form:"min_balance"`
+	MaxBalance string `form:"max_balance"`
 }
 
 func (a *Account) getUserAccounts(c *gin.Context) {
 	userId, err := utils.GetActiveUser(c)
 	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var req ListAccountsRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	balance := AmountRange{MinAmount: req.MinBalance, MaxAmount: req.MaxBalance}
+	if balance.isSet() && req.Currency == "" {
+		abortWithError(c, missingCurrency("min_balance and max_balance need a currency"))
+		return
+	}
+
+	minBalance, maxBalance, err := balance.bounds(req.Currency)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	beforeID, err := req.beforeID()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	accounts, err := a.server.queries.ListAccountsByUserID(c.Request.Context(), db.ListAccountsByUserIDParams{
+		UserID:      accountOwner(userId),
+		BeforeID:    beforeID,
+		Currency:    nullString(req.Currency),
+		CreatedFrom: req.from(),
+		CreatedTo:   req.to(),
+		MinBalance:  minBalance,
+		MaxBalance:  maxBalance,
+		PageLimit:   req.queryLimit(),
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPage(accounts, req.PageRequest, func(v *db.Account) int64 { return v.ID }, AccountResponse{}.toAccountResponse))
+}
+
+type AccountURI struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type ListEntriesRequest struct {
+	PageRequest
+	DateRange
+	AmountRange
+}
+
+// listAccountEntries pages through the ledger entries of one of the caller's
+// accounts. Amount bounds apply to the size of an entry, debit or credit.
+func (a *Account) listAccountEntries(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var uri AccountURI
+	var req ListEntriesRequest
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	account, ok := ownedAccount(c, a.server.queries, userId, uri.ID)
+	if !ok {
+		return
+	}
+
+	minAmount, maxAmount, err := req.bounds(account.Currency)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	beforeID, err := req.beforeID()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	entries, err := a.server.queries.ListEntriesByAccountID(c.Request.Context(), db.ListEntriesByAccountIDParams{
+		AccountID:   int32(account.ID),
+		BeforeID:    beforeID,
+		CreatedFrom: req.from(),
+		CreatedTo:   req.to(),
+		MinAmount:   minAmount,
+		MaxAmount:   maxAmount,
+		PageLimit:   req.queryLimit(),
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPage(entries, req.PageRequest, func(v *db.Entry) int64 { return v.ID }, func(v *db.Entry) *EntryResponse {
+		return EntryResponse{}.toEntryResponse(v, account.Currency)
+	}))
+}
+
+// listAccountTransactions is the statement view of an account: every money
+// movement newest first, with the balance after it and the other side of it.
+func (a *Account) listAccountTransactions(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var uri AccountURI
+	var req PageRequest
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	account, ok := ownedAccount(c, a.server.queries, userId, uri.ID)
+	if !ok {
+		return
+	}
+
+	beforeID, err := req.beforeID()
+	if err != nil {
+		abortWithError(c, err)
 		return
 	}
 
-	accounts, err := a.server.queries.GetAccountByUserID(context.Background(), int32(userId))
+	history, err := a.server.store.AccountHistory(c.Request.Context(), db.AccountHistoryParams{
+		AccountID: account.ID,
+		BeforeID:  beforeID,
+		Limit:     req.queryLimit(),
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, accounts)	
+	c.JSON(http.StatusOK, newPage(history, req, func(v *db.AccountTransaction) int64 { return v.ID }, func(v *db.AccountTransaction) *TransactionResponse {
+		return TransactionResponse{}.toTransactionResponse(v, account.Currency)
+	}))
+}
+
+type AccountStatusRequest struct {
+	Reason string `json:"reason" binding:"max=256"`
+}
+
+// closeAccount closes one of the caller's accounts. Its balance must be zero
+// and no deposit or withdrawal may be pending on it. The account and its
+// history are kept.
+func (a *Account) closeAccount(c *gin.Context) {
+	a.ownerStatusChange(c, db.AccountStatusClosed, "closed by owner")
+}
+
+// reopenAccount brings a closed or dormant account back to active. Frozen
+// accounts can only be unfrozen by an admin.
+func (a *Account) reopenAccount(c *gin.Context) {
+	a.ownerStatusChange(c, db.AccountStatusActive, "reopened by owner")
+}
+
+func (a *Account) ownerStatusChange(c *gin.Context, status string, defaultReason string) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var uri AccountURI
+	var req AccountStatusRequest
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	// The body is optional.
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortWithError(c, err)
+			return
+		}
+	}
+
+	account, ok := ownedAccount(c, a.server.queries, userId, uri.ID)
+	if !ok {
+		return
+	}
+
+	reason := req.Reason
+	if reason == "" {
+		reason = defaultReason
+	}
+
+	a.changeStatus(c, db.ChangeAccountStatusParams{
+		AccountID: account.ID,
+		Status:    status,
+		Reason:    reason,
+		ActorID:   sql.NullInt64{Int64: userId, Valid: true},
+		// Only an admin can unfreeze an account, or close it once frozen.
+		FromStatuses: []string{db.AccountStatusActive, db.AccountStatusDormant, db.AccountStatusClosed},
+	})
+}
+
+type AdminListAccountsRequest struct {
+	PageRequest
+	DateRange
+	UserID   int64  `form:"user_id" binding:"omitempty,min=1"`
+	Currency string `form:"currency" binding:"omitempty,len=3"`
+	Status   string `form:"status" binding:"omitempty,oneof=active frozen dormant closed"`
+	Kind     string `form:"kind"`
+}
+
+// adminListAccounts lists the accounts of every user, and the system
+// accounts with kind set.
+func (a *Account) adminListAccounts(c *gin.Context) {
+	var req AdminListAccountsRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	beforeID, err := req.beforeID()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var userID sql.NullInt32
+	if req.UserID != 0 {
+		userID = accountOwner(req.UserID)
+	}
+
+	accounts, err := a.server.queries.ListAccounts(c.Request.Context(), db.ListAccountsParams{
+		BeforeID:    beforeID,
+		UserID:      userID,
+		Currency:    nullString(strings.ToUpper(req.Currency)),
+		Status:      nullString(req.Status),
+		Kind:        nullString(req.Kind),
+		CreatedFrom: req.from(),
+		CreatedTo:   req.to(),
+		PageLimit:   req.queryLimit(),
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPage(accounts, req.PageRequest, func(v *db.Account) int64 { return v.ID }, AccountResponse{}.toAccountResponse))
+}
+
+func (a *Account) adminGetAccount(c *gin.Context) {
+	var uri AccountURI
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	account, err := a.server.queries.GetAccountByID(c.Request.Context(), uri.ID)
+	if err == sql.ErrNoRows {
+		abortWithError(c, notFound(fmt.Sprintf("account %d not found", uri.ID)))
+		return
+	}
+
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, AccountResponse{}.toAccountResponse(&account))
+}
+
+type AdminAccountStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=active frozen dormant closed"`
+	Reason string `json:"reason" binding:"required,max=256"`
+}
+
+// setAccountStatus lets staff move any customer account along the state
+// machine, e.g. to freeze it during an investigation.
+func (a *Account) setAccountStatus(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var uri AccountURI
+	var req AdminAccountStatusRequest
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	a.changeStatus(c, db.ChangeAccountStatusParams{
+		AccountID: uri.ID,
+		Status:    req.Status,
+		Reason:    req.Reason,
+		ActorID:   sql.NullInt64{Int64: userId, Valid: true},
+	})
+}
+
+func (a *Account) changeStatus(c *gin.Context, arg db.ChangeAccountStatusParams) {
+	account, err := a.server.store.ChangeAccountStatusTx(auditContext(c), arg)
+	if err != nil {
+		if isUniqueViolation(err) {
+			abortWithError(c, newAPIError(http.StatusConflict, CodeAlreadyExists, "an open account in this currency already exists"))
+			return
+		}
+		abortWithError(c, err)
+		return
+	}
+
+	requestLogger(c).Info("account status changed",
+		"account_id", account.ID,
+		"status", account.Status,
+		"actor_id", arg.ActorID.Int64,
+	)
+
+	c.JSON(http.StatusOK, AccountResponse{}.toAccountResponse(&account))
+}
+
+func (a *Account) listAccountStatusChanges(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var uri AccountURI
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	account, ok := ownedAccount(c, a.server.queries, userId, uri.ID)
+	if !ok {
+		return
+	}
+
+	changes, err := a.server.queries.ListAccountStatusChanges(c.Request.Context(), account.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	response := make([]*AccountStatusChangeResponse, 0, len(changes))
+	for i := range changes {
+		response = append(response, AccountStatusChangeResponse{}.toAccountStatusChangeResponse(&changes[i]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+type AccountStatusChangeResponse struct {
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (a AccountStatusChangeResponse) toAccountStatusChangeResponse(change *db.AccountStatusChange) *AccountStatusChangeResponse {
+	return &AccountStatusChangeResponse{
+		FromStatus: change.FromStatus,
+		ToStatus:   change.ToStatus,
+		Reason:     change.Reason,
+		CreatedAt:  change.CreatedAt,
+	}
+}
+
+// ownedAccount loads one of the caller's accounts. Accounts of other users
+// are reported as not found so ids cannot be probed. It writes the error
+// response itself and reports whether the caller may continue.
+func ownedAccount(c *gin.Context, queries *db.Queries, userId int64, accountID int64) (db.Account, bool) {
+	account, err := queries.GetAccountByID(c.Request.Context(), accountID)
+	if err == sql.ErrNoRows || (err == nil && account.UserID != accountOwner(userId)) {
+		abortWithError(c, notFound(fmt.Sprintf("account %d not found", accountID)))
+		return account, false
+	}
+
+	if err != nil {
+		abortWithError(c, err)
+		return account, false
+	}
+
+	return account, true
+}
+
+// accountOwner wraps a user id for the nullable accounts.user_id column;
+// only system accounts have no owner.
+func accountOwner(userId int64) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(userId), Valid: true}
+}
+
+type AccountResponse struct {
+	ID           int64       `json:"id"`
+	UserID       int32       `json:"user_id"`
+	Balance      utils.Money `json:"balance"`
+	Currency     string      `json:"currency"`
+	Status       string      `json:"status"`
+	StatusReason string      `json:"status_reason,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"`
+	ClosedAt     *time.Time  `json:"closed_at,omitempty"`
+}
+
+func (a AccountResponse) toAccountResponse(account *db.Account) *AccountResponse {
+	response := &AccountResponse{
+		ID:           account.ID,
+		UserID:       account.UserID.Int32,
+		Balance:      utils.NewMoney(account.Balance, account.Currency),
+		Currency:     account.Currency,
+		Status:       account.Status,
+		StatusReason: account.StatusReason,
+		CreatedAt:    account.CreatedAt,
+	}
+
+	if account.ClosedAt.Valid {
+		response.ClosedAt = &account.ClosedAt.Time
+	}
+
+	return response
+}
+
+const (
+	DirectionIn  = "in"
+	DirectionOut = "out"
+)
+
+type CounterpartyResponse struct {
+	AccountID int32 `json:"account_id"`
+	// Kind tells customer accounts apart from system accounts such as equity.
+	Kind string `json:"kind"`
+}
+
+type TransactionResponse struct {
+	ID           int64                 `json:"id"`
+	JournalID    int64                 `json:"journal_id"`
+	TransferID   *int64                `json:"transfer_id,omitempty"`
+	Kind         string                `json:"kind"`
+	Direction    string                `json:"direction"`
+	Amount       utils.Money           `json:"amount"`
+	BalanceAfter utils.Money           `json:"balance_after"`
+	Counterparty *CounterpartyResponse `json:"counterparty,omitempty"`
+	Description  string                `json:"description"`
+	CreatedAt    time.Time             `json:"created_at"`
+}
+
+// Amounts are reported as positive values; Direction carries the sign.
+func (t TransactionResponse) toTransactionResponse(tx *db.AccountTransaction, currency string) *TransactionResponse {
+	response := &TransactionResponse{
+		ID:           tx.ID,
+		JournalID:    tx.JournalID,
+		Kind:         tx.Kind,
+		Direction:    DirectionIn,
+		Amount:       utils.NewMoney(tx.Amount, currency),
+		BalanceAfter: utils.NewMoney(tx.BalanceAfter, currency),
+		Description:  tx.Description,
+		CreatedAt:    tx.CreatedAt,
+	}
+
+	if tx.Amount < 0 {
+		response.Direction = DirectionOut
+		response.Amount = utils.NewMoney(-tx.Amount, currency)
+	}
+
+	if tx.TransferID.Valid {
+		response.TransferID = &tx.TransferID.Int64
+	}
+
+	if tx.CounterpartyAccountID.Valid {
+		response.Counterparty = &CounterpartyResponse{
+			AccountID: tx.CounterpartyAccountID.Int32,
+			Kind:      tx.CounterpartyKind.String,
+		}
+	}
+
+	return response
 }
\ No newline at end of file
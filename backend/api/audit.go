@@ -0,0 +1,103 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	db "github/kasho/backend/db/sqlc"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Audit struct {
+	server *Server
+}
+
+func (a Audit) router(server *Server) {
+	a.server = server
+
+	adminGroup := server.admin.Group("/audit", RequirePermission(db.PermissionAuditRead))
+	adminGroup.GET("", a.listAuditEvents)
+}
+
+type ListAuditEventsRequest struct {
+	PageRequest
+	DateRange
+	ActorID    int64  `form:"actor_id" binding:"omitempty,min=1"`
+	Action     string `form:"action"`
+	TargetType string `form:"target_type"`
+	TargetID   string `form:"target_id"`
+}
+
+func (a *Audit) listAuditEvents(c *gin.Context) {
+	var req ListAuditEventsRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	beforeID, err := req.beforeID()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	events, err := a.server.queries.ListAuditEvents(c.Request.Context(), db.ListAuditEventsParams{
+		BeforeID:    beforeID,
+		ActorID:     sql.NullInt64{Int64: req.ActorID, Valid: req.ActorID != 0},
+		Action:      nullString(req.Action),
+		TargetType:  nullString(req.TargetType),
+		TargetID:    nullString(req.TargetID),
+		CreatedFrom: req.from(),
+		CreatedTo:   req.to(),
+		PageLimit:   req.queryLimit(),
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPage(events, req.PageRequest, func(v *db.AuditEvent) int64 { return v.ID }, AuditEventResponse{}.toAuditEventResponse))
+}
+
+type AuditEventResponse struct {
+	ID         int64           `json:"id"`
+	ActorID    *int64          `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	IP         string          `json:"ip,omitempty"`
+	UserAgent  string          `json:"user_agent,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+func (ar AuditEventResponse) toAuditEventResponse(event *db.AuditEvent) *AuditEventResponse {
+	response := &AuditEventResponse{
+		ID:         event.ID,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		Before:     event.BeforeState,
+		After:      event.AfterState,
+		IP:         event.Ip,
+		UserAgent:  event.UserAgent,
+		RequestID:  event.RequestID,
+		CreatedAt:  event.CreatedAt,
+		PrevHash:   event.PrevHash,
+		Hash:       event.Hash,
+	}
+
+	// A missing actor is the system itself.
+	if event.ActorID.Valid {
+		response.ActorID = &event.ActorID.Int64
+	}
+
+	return response
+}
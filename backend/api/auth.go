@@ -3,12 +3,22 @@ package api
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"github/kasho/backend/audit"
 	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/mail"
+	"github/kasho/backend/metrics"
 	"github/kasho/backend/utils"
+	"log/slog"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/lib/pq"
 )
 
 type Auth struct {
@@ -20,7 +30,17 @@ func (a Auth) router(server *Server) {
 
 	serverGroup := server.router.Group("/auth")
 	serverGroup.POST("login", a.login)
+	serverGroup.POST("login/2fa", a.completeLogin)
 	serverGroup.POST("register", a.register)
+	serverGroup.POST("refresh", a.refresh)
+	serverGroup.POST("logout", AuthenticatedMiddleware(server.queries), a.logout)
+	serverGroup.POST("change-password", AuthenticatedMiddleware(server.queries), a.changePassword)
+	serverGroup.POST("forgot-password", a.forgotPassword)
+	serverGroup.POST("reset-password", a.resetPassword)
+	serverGroup.POST("verify-email", a.verifyEmail)
+	serverGroup.POST("verify-email/resend", AuthenticatedMiddleware(server.queries), a.resendVerificationEmail)
+
+	server.router.GET("/.well-known/jwks.json", a.jwks)
 }
 
 type UserParams struct {
@@ -33,34 +53,43 @@ func (a *Auth) register(c *gin.Context) {
 	var user UserParams
 
 	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		abortWithError(c, err)
+		return
+	}
+
+	hashedPassword, err := utils.GenerateHashPassword(c.Request.Context(), user.Password)
+	if err != nil {
+		abortWithError(c, err)
 		return
 	}
 
-	hashedPassword, err := utils.GenerateHashPassword(user.Password)
+	token, tokenHash, err := utils.NewOpaqueToken()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 
-	arg := db.CreateUserParams{
+	arg := db.RegisterUserParams{
 		Email: user.Email,
 		HashedPassword: hashedPassword,
+		VerificationTokenHash: tokenHash,
+		VerificationExpiresAt: time.Now().Add(a.server.config.Email_verification_ttl),
 	}
 
-	newUser, err := a.server.queries.CreateUser(context.Background(), arg)
+	newUser, err := a.server.store.RegisterUserTx(auditContext(c), arg)
 	if err != nil {
-		if pgErr, ok := err.(*pq.Error); ok {
-			switch pgErr.Code {
-			case "23505":
-				c.JSON(http.StatusBadRequest, gin.H{"error": "email already exists"})
-				return
-			}
+		if isUniqueViolation(err) {
+			abortWithError(c, newAPIError(http.StatusConflict, CodeAlreadyExists, "email already exists"))
+			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 
+	requestLogger(c).Info("user registered", "user_id", newUser.ID, "email", newUser.Email)
+	a.server.metrics.Registered()
+	a.sendVerificationEmail(c, newUser, token)
+
 	c.JSON(http.StatusCreated, UserResponse{}.toUserResponse(&newUser))
 }
 
@@ -68,33 +97,615 @@ func (a Auth) login(c *gin.Context) {
 	user := new(UserParams)
 
 	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		abortWithError(c, err)
+		return
+	}
+
+	if !a.loginAllowed(c, user.Email) {
 		return
 	}
 
-	dbUser, err := a.server.queries.GetUserByEmail(context.Background(), user.Email)
+	dbUser, err := a.server.queries.GetUserByEmail(c.Request.Context(), user.Email)
 
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email or password"})
+		utils.VerifyNoPassword(c.Request.Context(), user.Password)
+		requestLogger(c).Warn("login failed", "reason", "unknown email", "email", user.Email)
+		a.loginFailed(c, user.Email, nil)
+		abortWithError(c, errInvalidCredentials)
 		return
 	}
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
+		return
+	}
+
+	if err := utils.VerifyPassword(c.Request.Context(), user.Password, dbUser.HashedPassword); err != nil {
+		requestLogger(c).Warn("login failed", "reason", "wrong password", "user_id", dbUser.ID)
+		a.loginFailed(c, user.Email, &dbUser)
+		abortWithError(c, errInvalidCredentials)
+		return
+	}
+
+	if err := a.server.emailThrottle.Reset(c.Request.Context(), loginEmailKey(user.Email)); err != nil {
+		requestLogger(c).Error("failed to reset login throttle", "user_id", dbUser.ID, "error", err)
+	}
+
+	twoFactor, err := a.server.twoFactorEnabled(c.Request.Context(), dbUser.ID)
+	if err != nil {
+		abortWithError(c, err)
 		return
 	}
 
-	if err := utils.VerifyPassword(user.Password, dbUser.HashedPassword); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email or password"})
+	if twoFactor {
+		a.challengeLogin(c, dbUser)
 		return
 	}
 
-	token, err := tokenController.CreateToken(dbUser.ID)
+	refreshToken, refreshHash, err := utils.NewRefreshToken()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	session, err := a.server.store.CreateSessionTx(audit.WithUser(auditContext(c), dbUser.ID), db.CreateSessionTxParams{
+		UserID:           dbUser.ID,
+		RefreshTokenHash: refreshHash,
+		ExpiresAt:        time.Now().Add(a.server.config.Refresh_token_ttl),
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	token, err := a.accessToken(c.Request.Context(), dbUser.ID, session.Session.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	requestLogger(c).Info("user logged in", "user_id", dbUser.ID, "session_id", session.Session.ID)
+	a.server.metrics.Login(metrics.LoginSuccess)
+
+	c.JSON(http.StatusOK, gin.H{"token": token, "refresh_token": refreshToken})
 }
 
+// Failed logins are counted per email, whether or not it belongs to a user,
+// and per client IP.
+func loginEmailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func loginIPKey(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// loginAllowed refuses a login attempt made before the backoff from earlier
+// failures has passed. The answer is the same for registered and unknown
+// emails.
+func (a *Auth) loginAllowed(c *gin.Context, email string) bool {
+	emailWait, err := a.server.emailThrottle.Wait(c.Request.Context(), loginEmailKey(email))
+	if err != nil {
+		abortWithError(c, err)
+		return false
+	}
+
+	ipWait, err := a.server.ipThrottle.Wait(c.Request.Context(), loginIPKey(c))
+	if err != nil {
+		abortWithError(c, err)
+		return false
+	}
+
+	wait := max(emailWait, ipWait)
+	if wait == 0 {
+		return true
+	}
+
+	requestLogger(c).Warn("login throttled", "email", email, "retry_after", wait)
+	a.server.metrics.Login(metrics.LoginThrottled)
+
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	abortWithError(c, newAPIError(http.StatusTooManyRequests, CodeRateLimited, "too many failed login attempts, try again later"))
+	return false
+}
+
+// loginFailed counts a failed login. When it locks out a registered email,
+// the lockout is audited and the user told, so they notice someone
+// guessing their password.
+func (a *Auth) loginFailed(c *gin.Context, email string, user *db.User) {
+	a.server.metrics.Login(metrics.LoginFailure)
+
+	// Counted even if the client hangs up, or an attacker could dodge the
+	// throttle by dropping the connection as soon as the password fails.
+	ctx := context.WithoutCancel(c.Request.Context())
+
+	if until, err := a.server.ipThrottle.Fail(ctx, loginIPKey(c)); err != nil {
+		requestLogger(c).Error("failed to record failed login", "error", err)
+	} else if !until.IsZero() {
+		requestLogger(c).Warn("login locked out", "client_ip", c.ClientIP(), "until", until)
+	}
+
+	until, err := a.server.emailThrottle.Fail(ctx, loginEmailKey(email))
+	if err != nil {
+		requestLogger(c).Error("failed to record failed login", "error", err)
+		return
+	}
+
+	if until.IsZero() {
+		return
+	}
+
+	requestLogger(c).Warn("login locked out", "email", email, "until", until)
+
+	if user == nil {
+		return
+	}
+
+	// Done in the background, so that locking out a registered email takes
+	// no longer to answer than locking out an unknown one.
+	ctx = audit.WithActor(context.Background(), audit.ActorFrom(auditContext(c)))
+	logger := requestLogger(c)
+	a.server.background(func() { a.notifyLoginLocked(ctx, logger, *user, until) })
+}
+
+func (a *Auth) notifyLoginLocked(ctx context.Context, logger *slog.Logger, user db.User, until time.Time) {
+	err := a.server.store.AuditTx(ctx, func(q *db.Queries) (db.AuditRecord, error) {
+		return db.AuditRecord{
+			Action:     "user.login_lockout",
+			TargetType: "user",
+			TargetID:   strconv.FormatInt(user.ID, 10),
+			After: struct {
+				LockedUntil time.Time `json:"locked_until"`
+			}{until},
+		}, nil
+	})
+	if err != nil {
+		logger.Error("failed to audit login lockout", "user_id", user.ID, "error", err)
+	}
+
+	msg := mail.LoginLocked(a.server.config.Mail_from, user.Email, a.server.config.Login_lockout_duration)
+	if err := a.server.mailer.Send(ctx, msg); err != nil {
+		logger.Error("failed to send login lockout email", "user_id", user.ID, "error", err)
+	}
+}
+
+// challengeLogin answers a correct password of a user with two-factor
+// authentication with a challenge instead of tokens. The challenge only
+// proves the password and is exchanged at login/2fa together with a code.
+func (a *Auth) challengeLogin(c *gin.Context, user db.User) {
+	challenge, challengeHash, err := utils.NewOpaqueToken()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	expiresAt := time.Now().Add(a.server.config.Login_challenge_ttl)
+
+	_, err = a.server.queries.CreateLoginChallenge(c.Request.Context(), db.CreateLoginChallengeParams{
+		UserID:    user.ID,
+		TokenHash: challengeHash,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	requestLogger(c).Info("login challenged", "user_id", user.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"two_factor_required": true,
+		"challenge_token":     challenge,
+		"expires_at":          expiresAt,
+	})
+}
+
+type CompleteLoginRequest struct {
+	SecondFactorRequest
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+}
+
+// completeLogin finishes a two-factor login with the challenge from login
+// and a code from the authenticator app or a recovery code.
+func (a *Auth) completeLogin(c *gin.Context) {
+	var req CompleteLoginRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	refreshToken, refreshHash, err := utils.NewRefreshToken()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	session, err := a.server.store.CompleteLoginTx(auditContext(c), db.CompleteLoginParams{
+		ChallengeHash:    utils.HashOpaqueToken(req.ChallengeToken),
+		Factor:           a.server.secondFactor(c, req.SecondFactorRequest),
+		RefreshTokenHash: refreshHash,
+		ExpiresAt:        time.Now().Add(a.server.config.Refresh_token_ttl),
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrSecondFactorInvalid) {
+			requestLogger(c).Warn("login failed", "reason", "wrong two-factor code")
+			a.server.metrics.Login(metrics.LoginFailure)
+		}
+		abortWithError(c, err)
+		return
+	}
+
+	token, err := a.accessToken(c.Request.Context(), session.Session.UserID, session.Session.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	requestLogger(c).Info("user logged in", "user_id", session.Session.UserID, "session_id", session.Session.ID, "two_factor", true)
+	a.server.metrics.Login(metrics.LoginSuccess)
+
+	c.JSON(http.StatusOK, gin.H{"token": token, "refresh_token": refreshToken})
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+func (a *Auth) refresh(c *gin.Context) {
+	var req RefreshRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	refreshToken, refreshHash, err := utils.NewRefreshToken()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	session, err := a.server.store.RotateRefreshTokenTx(auditContext(c), db.RotateRefreshTokenTxParams{
+		TokenHash:    utils.HashRefreshToken(req.RefreshToken),
+		NewTokenHash: refreshHash,
+		ExpiresAt:    time.Now().Add(a.server.config.Refresh_token_ttl),
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRefreshTokenReused) {
+			requestLogger(c).Warn("refresh token reused, session revoked")
+		}
+		abortWithError(c, err)
+		return
+	}
+
+	token, err := a.accessToken(c.Request.Context(), session.Session.UserID, session.Session.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token, "refresh_token": refreshToken})
+}
+
+// accessToken issues an access token carrying the roles and permissions the
+// user holds now, so role changes take effect at the next refresh.
+func (a *Auth) accessToken(ctx context.Context, userID int64, sessionID int64) (string, error) {
+	access, err := a.server.store.UserAccess(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	return tokenController.CreateToken(utils.TokenPayload{
+		UserID:      userID,
+		SessionID:   sessionID,
+		Roles:       access.Roles,
+		Permissions: access.Permissions,
+	})
+}
+
+// logout revokes the session of the access token used to call it, which
+// invalidates that access token and every refresh token of the session.
+func (a *Auth) logout(c *gin.Context) {
+	sessionId := c.GetInt64("session_id")
+
+	err := a.server.store.AuditTx(auditContext(c), func(q *db.Queries) (db.AuditRecord, error) {
+		return db.AuditRecord{
+			Action:     "session.revoke",
+			TargetType: "session",
+			TargetID:   strconv.FormatInt(sessionId, 10),
+			After:      gin.H{"reason": "logout"},
+		}, q.RevokeSession(c.Request.Context(), sessionId)
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+// changePassword sets a new password after checking the current one. Every
+// session of the user is revoked, this one included, so they log in again.
+func (a *Auth) changePassword(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var req ChangePasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	user, err := a.server.queries.GetUserByID(c.Request.Context(), userId)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if err := utils.VerifyPassword(c.Request.Context(), req.CurrentPassword, user.HashedPassword); err != nil {
+		requestLogger(c).Warn("password change failed", "reason", "wrong password", "user_id", userId)
+		abortWithError(c, newAPIError(http.StatusForbidden, CodeInvalidCredentials, "current password is incorrect"))
+		return
+	}
+
+	hashedPassword, err := utils.GenerateHashPassword(c.Request.Context(), req.NewPassword)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	user, err = a.server.store.ChangePasswordTx(auditContext(c), db.ChangePasswordParams{
+		UserID:         userId,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	requestLogger(c).Info("password changed", "user_id", userId)
+	a.notifyPasswordChanged(c, user)
+
+	c.Status(http.StatusNoContent)
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// maxPasswordResetsPerHour bounds how many reset emails one user can be
+// sent, so the endpoint can't be used to flood an inbox.
+const maxPasswordResetsPerHour = 3
+
+// forgotPassword emails a reset link. It answers the same way whether or
+// not the email belongs to a user, and does the work after responding, so
+// neither the answer nor its timing tells which emails are registered.
+func (a *Auth) forgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	ctx := audit.WithActor(context.Background(), audit.ActorFrom(auditContext(c)))
+	logger := requestLogger(c)
+
+	a.server.background(func() { a.sendPasswordReset(ctx, logger, req.Email) })
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the email is registered, a reset link has been sent to it"})
+}
+
+func (a *Auth) sendPasswordReset(ctx context.Context, logger *slog.Logger, email string) {
+	user, err := a.server.queries.GetUserByEmail(ctx, email)
+	if err == sql.ErrNoRows {
+		logger.Info("password reset requested", "outcome", "unknown email", "email", email)
+		return
+	}
+	if err != nil {
+		logger.Error("password reset failed", "error", err)
+		return
+	}
+
+	recent, err := a.server.queries.CountRecentPasswordResetTokens(ctx, db.CountRecentPasswordResetTokensParams{
+		UserID:    user.ID,
+		CreatedAt: time.Now().Add(-time.Hour),
+	})
+	if err != nil {
+		logger.Error("password reset failed", "user_id", user.ID, "error", err)
+		return
+	}
+	if recent >= maxPasswordResetsPerHour {
+		logger.Warn("password reset requested", "outcome", "rate limited", "user_id", user.ID)
+		return
+	}
+
+	token, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		logger.Error("password reset failed", "user_id", user.ID, "error", err)
+		return
+	}
+
+	ttl := a.server.config.Password_reset_ttl
+
+	_, err = a.server.store.RequestPasswordResetTx(audit.WithUser(ctx, user.ID), db.RequestPasswordResetParams{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		logger.Error("password reset failed", "user_id", user.ID, "error", err)
+		return
+	}
+
+	link := a.appLink("/reset-password", token)
+
+	if err := a.server.mailer.Send(ctx, mail.PasswordReset(a.server.config.Mail_from, user.Email, link, ttl)); err != nil {
+		logger.Error("failed to send password reset email", "user_id", user.ID, "error", err)
+		return
+	}
+
+	logger.Info("password reset requested", "outcome", "email sent", "user_id", user.ID)
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// resetPassword sets a new password with the token from a reset email.
+func (a *Auth) resetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	hashedPassword, err := utils.GenerateHashPassword(c.Request.Context(), req.NewPassword)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	user, err := a.server.store.ResetPasswordTx(auditContext(c), db.ResetPasswordParams{
+		TokenHash:      utils.HashOpaqueToken(req.Token),
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	requestLogger(c).Info("password reset", "user_id", user.ID)
+	a.notifyPasswordChanged(c, user)
+
+	c.Status(http.StatusNoContent)
+}
+
+// notifyPasswordChanged emails the user about a password change. The change
+// is already committed, so a failure is only logged.
+func (a *Auth) notifyPasswordChanged(c *gin.Context, user db.User) {
+	msg := mail.PasswordChanged(a.server.config.Mail_from, user.Email)
+
+	if err := a.server.mailer.Send(c.Request.Context(), msg); err != nil {
+		requestLogger(c).Error("failed to send password changed email", "user_id", user.ID, "error", err)
+	}
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// verifyEmail marks the user's email as verified with the token from a
+// verification email. It needs no session, so the link works on any device.
+func (a *Auth) verifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	user, err := a.server.store.VerifyEmailTx(auditContext(c), utils.HashOpaqueToken(req.Token))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	requestLogger(c).Info("email verified", "user_id", user.ID)
+
+	c.JSON(http.StatusOK, UserResponse{}.toUserResponse(&user))
+}
+
+// maxVerificationEmailsPerHour bounds how often a user can ask for another
+// verification email.
+const maxVerificationEmailsPerHour = 3
+
+// resendVerificationEmail sends the logged in user a new verification link.
+func (a *Auth) resendVerificationEmail(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	user, err := a.server.queries.GetUserByID(c.Request.Context(), userId)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if user.EmailVerifiedAt.Valid {
+		abortWithError(c, db.ErrEmailAlreadyVerified)
+		return
+	}
+
+	recent, err := a.server.queries.CountRecentEmailVerificationTokens(c.Request.Context(), db.CountRecentEmailVerificationTokensParams{
+		UserID:    userId,
+		CreatedAt: time.Now().Add(-time.Hour),
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if recent >= maxVerificationEmailsPerHour {
+		c.Header("Retry-After", strconv.Itoa(int(time.Hour.Seconds())))
+		abortWithError(c, newAPIError(http.StatusTooManyRequests, CodeRateLimited, "too many verification emails requested, try again later"))
+		return
+	}
+
+	token, tokenHash, err := utils.NewOpaqueToken()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	_, err = a.server.store.RequestEmailVerificationTx(auditContext(c), db.RequestEmailVerificationParams{
+		UserID:    userId,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(a.server.config.Email_verification_ttl),
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	a.sendVerificationEmail(c, user, token)
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "a verification link has been sent to your email"})
+}
+
+// sendVerificationEmail emails the user a link with token. The token is
+// already stored, so a failure is only logged and the user can resend.
+func (a *Auth) sendVerificationEmail(c *gin.Context, user db.User, token string) {
+	link := a.appLink("/verify-email", token)
+	msg := mail.EmailVerification(a.server.config.Mail_from, user.Email, link, a.server.config.Email_verification_ttl)
+
+	if err := a.server.mailer.Send(c.Request.Context(), msg); err != nil {
+		requestLogger(c).Error("failed to send verification email", "user_id", user.ID, "error", err)
+	}
+}
+
+// appLink is the frontend URL at path that hands token to the app.
+func (a *Auth) appLink(path string, token string) string {
+	return fmt.Sprintf("%s%s?token=%s", strings.TrimRight(a.server.config.App_url, "/"), path, url.QueryEscape(token))
+}
+
+// jwks publishes the public keys access tokens are signed with so that other
+// services can verify them without sharing a secret.
+func (a *Auth) jwks(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, tokenController.JWKS())
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Currency struct {
+	server *Server
+}
+
+func (cu Currency) router(server *Server) {
+	cu.server = server
+
+	server.router.GET("/currencies", cu.listEnabledCurrencies)
+
+	adminGroup := server.admin.Group("/currencies", RequirePermission(db.PermissionCurrenciesManage))
+	adminGroup.GET("", cu.listCurrencies)
+	adminGroup.POST(":code/enable", cu.enableCurrency)
+	adminGroup.POST(":code/disable", cu.disableCurrency)
+	adminGroup.PUT(":code/limits", cu.setCurrencyLimits)
+}
+
+// listEnabledCurrencies is public: clients use it to know which currencies
+// they can open accounts in and how to display them.
+func (cu *Currency) listEnabledCurrencies(c *gin.Context) {
+	currencies := []*CurrencyResponse{}
+
+	for _, currency := range utils.Currencies.List() {
+		if currency.Enabled {
+			currencies = append(currencies, CurrencyResponse{}.toCurrencyResponse(currency))
+		}
+	}
+
+	c.JSON(http.StatusOK, currencies)
+}
+
+func (cu *Currency) listCurrencies(c *gin.Context) {
+	rows, err := cu.server.store.LoadCurrencies(c.Request.Context())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	currencies := make([]*CurrencyResponse, 0, len(rows))
+	for _, currency := range rows {
+		currencies = append(currencies, CurrencyResponse{}.toCurrencyResponse(currency))
+	}
+
+	c.JSON(http.StatusOK, currencies)
+}
+
+type CurrencyURI struct {
+	Code string `uri:"code" binding:"required,len=3"`
+}
+
+func (cu *Currency) enableCurrency(c *gin.Context) {
+	cu.setCurrencyEnabled(c, true)
+}
+
+func (cu *Currency) disableCurrency(c *gin.Context) {
+	cu.setCurrencyEnabled(c, false)
+}
+
+// setCurrencyEnabled switches a currency on or off. Disabling a currency
+// stops new accounts and movements in it; existing balances are untouched.
+func (cu *Currency) setCurrencyEnabled(c *gin.Context, enabled bool) {
+	var uri CurrencyURI
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	code := strings.ToUpper(uri.Code)
+
+	currency, err := cu.update(c, code, func(q *db.Queries) (db.Currency, error) {
+		return q.SetCurrencyEnabled(c.Request.Context(), db.SetCurrencyEnabledParams{
+			Enabled: enabled,
+			Code:    code,
+		})
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	cu.updated(c, currency)
+}
+
+type CurrencyLimitsRequest struct {
+	// Decimal strings in the currency. Empty removes the bound.
+	MinAmount string `json:"min_amount"`
+	MaxAmount string `json:"max_amount"`
+	// Movements of at least this much need a recent two-factor check.
+	StepUpAmount string `json:"step_up_amount"`
+}
+
+func (cu *Currency) setCurrencyLimits(c *gin.Context) {
+	var uri CurrencyURI
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var req CurrencyLimitsRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	code := strings.ToUpper(uri.Code)
+
+	minAmount, maxAmount, err := AmountRange{MinAmount: req.MinAmount, MaxAmount: req.MaxAmount}.bounds(code)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	stepUpAmount, err := parseAmountBound(req.StepUpAmount, code)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if minAmount.Int64 < 0 || maxAmount.Int64 < 0 || stepUpAmount.Int64 < 0 {
+		abortWithError(c, newAPIError(http.StatusBadRequest, CodeInvalidAmount, "limits must not be negative"))
+		return
+	}
+
+	currency, err := cu.update(c, code, func(q *db.Queries) (db.Currency, error) {
+		return q.SetCurrencyLimits(c.Request.Context(), db.SetCurrencyLimitsParams{
+			MinAmount:    utils.Amount(minAmount.Int64),
+			MaxAmount:    utils.Amount(maxAmount.Int64),
+			StepUpAmount: utils.Amount(stepUpAmount.Int64),
+			Code:         code,
+		})
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	cu.updated(c, currency)
+}
+
+// update runs an admin change to a currency and records it in the audit log
+// with the currency as it was before.
+func (cu *Currency) update(c *gin.Context, code string, change func(q *db.Queries) (db.Currency, error)) (db.Currency, error) {
+	var currency db.Currency
+
+	err := cu.server.store.AuditTx(auditContext(c), func(q *db.Queries) (db.AuditRecord, error) {
+		before, err := q.GetCurrency(c.Request.Context(), code)
+		if err != nil {
+			return db.AuditRecord{}, err
+		}
+
+		currency, err = change(q)
+		return db.AuditRecord{
+			Action:     "currency.update",
+			TargetType: "currency",
+			TargetID:   code,
+			Before:     before,
+			After:      currency,
+		}, err
+	})
+
+	return currency, err
+}
+
+// updated applies an admin change to this instance's registry straight
+// away; other instances pick it up on their next refresh.
+func (cu *Currency) updated(c *gin.Context, currency db.Currency) {
+	utils.Currencies.Set(currency.Registry())
+
+	requestLogger(c).Info("currency updated",
+		"currency", currency.Code,
+		"enabled", currency.Enabled,
+		"min_amount", int64(currency.MinAmount),
+		"max_amount", int64(currency.MaxAmount),
+		"step_up_amount", int64(currency.StepUpAmount),
+	)
+
+	c.JSON(http.StatusOK, CurrencyResponse{}.toCurrencyResponse(currency.Registry()))
+}
+
+type CurrencyResponse struct {
+	Code      string       `json:"code"`
+	Numeric   string       `json:"numeric_code"`
+	Name      string       `json:"name"`
+	Exponent  int          `json:"exponent"`
+	Symbol    string       `json:"symbol"`
+	Enabled   bool         `json:"enabled"`
+	MinAmount *utils.Money `json:"min_amount,omitempty"`
+	MaxAmount *utils.Money `json:"max_amount,omitempty"`
+	// Clients can ask for a step-up before sending this much.
+	StepUpAmount *utils.Money `json:"step_up_amount,omitempty"`
+}
+
+func (cr CurrencyResponse) toCurrencyResponse(currency utils.Currency) *CurrencyResponse {
+	response := &CurrencyResponse{
+		Code:     currency.Code,
+		Numeric:  currency.Numeric,
+		Name:     currency.Name,
+		Exponent: currency.Exponent,
+		Symbol:   currency.Symbol,
+		Enabled:  currency.Enabled,
+	}
+
+	if currency.MinAmount > 0 {
+		minAmount := utils.NewMoney(currency.MinAmount, currency.Code)
+		response.MinAmount = &minAmount
+	}
+
+	if currency.MaxAmount > 0 {
+		maxAmount := utils.NewMoney(currency.MaxAmount, currency.Code)
+		response.MaxAmount = &maxAmount
+	}
+
+	if currency.StepUpAmount > 0 {
+		stepUpAmount := utils.NewMoney(currency.StepUpAmount, currency.Code)
+		response.StepUpAmount = &stepUpAmount
+	}
+
+	return response
+}
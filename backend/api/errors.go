@@ -0,0 +1,390 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/fx"
+	"github/kasho/backend/utils"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/lib/pq"
+)
+
+// statusClientClosedRequest is nginx's status for a request whose client
+// left before the response. Nobody reads it; it keeps such requests out of
+// the 5xx counts.
+const statusClientClosedRequest = 499
+
+// Error codes are part of the API contract: clients switch on them, so they
+// never change once published. Messages are for humans and may change.
+const (
+	CodeValidationFailed    = "validation_failed"
+	CodeInvalidBody         = "invalid_body"
+	CodeInvalidAmount       = "invalid_amount"
+	CodeInvalidCursor       = "invalid_cursor"
+	CodeUnauthorized        = "unauthorized"
+	CodeInvalidCredentials  = "invalid_credentials"
+	CodeInvalidToken        = "invalid_token"
+	CodeSessionRevoked      = "session_revoked"
+	CodeResetTokenInvalid   = "reset_token_invalid"
+	CodeVerificationInvalid = "verification_token_invalid"
+	CodeChallengeInvalid    = "login_challenge_invalid"
+	CodeInvalidTwoFactor    = "invalid_two_factor_code"
+	CodeTwoFactorEnabled    = "two_factor_enabled"
+	CodeTwoFactorDisabled   = "two_factor_not_enabled"
+	CodeStepUpRequired      = "step_up_required"
+	CodeTwoFactorRequired   = "two_factor_required"
+	CodeForbidden           = "forbidden"
+	CodeEmailNotVerified    = "email_not_verified"
+	CodeEmailVerified       = "email_already_verified"
+	CodeRateLimited         = "rate_limited"
+	CodeNotFound            = "not_found"
+	CodeMethodNotAllowed    = "method_not_allowed"
+	CodeAlreadyExists       = "already_exists"
+	CodeInvalidReference    = "invalid_reference"
+	CodeConstraintViolated  = "constraint_violated"
+	CodeRetryable           = "retryable_conflict"
+	CodeInsufficientFunds   = "insufficient_funds"
+	CodeCurrencyMismatch    = "currency_mismatch"
+	CodeCurrencyDisabled    = "currency_disabled"
+	CodeAmountLimit         = "amount_limit_exceeded"
+	CodeAccountFrozen       = "account_frozen"
+	CodeAccountDormant      = "account_dormant"
+	CodeAccountClosed       = "account_closed"
+	CodeAccountNotEmpty     = "account_not_empty"
+	CodeAccountBusy         = "account_busy"
+	CodeInvalidTransition   = "invalid_status_transition"
+	CodeUnknownRole         = "unknown_role"
+	CodeLastAdmin           = "last_admin"
+	CodeIdempotencyKey      = "idempotency_key_invalid"
+	CodeRequestInProgress   = "request_in_progress"
+	CodeTimeout             = "timeout"
+	CodeRequestCanceled     = "request_canceled"
+	CodeProviderUnavailable = "provider_unavailable"
+	CodeQuoteExpired        = "quote_expired"
+	CodeQuoteUsed           = "quote_used"
+	CodeFXPairUnavailable   = "fx_pair_unavailable"
+	CodeInternal            = "internal_error"
+)
+
+// APIError is the body of every error response:
+//
+//	{"error": {"code": "...", "message": "...", "details": [...], "request_id": "..."}}
+type APIError struct {
+	Status    int          `json:"-"`
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	// cause is the underlying error. It is logged but never sent to the client.
+	cause error
+}
+
+// FieldError describes one invalid field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type ErrorResponse struct {
+	Error *APIError `json:"error"`
+}
+
+func (e *APIError) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.cause)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.cause
+}
+
+func newAPIError(status int, code string, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+func notFound(message string) *APIError {
+	return newAPIError(http.StatusNotFound, CodeNotFound, message)
+}
+
+func forbidden(message string) *APIError {
+	return newAPIError(http.StatusForbidden, CodeForbidden, message)
+}
+
+func unauthorized(code string, message string) *APIError {
+	return newAPIError(http.StatusUnauthorized, code, message)
+}
+
+var errInvalidCredentials = newAPIError(http.StatusUnauthorized, CodeInvalidCredentials, "invalid email or password")
+
+// isUniqueViolation reports whether err is a Postgres unique_violation, for
+// handlers that want a more specific message than the generic mapping.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// abortWithError records err on the request and stops the handler chain.
+// ErrorMiddleware turns it into the response.
+func abortWithError(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
+
+// ErrorMiddleware writes the error envelope for the last error a handler
+// recorded with abortWithError, unless a response was already written.
+// It must run after RequestIDMiddleware.
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		renderError(c)
+	}
+}
+
+// renderError writes the pending error response now. Middleware that needs
+// to see the final response, like IdempotencyMiddleware, calls it after
+// c.Next instead of waiting for ErrorMiddleware.
+func renderError(c *gin.Context) {
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return
+	}
+
+	err := c.Errors.Last().Err
+	apiErr := toAPIError(err)
+
+	// Past the deadline, or after the client left, queries fail in ways
+	// that don't say why, such as sql.ErrTxDone. The context does.
+	if ctxErr := c.Request.Context().Err(); ctxErr != nil && apiErr.Status >= http.StatusInternalServerError {
+		apiErr = toAPIError(ctxErr)
+		apiErr.cause = err
+	}
+
+	apiErr.RequestID = c.GetString("request_id")
+
+	if apiErr.Status >= http.StatusInternalServerError {
+		requestLogger(c).Error("request failed", "code", apiErr.Code, "error", err)
+	}
+
+	c.JSON(apiErr.Status, ErrorResponse{Error: apiErr})
+}
+
+// recoverPanic answers a handler panic with the internal error envelope.
+func recoverPanic(c *gin.Context, recovered any) {
+	requestLogger(c).Error("panic while serving request", "panic", fmt.Sprint(recovered))
+
+	apiErr := newAPIError(http.StatusInternalServerError, CodeInternal, "internal server error")
+	apiErr.RequestID = c.GetString("request_id")
+	c.AbortWithStatusJSON(apiErr.Status, ErrorResponse{Error: apiErr})
+}
+
+// toAPIError maps an error from any layer to its stable code and status.
+// Errors it does not recognise become a 500 with a generic message, so raw
+// database or driver messages never reach the client.
+func toAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		copied := *apiErr
+		return &copied
+	}
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		return validationError(validationErrs)
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return newAPIError(http.StatusBadRequest, CodeInvalidBody, "request body must be valid JSON")
+	}
+	if errors.As(err, &typeErr) {
+		return &APIError{
+			Status:  http.StatusBadRequest,
+			Code:    CodeValidationFailed,
+			Message: "request validation failed",
+			Details: []FieldError{{Field: typeErr.Field, Rule: "type", Message: fmt.Sprintf("must be a %s", typeErr.Type)}},
+		}
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return postgresError(pqErr)
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return &APIError{Status: http.StatusServiceUnavailable, Code: CodeTimeout, Message: "request timed out, retry it", cause: err}
+	case errors.Is(err, context.Canceled):
+		return &APIError{Status: statusClientClosedRequest, Code: CodeRequestCanceled, Message: "request canceled by the client", cause: err}
+	case errors.Is(err, sql.ErrNoRows):
+		return notFound("resource not found")
+	case errors.Is(err, utils.ErrUnauthenticated):
+		return unauthorized(CodeUnauthorized, "authentication required")
+	case errors.Is(err, db.ErrInsufficientFunds):
+		return newAPIError(http.StatusUnprocessableEntity, CodeInsufficientFunds, "insufficient funds")
+	case errors.Is(err, db.ErrAccountFrozen):
+		return newAPIError(http.StatusUnprocessableEntity, CodeAccountFrozen, err.Error())
+	case errors.Is(err, db.ErrAccountDormant):
+		return newAPIError(http.StatusUnprocessableEntity, CodeAccountDormant, err.Error()+", reopen it first")
+	case errors.Is(err, db.ErrAccountClosed):
+		return newAPIError(http.StatusUnprocessableEntity, CodeAccountClosed, err.Error())
+	case errors.Is(err, db.ErrAccountNotEmpty):
+		return newAPIError(http.StatusUnprocessableEntity, CodeAccountNotEmpty, err.Error())
+	case errors.Is(err, db.ErrAccountBusy):
+		return newAPIError(http.StatusConflict, CodeAccountBusy, "account has pending deposits or withdrawals")
+	case errors.Is(err, db.ErrInvalidTransition), errors.Is(err, db.ErrSystemAccountState):
+		return newAPIError(http.StatusConflict, CodeInvalidTransition, err.Error())
+	case errors.Is(err, db.ErrResetTokenInvalid):
+		return newAPIError(http.StatusBadRequest, CodeResetTokenInvalid, err.Error())
+	case errors.Is(err, db.ErrVerificationTokenInvalid):
+		return newAPIError(http.StatusBadRequest, CodeVerificationInvalid, err.Error())
+	case errors.Is(err, db.ErrEmailAlreadyVerified):
+		return newAPIError(http.StatusConflict, CodeEmailVerified, err.Error())
+	case errors.Is(err, db.ErrLoginChallengeInvalid):
+		return unauthorized(CodeChallengeInvalid, err.Error())
+	case errors.Is(err, db.ErrSecondFactorInvalid):
+		return newAPIError(http.StatusForbidden, CodeInvalidTwoFactor, err.Error())
+	case errors.Is(err, db.ErrSecondFactorLocked):
+		return newAPIError(http.StatusTooManyRequests, CodeRateLimited, err.Error())
+	case errors.Is(err, db.ErrTwoFactorEnabled):
+		return newAPIError(http.StatusConflict, CodeTwoFactorEnabled, err.Error())
+	case errors.Is(err, db.ErrTwoFactorNotEnabled):
+		return newAPIError(http.StatusConflict, CodeTwoFactorDisabled, err.Error())
+	case errors.Is(err, db.ErrUnknownRole):
+		return newAPIError(http.StatusUnprocessableEntity, CodeUnknownRole, err.Error())
+	case errors.Is(err, db.ErrRoleNotHeld):
+		return notFound(err.Error())
+	case errors.Is(err, db.ErrLastAdmin):
+		return newAPIError(http.StatusConflict, CodeLastAdmin, err.Error())
+	case errors.Is(err, db.ErrCurrencyMismatch), errors.Is(err, utils.ErrMoneyCurrency):
+		return newAPIError(http.StatusUnprocessableEntity, CodeCurrencyMismatch, "accounts must have the same currency")
+	case errors.Is(err, utils.ErrAmountLimit):
+		return newAPIError(http.StatusUnprocessableEntity, CodeAmountLimit, err.Error())
+	case errors.Is(err, utils.ErrInvalidAmount), errors.Is(err, utils.ErrAmountOverflow), errors.Is(err, utils.ErrUnsupportedCurrency):
+		return newAPIError(http.StatusBadRequest, CodeInvalidAmount, err.Error())
+	case errors.Is(err, fx.ErrAmountTooSmall):
+		return newAPIError(http.StatusBadRequest, CodeInvalidAmount, "amount is too small to convert")
+	case errors.Is(err, fx.ErrUnknownPair):
+		return newAPIError(http.StatusUnprocessableEntity, CodeFXPairUnavailable, "no rate is available for this currency pair")
+	case errors.Is(err, db.ErrQuoteExpired):
+		return newAPIError(http.StatusUnprocessableEntity, CodeQuoteExpired, "quote has expired, request a new one")
+	case errors.Is(err, db.ErrQuoteUsed):
+		return newAPIError(http.StatusConflict, CodeQuoteUsed, "quote has already been executed")
+	case errors.Is(err, db.ErrRefreshTokenInvalid), errors.Is(err, db.ErrRefreshTokenReused):
+		return unauthorized(CodeInvalidToken, "refresh token is invalid or expired")
+	}
+
+	return &APIError{Status: http.StatusInternalServerError, Code: CodeInternal, Message: "internal server error", cause: err}
+}
+
+// postgresError maps SQLSTATE codes. Constraint violations are the client's
+// fault, serialization failures and deadlocks are safe to retry.
+func postgresError(err *pq.Error) *APIError {
+	switch err.Code {
+	case "23505": // unique_violation
+		return &APIError{Status: http.StatusConflict, Code: CodeAlreadyExists, Message: "resource already exists", cause: err}
+	case "23503": // foreign_key_violation
+		return &APIError{Status: http.StatusUnprocessableEntity, Code: CodeInvalidReference, Message: "referenced resource does not exist", cause: err}
+	case "23502", "23514": // not_null_violation, check_violation
+		return &APIError{Status: http.StatusUnprocessableEntity, Code: CodeConstraintViolated, Message: "request violates a data constraint", cause: err}
+	case "22003": // numeric_value_out_of_range
+		return &APIError{Status: http.StatusBadRequest, Code: CodeInvalidAmount, Message: "value out of range", cause: err}
+	case "40001", "40P01": // serialization_failure, deadlock_detected
+		return &APIError{Status: http.StatusConflict, Code: CodeRetryable, Message: "request conflicted with a concurrent request, retry it", cause: err}
+	case "55P03", "57014": // lock_not_available, query_canceled
+		return &APIError{Status: http.StatusServiceUnavailable, Code: CodeTimeout, Message: "request timed out, retry it", cause: err}
+	}
+
+	return &APIError{Status: http.StatusInternalServerError, Code: CodeInternal, Message: "internal server error", cause: err}
+}
+
+func validationError(errs validator.ValidationErrors) *APIError {
+	details := make([]FieldError, 0, len(errs))
+
+	for _, fe := range errs {
+		details = append(details, FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Message: validationMessage(fe),
+		})
+	}
+
+	return &APIError{
+		Status:  http.StatusBadRequest,
+		Code:    CodeValidationFailed,
+		Message: "request validation failed",
+		Details: details,
+	}
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "currency":
+		return "must be a supported currency"
+	case "min":
+		if fe.Kind().String() == "string" {
+			return fmt.Sprintf("must be at least %s characters", fe.Param())
+		}
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "max":
+		if fe.Kind().String() == "string" {
+			return fmt.Sprintf("must be at most %s characters", fe.Param())
+		}
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of %s", fe.Param())
+	case "nefield":
+		return fmt.Sprintf("must differ from %s", jsonFieldName(fe.Param()))
+	}
+
+	return fmt.Sprintf("failed the %s rule", fe.Tag())
+}
+
+// jsonFieldName turns a Go field name like FromAccountID into the snake_case
+// name clients send, for validator parameters that reference other fields.
+func jsonFieldName(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+
+	for i, r := range runes {
+		upper := r >= 'A' && r <= 'Z'
+		if upper && i > 0 {
+			prevLower := runes[i-1] >= 'a' && runes[i-1] <= 'z'
+			nextLower := i+1 < len(runes) && runes[i+1] >= 'a' && runes[i+1] <= 'z'
+			if prevLower || nextLower {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteString(strings.ToLower(string(r)))
+	}
+
+	return b.String()
+}
+
+// jsonTagName makes validator report fields by their JSON names.
+func jsonTagName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
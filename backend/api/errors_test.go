@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToAPIError(t *testing.T) {
+	testCases := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"no rows", sql.ErrNoRows, http.StatusNotFound, CodeNotFound},
+		{"wrapped no rows", fmt.Errorf("get account: %w", sql.ErrNoRows), http.StatusNotFound, CodeNotFound},
+		{"unique violation", &pq.Error{Code: "23505"}, http.StatusConflict, CodeAlreadyExists},
+		{"foreign key violation", &pq.Error{Code: "23503"}, http.StatusUnprocessableEntity, CodeInvalidReference},
+		{"serialization failure", &pq.Error{Code: "40001"}, http.StatusConflict, CodeRetryable},
+		{"deadlock", &pq.Error{Code: "40P01"}, http.StatusConflict, CodeRetryable},
+		{"query canceled", &pq.Error{Code: "57014"}, http.StatusServiceUnavailable, CodeTimeout},
+		{"deadline exceeded", fmt.Errorf("get account: %w", context.DeadlineExceeded), http.StatusServiceUnavailable, CodeTimeout},
+		{"client gone", context.Canceled, 499, CodeRequestCanceled},
+		{"unknown postgres error", &pq.Error{Code: "XX000", Message: "secret detail"}, http.StatusInternalServerError, CodeInternal},
+		{"insufficient funds", db.ErrInsufficientFunds, http.StatusUnprocessableEntity, CodeInsufficientFunds},
+		{"amount limit", fmt.Errorf("%w: maximum is 100.00", utils.ErrAmountLimit), http.StatusUnprocessableEntity, CodeAmountLimit},
+		{"frozen account", fmt.Errorf("%w: account 7", db.ErrAccountFrozen), http.StatusUnprocessableEntity, CodeAccountFrozen},
+		{"invalid transition", db.ErrInvalidTransition, http.StatusConflict, CodeInvalidTransition},
+		{"reset token invalid", db.ErrResetTokenInvalid, http.StatusBadRequest, CodeResetTokenInvalid},
+		{"verification token invalid", db.ErrVerificationTokenInvalid, http.StatusBadRequest, CodeVerificationInvalid},
+		{"email already verified", db.ErrEmailAlreadyVerified, http.StatusConflict, CodeEmailVerified},
+		{"login challenge invalid", db.ErrLoginChallengeInvalid, http.StatusUnauthorized, CodeChallengeInvalid},
+		{"two-factor code invalid", db.ErrSecondFactorInvalid, http.StatusForbidden, CodeInvalidTwoFactor},
+		{"two-factor locked", db.ErrSecondFactorLocked, http.StatusTooManyRequests, CodeRateLimited},
+		{"two-factor enabled", db.ErrTwoFactorEnabled, http.StatusConflict, CodeTwoFactorEnabled},
+		{"two-factor not enabled", db.ErrTwoFactorNotEnabled, http.StatusConflict, CodeTwoFactorDisabled},
+		{"unknown role", db.ErrUnknownRole, http.StatusUnprocessableEntity, CodeUnknownRole},
+		{"role not held", db.ErrRoleNotHeld, http.StatusNotFound, CodeNotFound},
+		{"last admin", db.ErrLastAdmin, http.StatusConflict, CodeLastAdmin},
+		{"quote expired", db.ErrQuoteExpired, http.StatusUnprocessableEntity, CodeQuoteExpired},
+		{"quote used", db.ErrQuoteUsed, http.StatusConflict, CodeQuoteUsed},
+		{"bad json", &json.SyntaxError{}, http.StatusBadRequest, CodeInvalidBody},
+		{"empty body", io.EOF, http.StatusBadRequest, CodeInvalidBody},
+		{"api error", forbidden("nope"), http.StatusForbidden, CodeForbidden},
+		{"unknown", errors.New("connection refused"), http.StatusInternalServerError, CodeInternal},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			apiErr := toAPIError(tc.err)
+			assert.Equal(t, tc.status, apiErr.Status)
+			assert.Equal(t, tc.code, apiErr.Code)
+		})
+	}
+
+	// Driver messages stay in the logs, not in the response.
+	assert.Equal(t, "internal server error", toAPIError(&pq.Error{Code: "XX000", Message: "secret detail"}).Message)
+}
+
+func TestErrorMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(RequestIDMiddleware(slog.New(slog.NewTextHandler(io.Discard, nil))), ErrorMiddleware())
+	router.GET("/missing", func(c *gin.Context) {
+		abortWithError(c, sql.ErrNoRows)
+	})
+	router.POST("/bind", func(c *gin.Context) {
+		var req struct {
+			Email string `json:"email" binding:"required,email"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortWithError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/missing", nil)
+	request.Header.Set(requestIDHeader, "req-1")
+	router.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+
+	var body ErrorResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Equal(t, CodeNotFound, body.Error.Code)
+	assert.Equal(t, "req-1", body.Error.RequestID)
+
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodPost, "/bind", strings.NewReader(`{"email":"not-an-email"}`))
+	router.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	body = ErrorResponse{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Equal(t, CodeValidationFailed, body.Error.Code)
+	require.Len(t, body.Error.Details, 1)
+	assert.Equal(t, "email", body.Error.Details[0].Rule)
+	assert.NotEmpty(t, body.Error.RequestID)
+}
@@ -0,0 +1,267 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/funding"
+	"github/kasho/backend/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Funding struct {
+	server *Server
+}
+
+func (f Funding) router(server *Server) {
+	f.server = server
+
+	serverGroup := server.router.Group("/funding", AuthenticatedMiddleware(server.queries))
+	serverGroup.GET("", f.listFundingRequests)
+	serverGroup.GET(":id", f.getFundingRequest)
+
+	verified := serverGroup.Group("", RequireVerifiedEmail(server.queries), server.idempotency())
+	verified.POST("deposits", f.createDeposit)
+	verified.POST("withdrawals", f.createWithdrawal)
+}
+
+type FundingParams struct {
+	AccountID int64  `json:"account_id" binding:"required,min=1"`
+	Amount    string `json:"amount" binding:"required"`
+	Currency  string `json:"currency" binding:"required,currency"`
+}
+
+func (f *Funding) createDeposit(c *gin.Context) {
+	f.createFundingRequest(c, db.FundingDeposit)
+}
+
+func (f *Funding) createWithdrawal(c *gin.Context) {
+	f.createFundingRequest(c, db.FundingWithdrawal)
+}
+
+// createFundingRequest records the request, hands it to the funding provider
+// and applies whatever the provider answered. Requests the provider leaves
+// pending, or whose answer was lost, are answered with 202 and finished
+// later by pollFunding.
+func (f *Funding) createFundingRequest(c *gin.Context, direction string) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var req FundingParams
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	amount, err := parseAmount(req.Amount, req.Currency)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	account, ok := ownedAccount(c, f.server.queries, userId, req.AccountID)
+	if !ok {
+		return
+	}
+
+	if account.Currency != amount.Currency {
+		abortWithError(c, db.ErrCurrencyMismatch)
+		return
+	}
+
+	// Only money leaving Kasho needs a step-up.
+	if direction == db.FundingWithdrawal {
+		if err := f.server.requireStepUp(c, amount); err != nil {
+			abortWithError(c, err)
+			return
+		}
+	}
+
+	ctx := auditContext(c)
+	provider := f.server.fundingProvider
+
+	request, err := f.server.store.CreateFundingTx(ctx, db.CreateFundingTxParams{
+		AccountID: account.ID,
+		Direction: direction,
+		Amount:    amount,
+		Provider:  provider.Name(),
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	// The request exists and may hold funds now. See it through with the
+	// provider even if the client goes away, rather than leave it pending
+	// with nothing submitted, but give up before pollFunding would submit
+	// it again.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), fundingSubmitTimeout)
+	defer cancel()
+
+	result, err := provider.Submit(ctx, funding.Request{
+		ID:        request.ID,
+		AccountID: request.AccountID,
+		Direction: funding.Direction(direction),
+		Amount:    amount,
+	})
+	if errors.Is(err, funding.ErrRejected) {
+		// The provider did nothing, so the request can be failed safely and
+		// any held funds returned.
+		if _, failErr := f.server.store.FailFundingTx(ctx, request.ID, "rejected by provider"); failErr != nil {
+			requestLogger(c).Error("failed to fail funding request", "funding_id", request.ID, "error", failErr)
+		}
+		abortWithError(c, &APIError{
+			Status:  http.StatusBadGateway,
+			Code:    CodeProviderUnavailable,
+			Message: "funding provider rejected the request, try again later",
+			cause:   err,
+		})
+		return
+	}
+
+	// Any other error may have come after the provider accepted the
+	// request, so failing it could pay out or credit twice once the client
+	// retries. It stays pending and pollFunding submits it again under the
+	// same ID, which the provider treats as the same request. The same goes
+	// for an answer that couldn't be recorded.
+	if err != nil {
+		requestLogger(c).Warn("funding provider submit failed, left pending", "funding_id", request.ID, "error", err)
+	} else if updated, err := f.server.applyFundingResult(ctx, request, result); err != nil {
+		requestLogger(c).Error("failed to apply funding result, left pending", "funding_id", request.ID, "error", err)
+	} else {
+		request = updated
+	}
+
+	requestLogger(c).Info("funding request created",
+		"funding_id", request.ID,
+		"direction", direction,
+		"account_id", account.ID,
+		"amount", amount.String(),
+		"status", request.Status,
+	)
+
+	status := http.StatusCreated
+	if request.Status == db.FundingStatusPending {
+		status = http.StatusAccepted
+	}
+
+	c.JSON(status, FundingResponse{}.toFundingResponse(&request))
+}
+
+type FundingURI struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func (f *Funding) getFundingRequest(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var uri FundingURI
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	request, err := f.server.queries.GetFundingRequestByID(c.Request.Context(), uri.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	account, err := f.server.queries.GetAccountByID(c.Request.Context(), request.AccountID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if account.UserID != accountOwner(userId) {
+		abortWithError(c, notFound(fmt.Sprintf("funding request %d not found", uri.ID)))
+		return
+	}
+
+	c.JSON(http.StatusOK, FundingResponse{}.toFundingResponse(&request))
+}
+
+type ListFundingRequest struct {
+	PageRequest
+	AccountID int64 `form:"account_id" binding:"required,min=1"`
+}
+
+func (f *Funding) listFundingRequests(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var req ListFundingRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	account, ok := ownedAccount(c, f.server.queries, userId, req.AccountID)
+	if !ok {
+		return
+	}
+
+	beforeID, err := req.beforeID()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	requests, err := f.server.queries.ListFundingRequestsByAccountID(c.Request.Context(), db.ListFundingRequestsByAccountIDParams{
+		AccountID: account.ID,
+		BeforeID:  beforeID,
+		PageLimit: req.queryLimit(),
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPage(requests, req.PageRequest, func(v *db.FundingRequest) int64 { return v.ID }, FundingResponse{}.toFundingResponse))
+}
+
+type FundingResponse struct {
+	ID            int64       `json:"id"`
+	AccountID     int64       `json:"account_id"`
+	Direction     string      `json:"direction"`
+	Amount        utils.Money `json:"amount"`
+	Status        string      `json:"status"`
+	FailureReason string      `json:"failure_reason,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+	SettledAt     *time.Time  `json:"settled_at,omitempty"`
+}
+
+func (f FundingResponse) toFundingResponse(request *db.FundingRequest) *FundingResponse {
+	response := &FundingResponse{
+		ID:            request.ID,
+		AccountID:     request.AccountID,
+		Direction:     request.Direction,
+		Amount:        utils.NewMoney(request.Amount, request.Currency),
+		Status:        request.Status,
+		FailureReason: request.FailureReason,
+		CreatedAt:     request.CreatedAt,
+	}
+
+	if request.SettledAt.Valid {
+		response.SettledAt = &request.SettledAt.Time
+	}
+
+	return response
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/funding"
+	"github/kasho/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fundingTest struct {
+	conn     *sql.DB
+	server   *Server
+	store    *db.Store
+	provider *funding.Fake
+	account  db.Account
+}
+
+// newFundingTest serves withdrawals for a user holding an account with
+// 100.00 USD, backed by a fake provider that settles at once.
+func newFundingTest(t *testing.T) *fundingTest {
+	gin.SetMode(gin.TestMode)
+
+	conn := newTestDB(t)
+	store := db.NewStore(conn)
+	user := createTestUser(t, store)
+	ctx := context.Background()
+
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterValidation("currency", currencyValidator)
+	}
+
+	account, err := store.CreateAccount(ctx, db.CreateAccountParams{
+		UserID:   sql.NullInt32{Int32: int32(user.ID), Valid: true},
+		Currency: "USD",
+	})
+	require.NoError(t, err)
+
+	equity, err := store.UpsertSystemAccount(ctx, db.UpsertSystemAccountParams{
+		Kind:     db.AccountKindEquity,
+		Currency: "USD",
+	})
+	require.NoError(t, err)
+
+	_, err = store.PostJournal(ctx, db.JournalParams{
+		Kind: db.JournalKindOpeningBalance,
+		Postings: []db.Posting{
+			{AccountID: equity.ID, Amount: -10000},
+			{AccountID: account.ID, Amount: 10000},
+		},
+	})
+	require.NoError(t, err)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	provider := funding.NewFake(0)
+
+	server := &Server{
+		router:          gin.New(),
+		store:           store,
+		queries:         store.Queries,
+		config:          &utils.Config{},
+		logger:          logger,
+		fundingProvider: provider,
+	}
+
+	f := &Funding{server: server}
+	server.router.Use(RequestIDMiddleware(logger), ErrorMiddleware())
+	server.router.POST("/funding/withdrawals", func(c *gin.Context) { c.Set("user_id", user.ID) }, f.createWithdrawal)
+
+	return &fundingTest{conn: conn, server: server, store: store, provider: provider, account: account}
+}
+
+func (ft *fundingTest) withdraw(t *testing.T) (*httptest.ResponseRecorder, FundingResponse) {
+	body := fmt.Sprintf(`{"account_id": %d, "amount": "10.00", "currency": "USD"}`, ft.account.ID)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/funding/withdrawals", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	ft.server.router.ServeHTTP(recorder, request)
+
+	var response FundingResponse
+	if recorder.Code < http.StatusBadRequest {
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	}
+
+	return recorder, response
+}
+
+func (ft *fundingTest) balance(t *testing.T) utils.Amount {
+	account, err := ft.store.GetAccountByID(context.Background(), ft.account.ID)
+	require.NoError(t, err)
+	return account.Balance
+}
+
+func TestWithdrawalSubmitErrorStaysPending(t *testing.T) {
+	ft := newFundingTest(t)
+
+	// The provider took the payout but the answer never arrived.
+	ft.provider.SubmitErr = errors.New("connection reset by peer")
+
+	recorder, response := ft.withdraw(t)
+	require.Equal(t, http.StatusAccepted, recorder.Code)
+	assert.Equal(t, db.FundingStatusPending, response.Status)
+	assert.Equal(t, utils.Amount(9000), ft.balance(t))
+
+	// Once the grace period is over the poller submits it again under the
+	// same ID and applies the provider's answer.
+	ft.provider.SubmitErr = nil
+	_, err := ft.conn.ExecContext(context.Background(),
+		`UPDATE funding_requests SET created_at = created_at - $1 * interval '1 second' WHERE id = $2`,
+		int64(2*fundingResubmitAfter.Seconds()), response.ID)
+	require.NoError(t, err)
+
+	ft.server.pollFunding()
+
+	request, err := ft.store.GetFundingRequestByID(context.Background(), response.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.FundingStatusSettled, request.Status)
+	assert.True(t, request.ProviderReference.Valid)
+
+	// Paid out once: the held 10.00 and nothing more.
+	assert.Equal(t, utils.Amount(9000), ft.balance(t))
+
+	requests, err := ft.store.ListFundingRequestsByAccountID(context.Background(), db.ListFundingRequestsByAccountIDParams{
+		AccountID: ft.account.ID,
+		PageLimit: 10,
+	})
+	require.NoError(t, err)
+	assert.Len(t, requests, 1)
+}
+
+func TestWithdrawalRejectedReturnsFunds(t *testing.T) {
+	ft := newFundingTest(t)
+
+	ft.provider.SubmitErr = fmt.Errorf("%w: account blocked", funding.ErrRejected)
+
+	recorder, _ := ft.withdraw(t)
+	require.Equal(t, http.StatusBadGateway, recorder.Code)
+	assert.Equal(t, utils.Amount(10000), ft.balance(t))
+
+	requests, err := ft.store.ListFundingRequestsByAccountID(context.Background(), db.ListFundingRequestsByAccountIDParams{
+		AccountID: ft.account.ID,
+		PageLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	assert.Equal(t, db.FundingStatusFailed, requests[0].Status)
+}
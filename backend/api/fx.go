@@ -0,0 +1,297 @@
+package api
+
+import (
+	"fmt"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/fx"
+	"github/kasho/backend/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FX struct {
+	server *Server
+}
+
+func (f FX) router(server *Server) {
+	f.server = server
+
+	serverGroup := server.router.Group("/fx", AuthenticatedMiddleware(server.queries))
+	serverGroup.GET("rate", f.getRate)
+	serverGroup.GET("quotes/:id", f.getQuote)
+
+	verified := serverGroup.Group("", RequireVerifiedEmail(server.queries), server.idempotency())
+	verified.POST("quotes", f.createQuote)
+	verified.POST("conversions", f.createConversion)
+}
+
+type RateRequest struct {
+	From string `form:"from" binding:"required,currency"`
+	To   string `form:"to" binding:"required,currency,nefield=From"`
+}
+
+type RateResponse struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Mid    string `json:"mid"`
+	Rate   string `json:"rate"`
+	Source string `json:"source"`
+}
+
+// getRate shows the mid rate of a pair and the rate a conversion would get
+// after the spread. It is indicative only: only a quote locks a rate.
+func (f *FX) getRate(c *gin.Context) {
+	var req RateRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	rate, err := f.server.fxProvider.Rate(c.Request.Context(), req.From, req.To)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, RateResponse{
+		From:   req.From,
+		To:     req.To,
+		Mid:    fx.FormatRate(rate.Value),
+		Rate:   fx.FormatRate(f.server.fxPricing.CustomerRate(rate.Value)),
+		Source: rate.Source,
+	})
+}
+
+type QuoteRequest struct {
+	FromAccountID int64  `json:"from_account_id" binding:"required,min=1"`
+	ToAccountID   int64  `json:"to_account_id" binding:"required,min=1,nefield=FromAccountID"`
+	Amount        string `json:"amount" binding:"required"`
+	Currency      string `json:"currency" binding:"required,currency"`
+}
+
+// createQuote prices selling amount out of one of the caller's accounts into
+// another of their accounts in a different currency, and locks that price
+// for Fx_quote_ttl.
+func (f *FX) createQuote(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var req QuoteRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	amount, err := parseAmount(req.Amount, req.Currency)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	fromAccount, ok := ownedAccount(c, f.server.queries, userId, req.FromAccountID)
+	if !ok {
+		return
+	}
+
+	toAccount, ok := ownedAccount(c, f.server.queries, userId, req.ToAccountID)
+	if !ok {
+		return
+	}
+
+	if fromAccount.Currency != amount.Currency {
+		abortWithError(c, newAPIError(http.StatusUnprocessableEntity, CodeCurrencyMismatch, fmt.Sprintf("account %d is held in %s, not %s", fromAccount.ID, fromAccount.Currency, amount.Currency)))
+		return
+	}
+
+	if !utils.IsSupportedCurrency(toAccount.Currency) {
+		abortWithError(c, newAPIError(http.StatusUnprocessableEntity, CodeCurrencyDisabled, fmt.Sprintf("%s is not enabled", toAccount.Currency)))
+		return
+	}
+
+	if toAccount.Currency == fromAccount.Currency {
+		abortWithError(c, newAPIError(http.StatusUnprocessableEntity, CodeCurrencyMismatch, "accounts must have different currencies, use a transfer instead"))
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	rate, err := f.server.fxProvider.Rate(ctx, fromAccount.Currency, toAccount.Currency)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	priced, err := f.server.fxPricing.Price(amount, toAccount.Currency, rate.Value)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var quote db.FxQuote
+
+	err = f.server.store.AuditTx(auditContext(c), func(q *db.Queries) (db.AuditRecord, error) {
+		rateRow, err := q.CreateFxRate(ctx, db.CreateFxRateParams{
+			BaseCurrency:  rate.Base,
+			QuoteCurrency: rate.Quote,
+			Rate:          fx.FormatRate(rate.Value),
+			Source:        rate.Source,
+		})
+		if err != nil {
+			return db.AuditRecord{}, err
+		}
+
+		quote, err = q.CreateFxQuote(ctx, db.CreateFxQuoteParams{
+			UserID:        userId,
+			FromAccountID: fromAccount.ID,
+			ToAccountID:   toAccount.ID,
+			RateID:        rateRow.ID,
+			SellAmount:    priced.Sell.Amount,
+			SellCurrency:  priced.Sell.Currency,
+			BuyAmount:     priced.Buy.Amount,
+			BuyCurrency:   priced.Buy.Currency,
+			FeeAmount:     priced.Fee.Amount,
+			SpreadAmount:  priced.Spread.Amount,
+			Rate:          fx.FormatRate(priced.Rate),
+			ExpiresAt:     time.Now().Add(f.server.config.Fx_quote_ttl),
+		})
+		return db.AuditRecord{
+			Action:     "fx.quote_create",
+			TargetType: "fx_quote",
+			TargetID:   strconv.FormatInt(quote.ID, 10),
+			After:      quote,
+		}, err
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, QuoteResponse{}.toQuoteResponse(&quote))
+}
+
+type QuoteURI struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func (f *FX) getQuote(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var uri QuoteURI
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	quote, err := f.server.queries.GetFxQuoteByID(c.Request.Context(), uri.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if quote.UserID != userId {
+		abortWithError(c, notFound(fmt.Sprintf("quote %d not found", uri.ID)))
+		return
+	}
+
+	c.JSON(http.StatusOK, QuoteResponse{}.toQuoteResponse(&quote))
+}
+
+type ConversionRequest struct {
+	QuoteID int64 `json:"quote_id" binding:"required,min=1"`
+}
+
+type ConversionResponse struct {
+	Quote       *QuoteResponse   `json:"quote"`
+	FromAccount *AccountResponse `json:"from_account"`
+	ToAccount   *AccountResponse `json:"to_account"`
+}
+
+func (f *FX) createConversion(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var req ConversionRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	result, err := f.server.store.ConvertTx(auditContext(c), db.ConvertTxParams{
+		QuoteID: req.QuoteID,
+		UserID:  userId,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	requestLogger(c).Info("fx conversion executed",
+		"quote_id", result.Quote.ID,
+		"journal_id", result.Journal.ID,
+		"sell", utils.NewMoney(result.Quote.SellAmount, result.Quote.SellCurrency).String(),
+		"buy", utils.NewMoney(result.Quote.BuyAmount, result.Quote.BuyCurrency).String(),
+	)
+
+	c.JSON(http.StatusCreated, ConversionResponse{
+		Quote:       QuoteResponse{}.toQuoteResponse(&result.Quote),
+		FromAccount: AccountResponse{}.toAccountResponse(&result.FromAccount),
+		ToAccount:   AccountResponse{}.toAccountResponse(&result.ToAccount),
+	})
+}
+
+type QuoteResponse struct {
+	ID            int64       `json:"id"`
+	FromAccountID int64       `json:"from_account_id"`
+	ToAccountID   int64       `json:"to_account_id"`
+	Sell          utils.Money `json:"sell"`
+	Buy           utils.Money `json:"buy"`
+	Fee           utils.Money `json:"fee"`
+	Spread        utils.Money `json:"spread"`
+	Rate          string      `json:"rate"`
+	ExpiresAt     time.Time   `json:"expires_at"`
+	JournalID     *int64      `json:"journal_id,omitempty"`
+	ExecutedAt    *time.Time  `json:"executed_at,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+func (q QuoteResponse) toQuoteResponse(quote *db.FxQuote) *QuoteResponse {
+	response := &QuoteResponse{
+		ID:            quote.ID,
+		FromAccountID: quote.FromAccountID,
+		ToAccountID:   quote.ToAccountID,
+		Sell:          utils.NewMoney(quote.SellAmount, quote.SellCurrency),
+		Buy:           utils.NewMoney(quote.BuyAmount, quote.BuyCurrency),
+		Fee:           utils.NewMoney(quote.FeeAmount, quote.SellCurrency),
+		Spread:        utils.NewMoney(quote.SpreadAmount, quote.BuyCurrency),
+		Rate:          quote.Rate,
+		ExpiresAt:     quote.ExpiresAt,
+		CreatedAt:     quote.CreatedAt,
+	}
+
+	if quote.JournalID.Valid {
+		response.JournalID = &quote.JournalID.Int64
+	}
+
+	if quote.ExecutedAt.Valid {
+		response.ExecutedAt = &quote.ExecutedAt.Time
+	}
+
+	return response
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readyTimeout bounds the checks behind /readyz, so a hung database fails
+// the probe instead of stalling it.
+const readyTimeout = 2 * time.Second
+
+type Health struct {
+	server *Server
+}
+
+func (h Health) router(server *Server) {
+	h.server = server
+
+	server.router.GET("/healthz", h.healthz)
+	server.router.GET("/readyz", h.readyz)
+
+	if server.metrics != nil {
+		server.router.GET("/metrics", h.metricsAuth, gin.WrapH(server.metrics.Handler()))
+	}
+}
+
+// metricsAuth requires METRICS_TOKEN as a bearer token when one is set.
+// The metrics include business volumes, which shouldn't be public.
+func (h *Health) metricsAuth(c *gin.Context) {
+	token := h.server.config.Metrics_token
+	if token == "" {
+		return
+	}
+
+	given, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		abortWithError(c, unauthorized(CodeInvalidToken, "metrics token is missing or invalid"))
+	}
+}
+
+// healthz reports that the process is up and serving. It checks nothing
+// else, so a database outage doesn't get the server restarted.
+func (h *Health) healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readyz reports whether the server can take traffic: the database answers
+// and its schema is at least at the version this build expects. A newer
+// schema is fine, since migrations only add to it, so old replicas keep
+// serving while a new build rolls out.
+func (h *Health) readyz(c *gin.Context) {
+	if h.server.draining.Load() {
+		notReady(c, "shutting down")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+	defer cancel()
+
+	if err := h.server.store.Ping(ctx); err != nil {
+		requestLogger(c).Warn("not ready", "reason", "database unreachable", "error", err)
+		notReady(c, "database unreachable")
+		return
+	}
+
+	version, dirty, err := h.server.store.SchemaVersion(ctx)
+	if err != nil {
+		requestLogger(c).Warn("not ready", "reason", "no migration version", "error", err)
+		notReady(c, "migration version unknown")
+		return
+	}
+
+	if dirty || version < h.server.schemaVersion {
+		requestLogger(c).Warn("not ready", "reason", "migration version", "version", version, "dirty", dirty, "expected", h.server.schemaVersion)
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":            "not ready",
+			"reason":            "database is behind the expected migration version or dirty",
+			"migration_version": version,
+			"migration_dirty":   dirty,
+			"expected_version":  h.server.schemaVersion,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ready", "migration_version": version})
+}
+
+func notReady(c *gin.Context, reason string) {
+	c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "reason": reason})
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthz(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server := &Server{router: gin.New()}
+	Health{}.router(server)
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestReadyzWhileDraining(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// No store: a draining server must answer without touching the
+	// database.
+	server := &Server{router: gin.New()}
+	server.draining.Store(true)
+	Health{}.router(server)
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	var body struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Equal(t, "not ready", body.Status)
+	assert.Equal(t, "shutting down", body.Reason)
+}
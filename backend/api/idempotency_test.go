@@ -0,0 +1,54 @@
+package api
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github/kasho/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyKeyReleasedOnPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := newTestStore(t)
+	user := createTestUser(t, store)
+
+	calls := 0
+	router := gin.New()
+	router.Use(RequestIDMiddleware(slog.New(slog.NewTextHandler(io.Discard, nil))), gin.CustomRecovery(recoverPanic), ErrorMiddleware())
+	router.POST("/things",
+		func(c *gin.Context) { c.Set("user_id", user.ID) },
+		IdempotencyMiddleware(store.Queries, time.Hour),
+		func(c *gin.Context) {
+			calls++
+			if calls == 1 {
+				panic("boom")
+			}
+			c.JSON(http.StatusCreated, gin.H{"ok": true})
+		},
+	)
+
+	key := utils.RandomString(16)
+	send := func() *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/things", strings.NewReader(`{}`))
+		request.Header.Set(idempotencyKeyHeader, key)
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	require.Equal(t, http.StatusInternalServerError, send().Code)
+
+	// The retry runs the handler again instead of finding the key in use.
+	assert.Equal(t, http.StatusCreated, send().Code)
+	assert.Equal(t, 2, calls)
+}
@@ -1,19 +1,215 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"github/kasho/backend/audit"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/metrics"
+	"github/kasho/backend/utils"
+	"io"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func AuthenticatedMiddleware() gin.HandlerFunc {
+const requestIDHeader = "X-Request-ID"
+
+// RequestIDMiddleware gives every request a correlation ID, taken from the
+// X-Request-ID header when the caller sent a usable one, and echoes it back.
+// A logger tagged with the ID is stored on the gin and request contexts so
+// every log line for the request can be tied together.
+func RequestIDMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if id == "" || len(id) > 64 {
+			id = newRequestID()
+		}
+
+		c.Set("request_id", id)
+		c.Header(requestIDHeader, id)
+
+		reqLogger := logger.With("request_id", id)
+		c.Set("logger", reqLogger)
+		c.Request = c.Request.WithContext(utils.ContextWithLogger(c.Request.Context(), reqLogger))
+
+		c.Next()
+	}
+}
+
+// TracingMiddleware starts a span for every request, continuing the trace
+// of a caller that sent a W3C traceparent header. The span is put on the
+// request context so queries and other work made with it become children,
+// and the trace ID is added to the request's logger. It must run after
+// RequestIDMiddleware.
+func TracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		ctx, span := otel.Tracer("github/kasho/backend/api").Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", c.Request.URL.Path),
+				attribute.String("client.address", c.ClientIP()),
+				attribute.String("request_id", c.GetString("request_id")),
+			),
+		)
+		defer span.End()
+
+		if span.SpanContext().IsValid() {
+			reqLogger := requestLogger(c).With("trace_id", span.SpanContext().TraceID().String())
+			c.Set("logger", reqLogger)
+			ctx = utils.ContextWithLogger(ctx, reqLogger)
+		}
+
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+	}
+}
+
+// DeadlineMiddleware bounds how long a request may run. Its context ends
+// at the deadline, which cancels the queries made with it, so a slow
+// request or client gives its connection back instead of holding it.
+// Routes in overrides, by route pattern, get their own timeout. It must run
+// before ErrorMiddleware, so errors are rendered before the deadline's
+// context is released.
+func DeadlineMiddleware(timeout time.Duration, overrides map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := timeout
+		if override, ok := overrides[c.FullPath()]; ok {
+			limit = override
+		}
+
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), limit)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// probeRoutes are logged at debug level, since orchestrators and Prometheus
+// call them every few seconds.
+var probeRoutes = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// MetricsMiddleware times every request by route and status. Requests that
+// match no route share the "unmatched" route, so scanners probing random
+// paths don't each make a new series.
+func MetricsMiddleware(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		m.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// RequestLoggerMiddleware logs one line per request once it has been served.
+// It must run after RequestIDMiddleware.
+func RequestLoggerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		} else if probeRoutes[c.FullPath()] {
+			level = slog.LevelDebug
+		}
+
+		attrs := []any{
+			"method", c.Request.Method,
+			"route", c.FullPath(),
+			"status", status,
+			"latency", time.Since(start),
+			"client_ip", c.ClientIP(),
+		}
+		if userID, ok := c.Get("user_id"); ok {
+			attrs = append(attrs, "user_id", userID)
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "error", c.Errors.String())
+		}
+
+		requestLogger(c).Log(c.Request.Context(), level, "request served", attrs...)
+	}
+}
+
+// requestLogger returns the logger for the current request.
+func requestLogger(c *gin.Context) *slog.Logger {
+	if logger, ok := c.Get("logger"); ok {
+		return logger.(*slog.Logger)
+	}
+	return slog.Default()
+}
+
+// auditContext returns a context carrying who is making the request, for
+// the audit records of the changes it makes.
+func auditContext(c *gin.Context) context.Context {
+	return audit.WithActor(c.Request.Context(), audit.Actor{
+		UserID:    c.GetInt64("user_id"),
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		RequestID: c.GetString("request_id"),
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func AuthenticatedMiddleware(queries *db.Queries) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := c.GetHeader("Authorization")
 
 		if token == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-			c.Abort()
+			abortWithError(c, unauthorized(CodeUnauthorized, "authentication required"))
 			return
 		}
 	
@@ -21,18 +217,241 @@ func AuthenticatedMiddleware() gin.HandlerFunc {
 		tokenSplit := strings.Split(token, " ")
 
 		if len(tokenSplit) != 2 || strings.ToLower(tokenSplit[0]) != "bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+			abortWithError(c, unauthorized(CodeInvalidToken, "Authorization header must be a bearer token"))
 			return
 		} 
 
-		userId, err := tokenController.VerifyToken(tokenSplit[1])
+		payload, err := tokenController.VerifyToken(c.Request.Context(), tokenSplit[1])
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-			c.Abort()
+			abortWithError(c, unauthorized(CodeInvalidToken, "access token is invalid or expired"))
 			return
 		}
 
-		c.Set("user_id", userId)
+		// Access tokens are only as valid as the session they were issued for.
+		session, err := queries.GetSessionByID(c.Request.Context(), payload.SessionID)
+		if err == sql.ErrNoRows || (err == nil && (session.RevokedAt.Valid || session.UserID != payload.UserID)) {
+			abortWithError(c, unauthorized(CodeSessionRevoked, "session has been revoked"))
+			return
+		}
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		if !time.Now().Before(session.ExpiresAt) {
+			abortWithError(c, unauthorized(CodeSessionRevoked, "session has expired"))
+			return
+		}
+
+		c.Set("user_id", payload.UserID)
+		c.Set("session_id", payload.SessionID)
+		c.Set("token", payload)
+	}
+}
+
+// RequirePermission lets through only callers whose access token grants
+// permission. It must run after AuthenticatedMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		payload, ok := c.Get("token")
+		if !ok {
+			abortWithError(c, utils.ErrUnauthenticated)
+			return
+		}
+
+		if !payload.(*utils.TokenPayload).Can(permission) {
+			abortWithError(c, forbidden(fmt.Sprintf("%s permission required", permission)))
+			return
+		}
 	}
-}
\ No newline at end of file
+}
+
+// RequireVerifiedEmail lets through only users who verified their email,
+// to keep unverified sign-ups from opening accounts or moving money. It
+// reads the user rather than the token so that verifying takes effect
+// without a refresh. It must run after AuthenticatedMiddleware, and before
+// IdempotencyMiddleware so a rejection isn't replayed once verified.
+func RequireVerifiedEmail(queries *db.Queries) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := utils.GetActiveUser(c)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		user, err := queries.GetUserByID(c.Request.Context(), userId)
+		if err == sql.ErrNoRows {
+			abortWithError(c, utils.ErrUnauthenticated)
+			return
+		}
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		if !user.EmailVerifiedAt.Valid {
+			abortWithError(c, newAPIError(http.StatusForbidden, CodeEmailNotVerified, "verify your email to do this"))
+			return
+		}
+	}
+}
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyMiddleware makes mutating requests safe to retry. The first
+// request carrying a given Idempotency-Key claims it and its response is
+// stored; retries with the same key and body get that response replayed,
+// and reusing the key for a different request is rejected with 409.
+// It must run after AuthenticatedMiddleware since keys are scoped per user.
+func IdempotencyMiddleware(queries *db.Queries, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+
+		if key == "" || !isMutatingMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		if len(key) > 255 {
+			abortWithError(c, newAPIError(http.StatusBadRequest, CodeIdempotencyKey, "Idempotency-Key must be at most 255 characters"))
+			return
+		}
+
+		userId, err := utils.GetActiveUser(c)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		requestHash := hashRequest(c.Request.Method, c.Request.URL.Path, body)
+
+		claimed, err := queries.ClaimIdempotencyKey(c.Request.Context(), db.ClaimIdempotencyKeyParams{
+			UserID:        userId,
+			Key:           key,
+			RequestHash:   requestHash,
+			ExpiredBefore: time.Now().Add(-ttl),
+		})
+
+		if err == sql.ErrNoRows {
+			replayIdempotentResponse(c, queries, userId, key, requestHash)
+			return
+		}
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		// A panicking handler never gets to the release below. Recovery runs
+		// further up the stack, so release the key here and let the panic go
+		// on, or retries would find the key in use until it expires.
+		defer func() {
+			if p := recover(); p != nil {
+				releaseIdempotencyKey(c, queries, claimed.ID)
+				panic(p)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = recorder
+
+		c.Next()
+		renderError(c)
+
+		status := recorder.Status()
+
+		// Server errors are not remembered so that the client can retry them.
+		if status >= http.StatusInternalServerError {
+			releaseIdempotencyKey(c, queries, claimed.ID)
+			return
+		}
+
+		// The key has to be completed even when the client left, or
+		// retries would find it in use.
+		ctx := context.WithoutCancel(c.Request.Context())
+
+		err = queries.CompleteIdempotencyKey(ctx, db.CompleteIdempotencyKeyParams{
+			ID:             claimed.ID,
+			ResponseStatus: sql.NullInt32{Int32: int32(status), Valid: true},
+			ResponseBody:   recorder.body.Bytes(),
+		})
+		if err != nil {
+			requestLogger(c).Error("failed to store idempotent response", "key_id", claimed.ID, "error", err)
+		}
+	}
+}
+
+// releaseIdempotencyKey deletes a claimed key so the request can be
+// retried. It runs even when the request timed out or the client left.
+func releaseIdempotencyKey(c *gin.Context, queries *db.Queries, id int64) {
+	ctx := context.WithoutCancel(c.Request.Context())
+	if err := queries.DeleteIdempotencyKey(ctx, id); err != nil {
+		requestLogger(c).Error("failed to release idempotency key", "key_id", id, "error", err)
+	}
+}
+
+func replayIdempotentResponse(c *gin.Context, queries *db.Queries, userId int64, key string, requestHash string) {
+	defer c.Abort()
+
+	stored, err := queries.GetIdempotencyKey(c.Request.Context(), db.GetIdempotencyKeyParams{
+		UserID: userId,
+		Key:    key,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if stored.RequestHash != requestHash {
+		c.Error(newAPIError(http.StatusConflict, CodeIdempotencyKey, "Idempotency-Key was already used for a different request"))
+		return
+	}
+
+	if !stored.ResponseStatus.Valid {
+		c.Error(newAPIError(http.StatusConflict, CodeRequestInProgress, "a request with this Idempotency-Key is still being processed"))
+		return
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(int(stored.ResponseStatus.Int32), "application/json; charset=utf-8", stored.ResponseBody)
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func hashRequest(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder tees everything the handler writes so it can be stored.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/metrics"
+	"github/kasho/backend/utils"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Stands in for AuthenticatedMiddleware with the token named by the
+	// test, or no token at all.
+	authenticate := func(c *gin.Context) {
+		switch c.GetHeader("X-Test-Role") {
+		case "support":
+			c.Set("token", &utils.TokenPayload{UserID: 1, Roles: []string{"support"}, Permissions: []string{"users.read"}})
+		case "customer":
+			c.Set("token", &utils.TokenPayload{UserID: 2})
+		}
+	}
+
+	router := gin.New()
+	router.Use(RequestIDMiddleware(slog.New(slog.NewTextHandler(io.Discard, nil))), ErrorMiddleware(), authenticate)
+	router.GET("/users", RequirePermission("users.read"), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/roles", RequirePermission("roles.manage"), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	testCases := []struct {
+		role   string
+		path   string
+		status int
+		code   string
+	}{
+		{"support", "/users", http.StatusNoContent, ""},
+		{"support", "/roles", http.StatusForbidden, CodeForbidden},
+		{"customer", "/users", http.StatusForbidden, CodeForbidden},
+		{"", "/users", http.StatusUnauthorized, CodeUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		request.Header.Set("X-Test-Role", tc.role)
+		router.ServeHTTP(recorder, request)
+
+		require.Equal(t, tc.status, recorder.Code, "%s %s", tc.role, tc.path)
+
+		if tc.code != "" {
+			var body ErrorResponse
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+			assert.Equal(t, tc.code, body.Error.Code)
+		}
+	}
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := metrics.New(prometheus.NewRegistry())
+
+	router := gin.New()
+	router.Use(MetricsMiddleware(m))
+	router.GET("/accounts/:id", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	for _, path := range []string{"/accounts/1", "/accounts/2", "/wp-login.php"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := recorder.Body.String()
+	assert.Contains(t, body, `kasho_http_request_duration_seconds_count{method="GET",route="/accounts/:id",status="204"} 2`)
+	assert.Contains(t, body, `kasho_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`)
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	router := gin.New()
+	router.Use(RequestIDMiddleware(slog.New(slog.NewTextHandler(io.Discard, nil))), TracingMiddleware())
+	router.GET("/accounts/:id", func(c *gin.Context) {
+		// Work done with the request context joins the request's trace.
+		_, span := otel.Tracer("test").Start(c.Request.Context(), "work")
+		span.End()
+		c.Status(http.StatusInternalServerError)
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/accounts/7", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	work, server := spans[0], spans[1]
+	assert.Equal(t, "GET /accounts/:id", server.Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+	assert.Equal(t, codes.Error, server.Status().Code)
+	assert.Equal(t, server.SpanContext().SpanID(), work.Parent().SpanID())
+}
+
+func TestDeadlineMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Stands in for a query: it runs until the request's context ends.
+	slowQuery := func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+			abortWithError(c, sql.ErrTxDone)
+		case <-time.After(time.Second):
+			c.Status(http.StatusNoContent)
+		}
+	}
+
+	router := gin.New()
+	router.Use(
+		RequestIDMiddleware(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		DeadlineMiddleware(20*time.Millisecond, map[string]time.Duration{"/slow": 5 * time.Second}),
+		ErrorMiddleware(),
+	)
+	router.GET("/query", slowQuery)
+	router.GET("/slow", func(c *gin.Context) {
+		deadline, ok := c.Request.Context().Deadline()
+		require.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(5*time.Second), deadline, time.Second)
+		c.Status(http.StatusNoContent)
+	})
+
+	start := time.Now()
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/query", nil))
+
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	var body ErrorResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Equal(t, CodeTimeout, body.Error.Code)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	// A client that hangs up cancels its request too.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	request := httptest.NewRequest(http.MethodGet, "/query", nil).WithContext(ctx)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, statusClientClosedRequest, recorder.Code)
+}
+
+func TestAuthenticatedMiddlewareSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := newTestStore(t)
+	user := createTestUser(t, store)
+	other := createTestUser(t, store)
+
+	previous := tokenController
+	t.Cleanup(func() { tokenController = previous })
+
+	var err error
+	tokenController, err = utils.NewJWTToken(&utils.Config{
+		Signing_key:      utils.RandomString(32),
+		Access_token_ttl: time.Minute,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(RequestIDMiddleware(slog.New(slog.NewTextHandler(io.Discard, nil))), ErrorMiddleware(), AuthenticatedMiddleware(store.Queries))
+	router.GET("/me", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	newSession := func(userID int64, expiresAt time.Time) int64 {
+		_, hash, err := utils.NewRefreshToken()
+		require.NoError(t, err)
+
+		result, err := store.CreateSessionTx(context.Background(), db.CreateSessionTxParams{
+			UserID:           userID,
+			RefreshTokenHash: hash,
+			ExpiresAt:        expiresAt,
+		})
+		require.NoError(t, err)
+		return result.Session.ID
+	}
+
+	testCases := []struct {
+		name      string
+		sessionID int64
+		status    int
+		code      string
+	}{
+		{"active", newSession(user.ID, time.Now().Add(time.Hour)), http.StatusNoContent, ""},
+		{"another user's session", newSession(other.ID, time.Now().Add(time.Hour)), http.StatusUnauthorized, CodeSessionRevoked},
+		{"expired", newSession(user.ID, time.Now().Add(-time.Second)), http.StatusUnauthorized, CodeSessionRevoked},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			token, err := tokenController.CreateToken(utils.TokenPayload{UserID: user.ID, SessionID: tc.sessionID})
+			require.NoError(t, err)
+
+			request := httptest.NewRequest(http.MethodGet, "/me", nil)
+			request.Header.Set("Authorization", "Bearer "+token)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			require.Equal(t, tc.status, recorder.Code)
+
+			if tc.code != "" {
+				var body ErrorResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+				assert.Equal(t, tc.code, body.Error.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"github/kasho/backend/utils"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// PageRequest holds the query parameters shared by every list endpoint.
+// Lists are ordered newest first and paged with an opaque cursor: pass the
+// next_cursor of one page as cursor to get the next.
+type PageRequest struct {
+	Limit  int32  `form:"limit" binding:"omitempty,min=1,max=100"`
+	Cursor string `form:"cursor"`
+}
+
+// DateRange filters on created_at. From is inclusive and To is exclusive,
+// both RFC 3339.
+type DateRange struct {
+	From time.Time `form:"from"`
+	To   time.Time `form:"to" binding:"omitempty,gtfield=From"`
+}
+
+// AmountRange filters on a decimal amount in the currency of the listed
+// resource. Both bounds are inclusive.
+type AmountRange struct {
+	MinAmount string `form:"min_amount"`
+	MaxAmount string `form:"max_amount"`
+}
+
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// pageCursor is what an opaque cursor decodes to. Every list is keyed on id
+// descending, so the last id seen is enough to resume.
+type pageCursor struct {
+	BeforeID int64 `json:"before_id"`
+}
+
+func (p PageRequest) limit() int32 {
+	if p.Limit == 0 {
+		return defaultPageLimit
+	}
+	return p.Limit
+}
+
+// queryLimit asks for one row more than the page holds, to learn whether
+// there is a next page without a COUNT.
+func (p PageRequest) queryLimit() int32 {
+	return p.limit() + 1
+}
+
+func (p PageRequest) beforeID() (sql.NullInt64, error) {
+	if p.Cursor == "" {
+		return sql.NullInt64{}, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(p.Cursor)
+	if err != nil {
+		return sql.NullInt64{}, errInvalidCursor
+	}
+
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.BeforeID <= 0 {
+		return sql.NullInt64{}, errInvalidCursor
+	}
+
+	return sql.NullInt64{Int64: cursor.BeforeID, Valid: true}, nil
+}
+
+func encodeCursor(beforeID int64) string {
+	data, _ := json.Marshal(pageCursor{BeforeID: beforeID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+var errInvalidCursor = newAPIError(http.StatusBadRequest, CodeInvalidCursor, "cursor is invalid")
+
+// newPage trims the extra row fetched by queryLimit and converts the rest.
+// id returns the key the list is ordered on.
+func newPage[T any, R any](rows []T, p PageRequest, id func(*T) int64, convert func(*T) *R) Page[R] {
+	page := Page[R]{Data: []R{}}
+
+	limit := int(p.limit())
+	if len(rows) > limit {
+		rows = rows[:limit]
+		page.NextCursor = encodeCursor(id(&rows[limit-1]))
+	}
+
+	for i := range rows {
+		page.Data = append(page.Data, *convert(&rows[i]))
+	}
+
+	return page
+}
+
+func (r DateRange) from() sql.NullTime {
+	return sql.NullTime{Time: r.From, Valid: !r.From.IsZero()}
+}
+
+func (r DateRange) to() sql.NullTime {
+	return sql.NullTime{Time: r.To, Valid: !r.To.IsZero()}
+}
+
+func (r AmountRange) isSet() bool {
+	return r.MinAmount != "" || r.MaxAmount != ""
+}
+
+// bounds parses the range into minor units of currency.
+func (r AmountRange) bounds(currency string) (min sql.NullInt64, max sql.NullInt64, err error) {
+	if min, err = parseAmountBound(r.MinAmount, currency); err != nil {
+		return
+	}
+	if max, err = parseAmountBound(r.MaxAmount, currency); err != nil {
+		return
+	}
+	if min.Valid && max.Valid && min.Int64 > max.Int64 {
+		err = newAPIError(http.StatusBadRequest, CodeInvalidAmount, "min_amount must not exceed max_amount")
+	}
+	return
+}
+
+func parseAmountBound(value string, currency string) (sql.NullInt64, error) {
+	if value == "" {
+		return sql.NullInt64{}, nil
+	}
+
+	money, err := utils.ParseMoney(value, currency)
+	if err != nil {
+		return sql.NullInt64{}, err
+	}
+
+	return sql.NullInt64{Int64: int64(money.Amount), Valid: true}, nil
+}
+
+// missingCurrency reports amount filters sent without the currency needed
+// to read them.
+func missingCurrency(message string) *APIError {
+	return &APIError{
+		Status:  http.StatusBadRequest,
+		Code:    CodeValidationFailed,
+		Message: "request validation failed",
+		Details: []FieldError{{Field: "currency", Rule: "required_with", Message: message}},
+	}
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	db "github/kasho/backend/db/sqlc"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageCursor(t *testing.T) {
+	beforeID, err := PageRequest{}.beforeID()
+	require.NoError(t, err)
+	assert.False(t, beforeID.Valid)
+
+	beforeID, err = PageRequest{Cursor: encodeCursor(42)}.beforeID()
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), beforeID.Int64)
+
+	for _, cursor := range []string{"not base64!", "bm90IGpzb24", encodeCursor(0)} {
+		_, err = PageRequest{Cursor: cursor}.beforeID()
+		assert.ErrorIs(t, err, errInvalidCursor, cursor)
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	id := func(u *db.User) int64 { return u.ID }
+
+	req := PageRequest{Limit: 2}
+	assert.Equal(t, int32(3), req.queryLimit())
+
+	// A full page plus the look-ahead row has a next cursor.
+	page := newPage([]db.User{{ID: 9}, {ID: 7}, {ID: 4}}, req, id, UserResponse{}.toUserResponse)
+	require.Len(t, page.Data, 2)
+	assert.Equal(t, int64(7), page.Data[1].ID)
+
+	beforeID, err := PageRequest{Cursor: page.NextCursor}.beforeID()
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), beforeID.Int64)
+
+	// The last page does not.
+	page = newPage([]db.User{{ID: 4}}, req, id, UserResponse{}.toUserResponse)
+	assert.Len(t, page.Data, 1)
+	assert.Empty(t, page.NextCursor)
+
+	assert.Equal(t, int32(defaultPageLimit), PageRequest{}.limit())
+}
@@ -1,15 +1,34 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/funding"
+	"github/kasho/backend/fx"
+	"github/kasho/backend/mail"
+	"github/kasho/backend/metrics"
+	"github/kasho/backend/throttle"
+	"github/kasho/backend/tracing"
 	"github/kasho/backend/utils"
+	"github/kasho/backend/db/migrations"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"database/sql"
 
 	"github.com/go-playground/validator/v10"
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -18,52 +37,529 @@ import (
 
 type Server struct {
 	queries *db.Queries
+	store *db.Store
 	router *gin.Engine
+	// admin is the /admin route group. Its routes are for staff and each
+	// one requires a permission.
+	admin *gin.RouterGroup
 	config *utils.Config
+	logger *slog.Logger
+	fundingProvider funding.Provider
+	fxProvider fx.Provider
+	fxPricing fx.Pricing
+	mailer mail.Mailer
+	// totpSealer seals TOTP secrets before they are stored.
+	totpSealer *utils.Sealer
+	// Failed logins are throttled per email and per client IP.
+	emailThrottle *throttle.Throttle
+	ipThrottle *throttle.Throttle
+	// schemaVersion is the newest migration built in. /readyz fails until
+	// the database has reached it.
+	schemaVersion uint
+	// draining is set once shutdown starts, so /readyz fails while in-flight
+	// requests finish.
+	draining atomic.Bool
+	// tasks counts work handlers leave running after they respond, such as
+	// sending an email. Start waits for it before closing the database.
+	tasks sync.WaitGroup
+	metrics *metrics.Metrics
+	// shutdownTracing flushes spans not exported yet.
+	shutdownTracing func(context.Context) error
 }
 
 var tokenController *utils.JWTToken
 
+// routeTimeouts overrides Request_timeout for routes expected to be slow.
+// They stay under the HTTP write timeout, so the error still reaches the
+// client.
+var routeTimeouts = map[string]time.Duration{
+	// Deposits and withdrawals wait for the funding provider.
+	"/funding/deposits":    25 * time.Second,
+	"/funding/withdrawals": 25 * time.Second,
+}
+
+// fundingResubmitAfter is how long a funding request may go without a
+// provider reference before pollFunding submits it again. It is longer than
+// fundingSubmitTimeout, so a request still being submitted by its handler
+// isn't picked up.
+const fundingResubmitAfter = time.Minute
+
+// fundingSubmitTimeout bounds how long a funding handler works with the
+// provider once the request exists, whether or not the client is still
+// waiting.
+const fundingSubmitTimeout = 30 * time.Second
+
 func NewServer(envPath string) *Server {
 	config, err := utils.LoadConfig(envPath)
 	if err != nil {
 		panic(fmt.Sprintf("Could not load config: %v", err))
 	}
 
-	conn, err := sql.Open(config.DBdriver, config.DB_source_live)
+	logger, err := utils.NewLogger(os.Stdout, config.Log_level, config.Log_format)
+	if err != nil {
+		panic(fmt.Sprintf("Could not create logger: %v", err))
+	}
+	slog.SetDefault(logger)
+
+	if config.Auto_migrate {
+		if err := autoMigrate(config, logger); err != nil {
+			panic(fmt.Sprintf("Could not migrate database: %v", err))
+		}
+	}
+
+	source, err := utils.WithStatementTimeout(config.DB_source_live, config.Db_statement_timeout)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid DB_SOURCE_LIVE: %v", err))
+	}
+
+	conn, err := sql.Open(config.DBdriver, source)
 	if err != nil {
 		panic(fmt.Sprintf("Could not connect to database: %v", err))
 	}
 
-	tokenController = utils.NewJWTToken(config)
+	tokenController, err = utils.NewJWTToken(config, logger)
+	if err != nil {
+		panic(fmt.Sprintf("Could not load JWT keys: %v", err))
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:     config.Trace_exporter,
+		OTLPEndpoint: config.Otel_exporter_otlp_endpoint,
+		SampleRatio:  config.Trace_sample_ratio,
+		Stdout:       os.Stdout,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("Could not set up tracing: %v", err))
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(conn, "kasho"),
+	)
+	serverMetrics := metrics.New(registry)
+
+	store := db.NewStore(conn, serverMetrics.WrapDBTX, tracing.WrapDBTX)
+
+	fundingProvider, err := newFundingProvider(config)
+	if err != nil {
+		panic(fmt.Sprintf("Could not create funding provider: %v", err))
+	}
+
+	if err := loadCurrencies(store, config); err != nil {
+		panic(fmt.Sprintf("Could not load currencies: %v", err))
+	}
+
+	fxProvider, err := fx.LoadFile(config.Fx_rates_file)
+	if err != nil {
+		panic(fmt.Sprintf("Could not load FX rates: %v", err))
+	}
+
+	mailer, err := newMailer(config, logger)
+	if err != nil {
+		panic(fmt.Sprintf("Could not create mailer: %v", err))
+	}
+
+	totpSealer, err := utils.NewSealer(config.Totp_encryption_key)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid TOTP_ENCRYPTION_KEY: %v", err))
+	}
+
+	throttleStore, err := newThrottleStore(config, store)
+	if err != nil {
+		panic(fmt.Sprintf("Could not create login throttle: %v", err))
+	}
+
+	schemaVersion, err := migrations.Latest()
+	if err != nil {
+		panic(fmt.Sprintf("Could not read migrations: %v", err))
+	}
 
-	q := db.New(conn)
+	g := gin.New()
 
-	g := gin.Default()
+	// The client IP keys the login throttle, so only known proxies may set
+	// it through X-Forwarded-For.
+	if err := g.SetTrustedProxies(config.Trusted_proxies); err != nil {
+		panic(fmt.Sprintf("Invalid TRUSTED_PROXIES: %v", err))
+	}
+
+	g.Use(RequestIDMiddleware(logger), TracingMiddleware(), RequestLoggerMiddleware(), MetricsMiddleware(serverMetrics), DeadlineMiddleware(config.Request_timeout, routeTimeouts), gin.CustomRecovery(recoverPanic), ErrorMiddleware())
+
+	g.HandleMethodNotAllowed = true
+	g.NoRoute(func(c *gin.Context) {
+		abortWithError(c, notFound("route not found"))
+	})
+	g.NoMethod(func(c *gin.Context) {
+		abortWithError(c, newAPIError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "method not allowed"))
+	})
 
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		v.RegisterValidation("currency", currencyValidator)
+		v.RegisterTagNameFunc(jsonTagName)
 	}
 
 	g.Use(cors.Default())
 
 	return &Server	{
-		queries: q,
+		queries: store.Queries,
+		store: store,
 		router: g,
 		config: config,
+		logger: logger,
+		fundingProvider: fundingProvider,
+		fxProvider: fxProvider,
+		fxPricing: fx.Pricing{SpreadBps: config.Fx_spread_bps, FeeBps: config.Fx_fee_bps},
+		mailer: mailer,
+		totpSealer: totpSealer,
+		emailThrottle: throttle.New(throttleStore, throttle.Policy{
+			FreeAttempts: config.Login_free_attempts,
+			BaseDelay: config.Login_backoff_base,
+			MaxDelay: config.Login_backoff_max,
+			MaxFailures: config.Login_max_failures,
+			LockoutDuration: config.Login_lockout_duration,
+			Window: config.Login_failure_window,
+		}),
+		// Many users can share an IP, so it only locks, and much later.
+		ipThrottle: throttle.New(throttleStore, throttle.Policy{
+			FreeAttempts: config.Login_ip_max_failures,
+			MaxFailures: config.Login_ip_max_failures,
+			LockoutDuration: config.Login_lockout_duration,
+			Window: config.Login_failure_window,
+		}),
+		schemaVersion: schemaVersion,
+		metrics: serverMetrics,
+		shutdownTracing: shutdownTracing,
 	}
 }
 
-func (s *Server) Start(port int) {
+// Start serves the API on port until the process gets SIGINT or SIGTERM.
+// It then stops taking connections, lets in-flight requests and background
+// jobs finish within Shutdown_timeout, and closes the database.
+func (s *Server) Start(port int) error {
 	s.router.GET("/", func(ctx *gin.Context) {
 		ctx.JSON(http.StatusOK, gin.H{"message": "Welcome to Kasho!"})
 	})
 
+	s.admin = s.router.Group("/admin", AuthenticatedMiddleware(s.queries))
+
+	Health{}.router(s)
 	User{}.router(s)
 	Auth{}.router(s)
+	TwoFactor{}.router(s)
 	Account{}.router(s)
+	Transfer{}.router(s)
+	Funding{}.router(s)
+	FX{}.router(s)
+	Currency{}.router(s)
+	Audit{}.router(s)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var jobs sync.WaitGroup
+	every(ctx, &jobs, s.config.Idempotency_purge_interval, s.purgeIdempotencyKeys)
+	every(ctx, &jobs, s.config.Login_failure_window, s.purgeLoginAttempts)
+	every(ctx, &jobs, s.config.Funding_poll_interval, s.pollFunding)
+	every(ctx, &jobs, s.config.Currencies_refresh_interval, s.refreshCurrencies)
+
+	httpServer := &http.Server{
+		Addr:         fmt.Sprintf(":%v", port),
+		Handler:      s.router,
+		ReadTimeout:  s.config.Http_read_timeout,
+		WriteTimeout: s.config.Http_write_timeout,
+		IdleTimeout:  s.config.Http_idle_timeout,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	s.logger.Info("server started", "port", port)
+
+	select {
+	case err := <-serveErr:
+		stop()
+		jobs.Wait()
+		s.tasks.Wait()
+		s.store.Close()
+		s.shutdownTracing(context.Background())
+		return err
+	case <-ctx.Done():
+	}
+
+	// A second signal kills the process instead of waiting for the drain.
+	stop()
+	s.draining.Store(true)
+	s.logger.Info("shutting down", "timeout", s.config.Shutdown_timeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.Shutdown_timeout)
+	defer cancel()
+
+	err := httpServer.Shutdown(shutdownCtx)
+	if err != nil {
+		s.logger.Error("requests still running at shutdown timeout", "error", err)
+	}
+
+	jobs.Wait()
+	s.tasks.Wait()
+
+	if closeErr := s.store.Close(); closeErr != nil {
+		s.logger.Error("failed to close database", "error", closeErr)
+	}
+
+	if traceErr := s.shutdownTracing(shutdownCtx); traceErr != nil {
+		s.logger.Error("failed to flush traces", "error", traceErr)
+	}
+
+	s.logger.Info("server stopped")
+	return err
+}
+
+// background runs task in its own goroutine, counted in s.tasks, for work
+// that goes on after the response has been sent.
+func (s *Server) background(task func()) {
+	s.tasks.Add(1)
+	go func() {
+		defer s.tasks.Done()
+		task()
+	}()
+}
+
+// every runs job every interval until ctx is done. A run in progress is
+// not interrupted; jobs is done once it returns.
+func every(ctx context.Context, jobs *sync.WaitGroup, interval time.Duration, job func()) {
+	jobs.Add(1)
+
+	go func() {
+		defer jobs.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				job()
+			}
+		}
+	}()
+}
+
+// idempotency returns the Idempotency-Key middleware for mutating routes.
+func (s *Server) idempotency() gin.HandlerFunc {
+	return IdempotencyMiddleware(s.queries, s.config.Idempotency_key_ttl)
+}
+
+// purgeIdempotencyKeys deletes idempotency keys that are past their
+// retention window.
+func (s *Server) purgeIdempotencyKeys() {
+	expiredBefore := time.Now().Add(-s.config.Idempotency_key_ttl)
+
+	n, err := s.queries.DeleteExpiredIdempotencyKeys(context.Background(), expiredBefore)
+	if err != nil {
+		s.logger.Error("failed to purge idempotency keys", "error", err)
+		return
+	}
+
+	if n > 0 {
+		s.logger.Info("purged expired idempotency keys", "count", n)
+	}
+}
+
+// purgeLoginAttempts deletes failed logins that no longer count, so
+// attempts against many emails don't pile up.
+func (s *Server) purgeLoginAttempts() {
+	n, err := s.queries.DeleteStaleLoginAttempts(context.Background(), time.Now().Add(-s.config.Login_failure_window))
+	if err != nil {
+		s.logger.Error("failed to purge login attempts", "error", err)
+		return
+	}
+
+	if n > 0 {
+		s.logger.Info("purged stale login attempts", "count", n)
+	}
+}
+
+// autoMigrate applies pending migrations before the server touches the
+// database. It uses its own connection, without the statement timeout, as
+// migrations can run longer than any query should. The advisory lock held
+// by the migrator lets several replicas start at once.
+func autoMigrate(config *utils.Config, logger *slog.Logger) error {
+	conn, err := sql.Open(config.DBdriver, config.DB_source_live)
+	if err != nil {
+		return err
+	}
+
+	version, err := migrations.Up(conn, logger)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("database migrated", "version", version)
+	return nil
+}
+
+// loadCurrencies fills utils.Currencies from the currencies table, after
+// writing the currencies managed in config to it.
+func loadCurrencies(store *db.Store, config *utils.Config) error {
+	ctx := context.Background()
+
+	if config.Currencies_file != "" {
+		currencies, err := utils.LoadCurrencyFile(config.Currencies_file)
+		if err != nil {
+			return err
+		}
+		if err := store.SyncCurrencies(ctx, currencies); err != nil {
+			return err
+		}
+	}
+
+	currencies, err := store.LoadCurrencies(ctx)
+	if err != nil {
+		return err
+	}
+
+	utils.Currencies.Replace(currencies)
+	return nil
+}
+
+// refreshCurrencies reloads the currency registry.
+func (s *Server) refreshCurrencies() {
+	currencies, err := s.store.LoadCurrencies(context.Background())
+	if err != nil {
+		s.logger.Error("failed to reload currencies", "error", err)
+		return
+	}
+
+	utils.Currencies.Replace(currencies)
+}
+
+func newFundingProvider(config *utils.Config) (funding.Provider, error) {
+	switch config.Funding_provider {
+	case "fake":
+		return funding.NewFake(config.Funding_fake_settle_after), nil
+	}
+
+	return nil, fmt.Errorf("unknown FUNDING_PROVIDER %q", config.Funding_provider)
+}
+
+func newThrottleStore(config *utils.Config, store *db.Store) (throttle.Store, error) {
+	switch config.Login_throttle_store {
+	case "postgres":
+		return throttle.Postgres{Queries: store.Queries}, nil
+	case "memory":
+		return throttle.NewMemory(), nil
+	}
+
+	return nil, fmt.Errorf("unknown LOGIN_THROTTLE_STORE %q", config.Login_throttle_store)
+}
+
+func newMailer(config *utils.Config, logger *slog.Logger) (mail.Mailer, error) {
+	switch config.Mailer {
+	case "log":
+		return mail.Log{Logger: logger}, nil
+	case "file":
+		return mail.NewFile(config.Mail_dir)
+	}
+
+	return nil, fmt.Errorf("unknown MAILER %q", config.Mailer)
+}
+
+// pollFunding asks the funding provider about requests it left pending and
+// settles or fails them accordingly. Requests the provider never answered
+// are submitted again once fundingResubmitAfter has passed.
+func (s *Server) pollFunding() {
+	ctx := context.Background()
+
+	pending, err := s.queries.ListPendingFundingRequests(ctx, db.ListPendingFundingRequestsParams{
+		UnsubmittedBefore: time.Now().Add(-fundingResubmitAfter),
+		PageLimit:         100,
+	})
+	if err != nil {
+		s.logger.Error("failed to list pending funding requests", "error", err)
+		return
+	}
+
+	for _, request := range pending {
+		var result funding.Result
+		if request.ProviderReference.Valid {
+			result, err = s.fundingProvider.Status(ctx, request.ProviderReference.String)
+		} else {
+			result, err = s.resubmitFunding(ctx, request)
+		}
+		if err != nil {
+			s.logger.Warn("funding provider status failed", "funding_id", request.ID, "error", err)
+			continue
+		}
+
+		updated, err := s.applyFundingResult(ctx, request, result)
+		if err != nil && !errors.Is(err, db.ErrFundingNotPending) {
+			s.logger.Error("failed to apply funding result", "funding_id", request.ID, "error", err)
+			continue
+		}
+
+		if updated.Status != db.FundingStatusPending {
+			s.logger.Info("funding request finished", "funding_id", request.ID, "status", updated.Status)
+		}
+	}
+}
+
+// resubmitFunding submits a request again whose first answer was lost. The
+// provider recognises it by its ID, so this never moves money twice. A
+// rejection fails the request, returning any held funds.
+func (s *Server) resubmitFunding(ctx context.Context, request db.FundingRequest) (funding.Result, error) {
+	result, err := s.fundingProvider.Submit(ctx, funding.Request{
+		ID:        request.ID,
+		AccountID: request.AccountID,
+		Direction: funding.Direction(request.Direction),
+		Amount:    utils.NewMoney(request.Amount, request.Currency),
+	})
+	if errors.Is(err, funding.ErrRejected) {
+		return funding.Result{Status: funding.StatusFailed, FailureReason: "rejected by provider"}, nil
+	}
+
+	return result, err
+}
+
+// applyFundingResult moves a funding request to the state its provider
+// reported. Pending results only record the provider reference.
+func (s *Server) applyFundingResult(ctx context.Context, request db.FundingRequest, result funding.Result) (db.FundingRequest, error) {
+	var err error
+
+	if !request.ProviderReference.Valid && result.Reference != "" {
+		before := request
+
+		err = s.store.AuditTx(ctx, func(q *db.Queries) (db.AuditRecord, error) {
+			var err error
+			request, err = q.SetFundingRequestReference(ctx, db.SetFundingRequestReferenceParams{
+				ID:                before.ID,
+				ProviderReference: sql.NullString{String: result.Reference, Valid: true},
+			})
+			return db.AuditRecord{
+				Action:     "funding.reference",
+				TargetType: "funding_request",
+				TargetID:   strconv.FormatInt(before.ID, 10),
+				Before:     before,
+				After:      request,
+			}, err
+		})
+		if err != nil {
+			return request, err
+		}
+	}
+
+	switch result.Status {
+	case funding.StatusSettled:
+		return s.store.SettleFundingTx(ctx, request.ID)
+	case funding.StatusFailed:
+		return s.store.FailFundingTx(ctx, request.ID, result.FailureReason)
+	}
 
-	s.router.Run(fmt.Sprintf(":%v", port))
+	return request, nil
 }
 
 // NewServer (OLD)
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDB connects to the test database (DB_SOURCE). Tests that need it
+// are skipped when it can't be reached, so the rest of the package runs
+// without Postgres.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	config, err := utils.LoadConfig("..")
+	require.NoError(t, err)
+
+	conn, err := sql.Open(config.DBdriver, config.DB_source)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := conn.PingContext(ctx); err != nil {
+		t.Skipf("test database unavailable: %v", err)
+	}
+
+	return conn
+}
+
+func newTestStore(t *testing.T) *db.Store {
+	return db.NewStore(newTestDB(t))
+}
+
+func createTestUser(t *testing.T, store *db.Store) db.User {
+	t.Helper()
+
+	user, err := store.CreateUser(context.Background(), db.CreateUserParams{
+		Email:          utils.RandomEmail(),
+		HashedPassword: utils.RandomString(60),
+	})
+	require.NoError(t, err)
+
+	return user
+}
@@ -0,0 +1,347 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Transfer struct {
+	server *Server
+}
+
+func (t Transfer) router(server *Server) {
+	t.server = server
+
+	serverGroup := server.router.Group("/transfers", AuthenticatedMiddleware(server.queries))
+	serverGroup.GET("", t.listTransfers)
+
+	verified := serverGroup.Group("", RequireVerifiedEmail(server.queries), server.idempotency())
+	verified.POST("", t.createTransfer)
+
+	adminGroup := server.admin.Group("/transfers", RequirePermission(db.PermissionTransfersRead))
+	adminGroup.GET("", t.adminListTransfers)
+	adminGroup.GET(":id", t.adminGetTransfer)
+}
+
+type TransferRequest struct {
+	FromAccountID int64  `json:"from_account_id" binding:"required,min=1"`
+	ToAccountID   int64  `json:"to_account_id" binding:"required,min=1,nefield=FromAccountID"`
+	Amount        string `json:"amount" binding:"required"`
+	Currency      string `json:"currency" binding:"required,currency"`
+}
+
+type TransferResultResponse struct {
+	Transfer    *TransferResponse `json:"transfer"`
+	FromAccount *AccountResponse  `json:"from_account"`
+	FromEntry   *EntryResponse    `json:"from_entry"`
+}
+
+func (t *Transfer) createTransfer(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var req TransferRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	amount, err := parseAmount(req.Amount, req.Currency)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	fromAccount, ok := t.validAccount(c, req.FromAccountID, req.Currency)
+	if !ok {
+		return
+	}
+
+	if fromAccount.UserID != accountOwner(userId) {
+		abortWithError(c, forbidden("from account does not belong to the authenticated user"))
+		return
+	}
+
+	if _, ok := t.validAccount(c, req.ToAccountID, req.Currency); !ok {
+		return
+	}
+
+	if err := t.server.requireStepUp(c, amount); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	arg := db.TransferTxParams{
+		FromAccountID: req.FromAccountID,
+		ToAccountID:   req.ToAccountID,
+		Amount:        amount,
+	}
+
+	result, err := t.server.store.TransferTx(auditContext(c), arg)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	requestLogger(c).Info("transfer created",
+		"transfer_id", result.Transfer.ID,
+		"from_account_id", req.FromAccountID,
+		"to_account_id", req.ToAccountID,
+		"amount", amount.String(),
+	)
+	t.server.metrics.Transferred(amount)
+
+	c.JSON(http.StatusCreated, TransferResultResponse{
+		Transfer:    TransferResponse{}.toTransferResponse(&result.Transfer, amount.Currency),
+		FromAccount: AccountResponse{}.toAccountResponse(&result.FromAccount),
+		FromEntry:   EntryResponse{}.toEntryResponse(&result.FromEntry, amount.Currency),
+	})
+}
+
+type ListTransfersRequest struct {
+	PageRequest
+	DateRange
+	AmountRange
+	AccountID int64  `form:"account_id" binding:"omitempty,min=1"`
+	Currency  string `form:"currency" binding:"omitempty,currency"`
+}
+
+// listTransfers pages through transfers into or out of the caller's
+// accounts, optionally narrowed to one account or one currency.
+func (t *Transfer) listTransfers(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var req ListTransfersRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	accounts, err := t.server.queries.GetAccountByUserID(c.Request.Context(), accountOwner(userId))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	currencies := map[int32]string{}
+	accountIDs := []int32{}
+	currency := req.Currency
+
+	for _, account := range accounts {
+		if req.AccountID != 0 && account.ID != req.AccountID {
+			continue
+		}
+		if req.AccountID != 0 && currency == "" {
+			currency = account.Currency
+		}
+		if currency != "" && account.Currency != currency {
+			continue
+		}
+		currencies[int32(account.ID)] = account.Currency
+		accountIDs = append(accountIDs, int32(account.ID))
+	}
+
+	if req.AccountID != 0 && len(accountIDs) == 0 {
+		abortWithError(c, notFound(fmt.Sprintf("account %d not found", req.AccountID)))
+		return
+	}
+
+	if req.isSet() && currency == "" {
+		abortWithError(c, missingCurrency("min_amount and max_amount need a currency or an account_id"))
+		return
+	}
+
+	minAmount, maxAmount, err := req.bounds(currency)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	beforeID, err := req.beforeID()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	transfers, err := t.server.queries.ListTransfersByAccountIDs(c.Request.Context(), db.ListTransfersByAccountIDsParams{
+		AccountIds:  accountIDs,
+		BeforeID:    beforeID,
+		CreatedFrom: req.from(),
+		CreatedTo:   req.to(),
+		MinAmount:   minAmount,
+		MaxAmount:   maxAmount,
+		PageLimit:   req.queryLimit(),
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPage(transfers, req.PageRequest, func(v *db.Transfer) int64 { return v.ID }, func(v *db.Transfer) *TransferResponse {
+		currency, ok := currencies[v.FromAccountID]
+		if !ok {
+			currency = currencies[v.ToAccountID]
+		}
+		return TransferResponse{}.toTransferResponse(v, currency)
+	}))
+}
+
+type AdminListTransfersRequest struct {
+	PageRequest
+	DateRange
+	AccountID int64  `form:"account_id" binding:"omitempty,min=1"`
+	Currency  string `form:"currency" binding:"omitempty,len=3"`
+}
+
+// adminListTransfers pages through the transfers of every user.
+func (t *Transfer) adminListTransfers(c *gin.Context) {
+	var req AdminListTransfersRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	beforeID, err := req.beforeID()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var accountID sql.NullInt32
+	if req.AccountID != 0 {
+		accountID = sql.NullInt32{Int32: int32(req.AccountID), Valid: true}
+	}
+
+	transfers, err := t.server.queries.ListTransfers(c.Request.Context(), db.ListTransfersParams{
+		BeforeID:    beforeID,
+		AccountID:   accountID,
+		Currency:    nullString(strings.ToUpper(req.Currency)),
+		CreatedFrom: req.from(),
+		CreatedTo:   req.to(),
+		PageLimit:   req.queryLimit(),
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPage(transfers, req.PageRequest, func(v *db.ListTransfersRow) int64 { return v.ID }, func(v *db.ListTransfersRow) *TransferResponse {
+		return TransferResponse{}.toTransferResponse(&db.Transfer{
+			ID:            v.ID,
+			FromAccountID: v.FromAccountID,
+			ToAccountID:   v.ToAccountID,
+			Amount:        v.Amount,
+			CreatedAt:     v.CreatedAt,
+			JournalID:     v.JournalID,
+		}, v.Currency)
+	}))
+}
+
+type TransferURI struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func (t *Transfer) adminGetTransfer(c *gin.Context) {
+	var uri TransferURI
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	transfer, err := t.server.queries.GetTransferByID(c.Request.Context(), uri.ID)
+	if err == sql.ErrNoRows {
+		abortWithError(c, notFound(fmt.Sprintf("transfer %d not found", uri.ID)))
+		return
+	}
+
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	account, err := t.server.queries.GetAccountByID(c.Request.Context(), int64(transfer.FromAccountID))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, TransferResponse{}.toTransferResponse(&transfer, account.Currency))
+}
+
+// validAccount checks that the account exists and is held in the given currency.
+// It writes the error response itself and reports whether the caller may continue.
+func (t *Transfer) validAccount(c *gin.Context, accountID int64, currency string) (db.Account, bool) {
+	account, err := t.server.queries.GetAccountByID(c.Request.Context(), accountID)
+	if err == sql.ErrNoRows || (err == nil && account.Kind != db.AccountKindUser) {
+		abortWithError(c, notFound(fmt.Sprintf("account %d not found", accountID)))
+		return account, false
+	}
+
+	if err != nil {
+		abortWithError(c, err)
+		return account, false
+	}
+
+	if account.Currency != currency {
+		abortWithError(c, newAPIError(http.StatusUnprocessableEntity, CodeCurrencyMismatch, fmt.Sprintf("account %d is held in %s, not %s", accountID, account.Currency, currency)))
+		return account, false
+	}
+
+	return account, true
+}
+
+type TransferResponse struct {
+	ID            int64       `json:"id"`
+	FromAccountID int32       `json:"from_account_id"`
+	ToAccountID   int32       `json:"to_account_id"`
+	Amount        utils.Money `json:"amount"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+// Transfers only move money between accounts of the same currency, so the
+// currency of either account is the currency of the transfer.
+func (t TransferResponse) toTransferResponse(transfer *db.Transfer, currency string) *TransferResponse {
+	return &TransferResponse{
+		ID:            transfer.ID,
+		FromAccountID: transfer.FromAccountID,
+		ToAccountID:   transfer.ToAccountID,
+		Amount:        utils.NewMoney(transfer.Amount, currency),
+		CreatedAt:     transfer.CreatedAt,
+	}
+}
+
+type EntryResponse struct {
+	ID        int64       `json:"id"`
+	AccountID int32       `json:"account_id"`
+	Amount    utils.Money `json:"amount"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+func (e EntryResponse) toEntryResponse(entry *db.Entry, currency string) *EntryResponse {
+	return &EntryResponse{
+		ID:        entry.ID,
+		AccountID: entry.AccountID,
+		Amount:    utils.NewMoney(entry.Amount, currency),
+		Type:      entry.Type,
+		CreatedAt: entry.CreatedAt,
+	}
+}
@@ -0,0 +1,317 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	totpIssuer = "Kasho"
+	// recoveryCodeCount is how many recovery codes are issued at a time.
+	recoveryCodeCount = 10
+)
+
+type TwoFactor struct {
+	server *Server
+}
+
+func (t TwoFactor) router(server *Server) {
+	t.server = server
+
+	serverGroup := server.router.Group("/auth/2fa", AuthenticatedMiddleware(server.queries))
+	serverGroup.GET("", t.getTwoFactor)
+	serverGroup.POST("totp", t.enrolTOTP)
+	serverGroup.POST("totp/confirm", t.confirmTOTP)
+	serverGroup.POST("totp/disable", t.disableTOTP)
+	serverGroup.POST("recovery-codes", t.regenerateRecoveryCodes)
+	serverGroup.POST("step-up", t.stepUp)
+}
+
+// SecondFactorRequest carries a code from the authenticator app or, when
+// that is lost, one of the recovery codes.
+type SecondFactorRequest struct {
+	Code         string `json:"code" binding:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code" binding:"required_without=Code"`
+}
+
+// secondFactor turns the request into what the store verifies. The TOTP
+// secret is only opened inside the check, while its row is locked.
+func (s *Server) secondFactor(c *gin.Context, req SecondFactorRequest) db.SecondFactor {
+	if req.RecoveryCode != "" {
+		return db.SecondFactor{RecoveryCodeHash: utils.HashRecoveryCode(req.RecoveryCode)}
+	}
+
+	return db.SecondFactor{TOTP: s.totpCheck(c, req.Code)}
+}
+
+func (s *Server) totpCheck(c *gin.Context, code string) db.TOTPCheck {
+	return func(sealedSecret string, lastUsedStep int64) (int64, bool) {
+		secret, err := s.totpSealer.Open(sealedSecret)
+		if err != nil {
+			requestLogger(c).Error("failed to open TOTP secret", "error", err)
+			return 0, false
+		}
+
+		return utils.ValidateTOTP(secret, code, time.Now(), lastUsedStep)
+	}
+}
+
+// twoFactorEnabled reports whether the user has a confirmed authenticator.
+func (s *Server) twoFactorEnabled(ctx context.Context, userID int64) (bool, error) {
+	credential, err := s.queries.GetTOTPCredential(ctx, userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return credential.ConfirmedAt.Valid, nil
+}
+
+type TwoFactorResponse struct {
+	Enabled           bool  `json:"enabled"`
+	RecoveryCodesLeft int64 `json:"recovery_codes_left"`
+}
+
+func (t *TwoFactor) getTwoFactor(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	enabled, err := t.server.twoFactorEnabled(c.Request.Context(), userId)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	left, err := t.server.queries.CountUnusedRecoveryCodes(c.Request.Context(), userId)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, TwoFactorResponse{Enabled: enabled, RecoveryCodesLeft: left})
+}
+
+type TOTPEnrolmentResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// enrolTOTP generates a secret for the user's authenticator app. Two-factor
+// authentication stays off until a code from the app is confirmed.
+func (t *TwoFactor) enrolTOTP(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	user, err := t.server.queries.GetUserByID(c.Request.Context(), userId)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	secret, err := utils.NewTOTPSecret()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	sealed, err := t.server.totpSealer.Seal(secret)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if err := t.server.store.EnrolTOTP(c.Request.Context(), userId, sealed); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, TOTPEnrolmentResponse{
+		Secret: secret,
+		URI:    utils.TOTPURI(totpIssuer, user.Email, secret),
+	})
+}
+
+type ConfirmTOTPRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// confirmTOTP turns two-factor authentication on and returns the recovery
+// codes. They are shown this once; only their hashes are kept.
+func (t *TwoFactor) confirmTOTP(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var req ConfirmTOTPRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	codes, hashes, err := utils.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	err = t.server.store.ConfirmTOTPTx(auditContext(c), db.ConfirmTOTPParams{
+		UserID:             userId,
+		TOTP:               t.server.totpCheck(c, req.Code),
+		RecoveryCodeHashes: hashes,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	requestLogger(c).Info("two-factor authentication enabled", "user_id", userId)
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (t *TwoFactor) disableTOTP(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var req SecondFactorRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if err := t.server.store.DisableTOTPTx(auditContext(c), userId, t.server.secondFactor(c, req)); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	requestLogger(c).Info("two-factor authentication disabled", "user_id", userId)
+
+	c.Status(http.StatusNoContent)
+}
+
+// regenerateRecoveryCodes replaces every recovery code, for when they run
+// low or may have been seen by someone else.
+func (t *TwoFactor) regenerateRecoveryCodes(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var req SecondFactorRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	codes, hashes, err := utils.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	err = t.server.store.RegenerateRecoveryCodesTx(auditContext(c), db.RecoveryCodesParams{
+		UserID:             userId,
+		Factor:             t.server.secondFactor(c, req),
+		RecoveryCodeHashes: hashes,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+type StepUpResponse struct {
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// stepUp checks a second factor for the current session, which lets it
+// make sensitive requests until the step-up expires.
+func (t *TwoFactor) stepUp(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var req SecondFactorRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	err = t.server.store.StepUpTx(auditContext(c), db.StepUpParams{
+		UserID:    userId,
+		SessionID: c.GetInt64("session_id"),
+		Factor:    t.server.secondFactor(c, req),
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, StepUpResponse{ExpiresAt: time.Now().Add(t.server.config.Step_up_ttl)})
+}
+
+// requireStepUp makes a movement of amount wait for a recent two-factor
+// check when it reaches the step-up amount of its currency. Users without
+// two-factor authentication are told to enable it, as they can't step up.
+func (s *Server) requireStepUp(c *gin.Context, amount utils.Money) error {
+	currency, ok := utils.Currencies.Lookup(amount.Currency)
+	if !ok || currency.StepUpAmount == 0 || amount.Amount < currency.StepUpAmount {
+		return nil
+	}
+
+	session, err := s.queries.GetSessionByID(c.Request.Context(), c.GetInt64("session_id"))
+	if err != nil {
+		return err
+	}
+
+	if session.StepUpAt.Valid && time.Since(session.StepUpAt.Time) < s.config.Step_up_ttl {
+		return nil
+	}
+
+	threshold := utils.NewMoney(currency.StepUpAmount, currency.Code)
+
+	enabled, err := s.twoFactorEnabled(c.Request.Context(), c.GetInt64("user_id"))
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return newAPIError(http.StatusForbidden, CodeTwoFactorRequired,
+			fmt.Sprintf("amounts of %s %s or more need two-factor authentication, enable it to send them", threshold, currency.Code))
+	}
+
+	return newAPIError(http.StatusForbidden, CodeStepUpRequired,
+		fmt.Sprintf("amounts of %s %s or more need a recent two-factor check", threshold, currency.Code))
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireStepUp(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := newTestStore(t)
+	user := createTestUser(t, store)
+	ctx := context.Background()
+
+	_, hash, err := utils.NewRefreshToken()
+	require.NoError(t, err)
+
+	session, err := store.CreateSessionTx(ctx, db.CreateSessionTxParams{
+		UserID:           user.ID,
+		RefreshTokenHash: hash,
+		ExpiresAt:        time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	usd, ok := utils.Currencies.Lookup("USD")
+	require.True(t, ok)
+	t.Cleanup(func() { utils.Currencies.Set(usd) })
+
+	stepUp := usd
+	stepUp.StepUpAmount = 5000
+	utils.Currencies.Set(stepUp)
+
+	server := &Server{queries: store.Queries, config: &utils.Config{Step_up_ttl: 5 * time.Minute}}
+
+	requireStepUp := func(amount utils.Amount) error {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/transfers", nil)
+		c.Set("user_id", user.ID)
+		c.Set("session_id", session.Session.ID)
+		return server.requireStepUp(c, utils.NewMoney(amount, "USD"))
+	}
+
+	code := func(err error) string {
+		var apiErr *APIError
+		require.True(t, errors.As(err, &apiErr), "got %v", err)
+		assert.Equal(t, http.StatusForbidden, apiErr.Status)
+		return apiErr.Code
+	}
+
+	require.NoError(t, requireStepUp(4999))
+
+	// Without an authenticator there is nothing to step up with.
+	assert.Equal(t, CodeTwoFactorRequired, code(requireStepUp(5000)))
+
+	_, err = store.CreatePendingTOTPCredential(ctx, db.CreatePendingTOTPCredentialParams{
+		UserID: user.ID,
+		Secret: utils.RandomString(32),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, CodeTwoFactorRequired, code(requireStepUp(5000)), "an unconfirmed authenticator doesn't count")
+
+	require.NoError(t, store.ConfirmTOTPCredential(ctx, db.ConfirmTOTPCredentialParams{UserID: user.ID}))
+	assert.Equal(t, CodeStepUpRequired, code(requireStepUp(5000)))
+
+	require.NoError(t, store.SetSessionStepUp(ctx, session.Session.ID))
+	assert.NoError(t, requireStepUp(5000))
+}
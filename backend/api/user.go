@@ -1,9 +1,10 @@
 package api
 
 import (
-	"context"
 	"database/sql"
+	"fmt"
 	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
 	"net/http"
 	"time"
 
@@ -17,65 +18,232 @@ type User struct {
 func (u User) router(server *Server) {
 	u.server = server
 
-	serverGroup := server.router.Group("/users", AuthenticatedMiddleware())
-	serverGroup.GET("", u.listUsers)
+	serverGroup := server.router.Group("/users", AuthenticatedMiddleware(server.queries))
 	serverGroup.GET("me", u.getLoggedInUser)
+
+	adminGroup := server.admin.Group("/users")
+	adminGroup.GET("", RequirePermission(db.PermissionUsersRead), u.listUsers)
+	adminGroup.GET(":id", RequirePermission(db.PermissionUsersRead), u.getUser)
+	adminGroup.POST(":id/roles", RequirePermission(db.PermissionRolesManage), u.grantRole)
+	adminGroup.DELETE(":id/roles/:role", RequirePermission(db.PermissionRolesManage), u.revokeRole)
+
+	server.admin.GET("/roles", RequirePermission(db.PermissionRolesManage), u.listRoles)
+}
+
+type ListUsersRequest struct {
+	PageRequest
+	DateRange
 }
 
 func (u *User) listUsers(c *gin.Context) {
+	var req ListUsersRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	beforeID, err := req.beforeID()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
 	arg := db.ListUsersParams{
-		Offset: 0,
-		Limit: 10,
+		BeforeID:    beforeID,
+		CreatedFrom: req.from(),
+		CreatedTo:   req.to(),
+		PageLimit:   req.queryLimit(),
 	}
 
-	users, err := u.server.queries.ListUsers(context.Background(), arg)
+	users, err := u.server.queries.ListUsers(c.Request.Context(), arg)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
 		return
 	}
 
-	newUsers := []UserResponse{}
+	c.JSON(http.StatusOK, newPage(users, req.PageRequest, func(v *db.User) int64 { return v.ID }, UserResponse{}.toUserResponse))
+}
+
+func (u *User) getLoggedInUser(c *gin.Context) {
+	userId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
 
-	for _, v := range users {
-		n := UserResponse{}.toUserResponse(&v)
-		newUsers = append(newUsers, *n)
+	user, err := u.server.queries.GetUserByID(c.Request.Context(), userId)
+
+	if err == sql.ErrNoRows {
+		abortWithError(c, utils.ErrUnauthenticated)
+		return
+	}
+
+	if err != nil {
+		abortWithError(c, err)
+		return
 	}
 
-	c.JSON(http.StatusOK, newUsers)
+	u.respondWithRoles(c, &user)
 }
 
-func (u *User) getLoggedInUser(c *gin.Context) {
-	value, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+type UserURI struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func (u *User) getUser(c *gin.Context) {
+	var uri UserURI
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		abortWithError(c, err)
 		return
 	}
 
-	userId, ok := value.(int64)
+	user, ok := u.findUser(c, uri.ID)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	user, err := u.server.queries.GetUserByID(context.Background(), userId)
+	u.respondWithRoles(c, &user)
+}
+
+type GrantRoleRequest struct {
+	Role string `json:"role" binding:"required,max=32"`
+}
 
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+func (u *User) grantRole(c *gin.Context) {
+	actorId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var uri UserURI
+	var req GrantRoleRequest
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	user, ok := u.findUser(c, uri.ID)
+	if !ok {
+		return
+	}
+
+	_, err = u.server.store.GrantRoleTx(auditContext(c), db.UserRoleParams{
+		UserID:  user.ID,
+		Role:    req.Role,
+		ActorID: sql.NullInt64{Int64: actorId, Valid: true},
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	requestLogger(c).Info("role granted", "user_id", user.ID, "role", req.Role, "actor_id", actorId)
+
+	u.respondWithRoles(c, &user)
+}
+
+type RevokeRoleURI struct {
+	ID   int64  `uri:"id" binding:"required,min=1"`
+	Role string `uri:"role" binding:"required,max=32"`
+}
+
+// revokeRole takes a role away from a user. Their sessions are revoked with
+// it, so they have to log in again.
+func (u *User) revokeRole(c *gin.Context) {
+	actorId, err := utils.GetActiveUser(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var uri RevokeRoleURI
+
+	if err := c.ShouldBindUri(&uri); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	user, ok := u.findUser(c, uri.ID)
+	if !ok {
+		return
+	}
+
+	err = u.server.store.RevokeRoleTx(auditContext(c), db.UserRoleParams{
+		UserID:  user.ID,
+		Role:    uri.Role,
+		ActorID: sql.NullInt64{Int64: actorId, Valid: true},
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	requestLogger(c).Info("role revoked", "user_id", user.ID, "role", uri.Role, "actor_id", actorId)
+
+	u.respondWithRoles(c, &user)
+}
+
+func (u *User) listRoles(c *gin.Context) {
+	roles, err := u.server.store.RolesWithPermissions(c.Request.Context())
+	if err != nil {
+		abortWithError(c, err)
 		return
 	}
 
+	response := make([]*RoleResponse, 0, len(roles))
+	for i := range roles {
+		response = append(response, RoleResponse{}.toRoleResponse(&roles[i]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// findUser loads a user by id, writing a 404 when there is none.
+func (u *User) findUser(c *gin.Context, id int64) (db.User, bool) {
+	user, err := u.server.queries.GetUserByID(c.Request.Context(), id)
+	if err == sql.ErrNoRows {
+		abortWithError(c, notFound(fmt.Sprintf("user %d not found", id)))
+		return user, false
+	}
+
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		abortWithError(c, err)
+		return user, false
+	}
+
+	return user, true
+}
+
+func (u *User) respondWithRoles(c *gin.Context, user *db.User) {
+	access, err := u.server.store.UserAccess(c.Request.Context(), user.ID)
+	if err != nil {
+		abortWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, UserResponse{}.toUserResponse(&user))
+	response := UserResponse{}.toUserResponse(user)
+	response.Roles = access.Roles
+	response.Permissions = access.Permissions
+
+	c.JSON(http.StatusOK, response)
 }
 
 type UserResponse struct {
 	ID int64 `json:"id"`
 	Email string `json:"email"`
+	EmailVerified bool `json:"email_verified"`
+	Roles []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -84,7 +252,22 @@ func (u UserResponse) toUserResponse(user *db.User) *UserResponse {
 	return &UserResponse{
 		ID: user.ID,
 		Email: user.Email,
+		EmailVerified: user.EmailVerifiedAt.Valid,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
 	}
-}
\ No newline at end of file
+}
+
+type RoleResponse struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+func (r RoleResponse) toRoleResponse(role *db.RoleWithPermissions) *RoleResponse {
+	return &RoleResponse{
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.Permissions,
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"github/kasho/backend/utils"
+	"net/http"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -11,4 +12,19 @@ var currencyValidator validator.Func = func(fl validator.FieldLevel) bool {
 		return utils.IsSupportedCurrency(currency)
 	}
 	return false
-}
\ No newline at end of file
+}
+
+// parseAmount reads the amount of a money movement: a positive decimal
+// string within the limits of its currency.
+func parseAmount(value string, currency string) (utils.Money, error) {
+	amount, err := utils.ParseMoney(value, currency)
+	if err != nil {
+		return amount, err
+	}
+
+	if !amount.IsPositive() {
+		return amount, newAPIError(http.StatusBadRequest, CodeInvalidAmount, "amount must be greater than zero")
+	}
+
+	return amount, utils.CheckAmountLimits(amount)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+
+	_ "github.com/lib/pq"
+)
+
+// auditVerify walks the audit log and reports every event whose hash does
+// not follow from the one before it. It exits 1 when the chain is broken.
+func auditVerify() int {
+	config, err := utils.LoadConfig(".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not load config: %v\n", err)
+		return 2
+	}
+
+	conn, err := sql.Open(config.DBdriver, config.DB_source_live)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not connect to database: %v\n", err)
+		return 2
+	}
+	defer conn.Close()
+
+	report, err := db.NewStore(conn).VerifyAuditChain(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not verify the audit log: %v\n", err)
+		return 2
+	}
+
+	for _, b := range report.Breaks {
+		fmt.Printf("event %d: %s\n", b.ID, b.Reason)
+	}
+
+	if !report.OK() {
+		fmt.Printf("audit log broken: %d of %d events fail verification\n", len(report.Breaks), report.Checked)
+		return 1
+	}
+
+	fmt.Printf("audit log intact: %d events verified\n", report.Checked)
+	return 0
+}
@@ -0,0 +1,87 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func chain(records ...Record) []Link {
+	links := []Link{}
+	prev := GenesisHash
+
+	for i, r := range records {
+		hash := r.Hash(prev)
+		links = append(links, Link{ID: int64(i + 1), Record: r, PrevHash: prev, Hash: hash})
+		prev = hash
+	}
+
+	return links
+}
+
+func TestActor(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, Actor{}, ActorFrom(ctx))
+
+	ctx = WithActor(ctx, Actor{IP: "10.0.0.1", RequestID: "req-1"})
+	ctx = WithUser(ctx, 7)
+
+	assert.Equal(t, Actor{UserID: 7, IP: "10.0.0.1", RequestID: "req-1"}, ActorFrom(ctx))
+}
+
+func TestHash(t *testing.T) {
+	created := time.Date(2025, 6, 1, 10, 0, 0, 123456789, time.FixedZone("WAT", 3600))
+	r := Record{ActorID: 1, Action: "account.create", TargetType: "account", TargetID: "5", After: json.RawMessage(`{"id":5}`), CreatedAt: created}
+
+	hash := r.Hash(GenesisHash)
+	assert.Len(t, hash, 64)
+
+	// The same instant read back from Postgres hashes the same.
+	readBack := r
+	readBack.CreatedAt = created.UTC().Truncate(time.Microsecond)
+	assert.Equal(t, hash, readBack.Hash(GenesisHash))
+
+	// A missing state hashes like JSON null.
+	readBack.Before = json.RawMessage("null")
+	assert.Equal(t, hash, readBack.Hash(GenesisHash))
+
+	assert.NotEqual(t, hash, r.Hash(hash))
+
+	changed := r
+	changed.TargetID = "6"
+	assert.NotEqual(t, hash, changed.Hash(GenesisHash))
+}
+
+func TestVerify(t *testing.T) {
+	links := chain(
+		Record{Action: "user.register", TargetType: "user", TargetID: "1"},
+		Record{ActorID: 1, Action: "account.create", TargetType: "account", TargetID: "1"},
+		Record{ActorID: 1, Action: "transfer.create", TargetType: "transfer", TargetID: "1"},
+	)
+
+	last, breaks := Verify(GenesisHash, links)
+	assert.Empty(t, breaks)
+	assert.Equal(t, links[2].Hash, last)
+
+	// Verifying in batches gives the same result.
+	last, breaks = Verify(GenesisHash, links[:1])
+	require.Empty(t, breaks)
+	last, breaks = Verify(last, links[1:])
+	assert.Empty(t, breaks)
+	assert.Equal(t, links[2].Hash, last)
+
+	tampered := append([]Link{}, links...)
+	tampered[1].Record.TargetID = "2"
+	_, breaks = Verify(GenesisHash, tampered)
+	require.Len(t, breaks, 1)
+	assert.Equal(t, int64(2), breaks[0].ID)
+
+	deleted := []Link{links[0], links[2]}
+	_, breaks = Verify(GenesisHash, deleted)
+	require.Len(t, breaks, 1)
+	assert.Equal(t, int64(3), breaks[0].ID)
+}
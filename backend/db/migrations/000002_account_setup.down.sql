@@ -1,5 +1,5 @@
 DROP TABLE IF EXISTS "transfers";
-DROP TABLE IF EXISTS "entires";
+DROP TABLE IF EXISTS "entries";
 ALTER TABLE "accounts" DROP CONSTRAINT "unique_user_currency";
 DROP TABLE IF EXISTS "accounts";
 
@@ -0,0 +1,39 @@
+CREATE TEMPORARY TABLE currency_exponents (
+    code VARCHAR(10) PRIMARY KEY,
+    exponent SMALLINT NOT NULL
+);
+
+INSERT INTO currency_exponents (code, exponent) VALUES
+    ('USD', 2),
+    ('NGN', 2),
+    ('ZAR', 2);
+
+ALTER TABLE "accounts" ADD COLUMN "minor_exponent" SMALLINT;
+ALTER TABLE "entries" ADD COLUMN "minor_exponent" SMALLINT;
+ALTER TABLE "transfers" ADD COLUMN "minor_exponent" SMALLINT;
+
+UPDATE "accounts" a SET minor_exponent = c.exponent
+FROM currency_exponents c WHERE c.code = a.currency;
+
+UPDATE "entries" e SET minor_exponent = a.minor_exponent
+FROM accounts a WHERE a.id = e.account_id;
+
+UPDATE "transfers" t SET minor_exponent = a.minor_exponent
+FROM accounts a WHERE a.id = t.from_account_id;
+
+ALTER TABLE "accounts" ALTER COLUMN balance DROP DEFAULT;
+ALTER TABLE "accounts" ALTER COLUMN balance TYPE DOUBLE PRECISION
+    USING (balance::numeric / power(10::numeric, minor_exponent))::DOUBLE PRECISION;
+ALTER TABLE "accounts" ALTER COLUMN balance SET DEFAULT 0.00;
+
+ALTER TABLE "entries" ALTER COLUMN amount TYPE DOUBLE PRECISION
+    USING (amount::numeric / power(10::numeric, minor_exponent))::DOUBLE PRECISION;
+
+ALTER TABLE "transfers" ALTER COLUMN amount TYPE DOUBLE PRECISION
+    USING (amount::numeric / power(10::numeric, minor_exponent))::DOUBLE PRECISION;
+
+ALTER TABLE "accounts" DROP COLUMN "minor_exponent";
+ALTER TABLE "entries" DROP COLUMN "minor_exponent";
+ALTER TABLE "transfers" DROP COLUMN "minor_exponent";
+
+DROP TABLE currency_exponents;
@@ -0,0 +1,68 @@
+-- Balances and amounts move from DOUBLE PRECISION to BIGINT minor units
+-- (cents, kobo, ...). The exponent of every currency in use is listed here so
+-- the conversion does not depend on application code.
+CREATE TEMPORARY TABLE currency_exponents (
+    code VARCHAR(10) PRIMARY KEY,
+    exponent SMALLINT NOT NULL
+);
+
+INSERT INTO currency_exponents (code, exponent) VALUES
+    ('USD', 2),
+    ('NGN', 2),
+    ('ZAR', 2);
+
+ALTER TABLE "accounts" ADD COLUMN "minor_exponent" SMALLINT;
+ALTER TABLE "entries" ADD COLUMN "minor_exponent" SMALLINT;
+ALTER TABLE "transfers" ADD COLUMN "minor_exponent" SMALLINT;
+
+UPDATE "accounts" a SET minor_exponent = c.exponent
+FROM currency_exponents c WHERE c.code = a.currency;
+
+UPDATE "entries" e SET minor_exponent = a.minor_exponent
+FROM accounts a WHERE a.id = e.account_id;
+
+UPDATE "transfers" t SET minor_exponent = a.minor_exponent
+FROM accounts a WHERE a.id = t.from_account_id;
+
+-- Refuse to migrate rather than round away anything that is not a whole
+-- number of minor units, or that belongs to a currency we don't know.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM accounts WHERE minor_exponent IS NULL
+        OR balance::numeric * power(10::numeric, minor_exponent) <> trunc(balance::numeric * power(10::numeric, minor_exponent))
+    ) THEN
+        RAISE EXCEPTION 'accounts.balance has values that are not whole minor units';
+    END IF;
+
+    IF EXISTS (
+        SELECT 1 FROM entries WHERE minor_exponent IS NULL
+        OR amount::numeric * power(10::numeric, minor_exponent) <> trunc(amount::numeric * power(10::numeric, minor_exponent))
+    ) THEN
+        RAISE EXCEPTION 'entries.amount has values that are not whole minor units';
+    END IF;
+
+    IF EXISTS (
+        SELECT 1 FROM transfers WHERE minor_exponent IS NULL
+        OR amount::numeric * power(10::numeric, minor_exponent) <> trunc(amount::numeric * power(10::numeric, minor_exponent))
+    ) THEN
+        RAISE EXCEPTION 'transfers.amount has values that are not whole minor units';
+    END IF;
+END $$;
+
+ALTER TABLE "accounts" ALTER COLUMN balance DROP DEFAULT;
+ALTER TABLE "accounts" ALTER COLUMN balance TYPE BIGINT
+    USING (balance::numeric * power(10::numeric, minor_exponent))::BIGINT;
+ALTER TABLE "accounts" ALTER COLUMN balance SET DEFAULT 0;
+
+ALTER TABLE "entries" ALTER COLUMN amount TYPE BIGINT
+    USING (amount::numeric * power(10::numeric, minor_exponent))::BIGINT;
+
+ALTER TABLE "transfers" ALTER COLUMN amount TYPE BIGINT
+    USING (amount::numeric * power(10::numeric, minor_exponent))::BIGINT;
+
+ALTER TABLE "accounts" DROP COLUMN "minor_exponent";
+ALTER TABLE "entries" DROP COLUMN "minor_exponent";
+ALTER TABLE "transfers" DROP COLUMN "minor_exponent";
+
+DROP TABLE currency_exponents;
@@ -0,0 +1,24 @@
+DROP TRIGGER IF EXISTS "entries_journal_balanced" ON "entries";
+DROP FUNCTION IF EXISTS check_journal_balanced();
+
+DROP INDEX IF EXISTS "entries_account_id_idx";
+DROP INDEX IF EXISTS "entries_journal_id_idx";
+ALTER TABLE "entries" DROP CONSTRAINT IF EXISTS "entry_type_matches_amount";
+
+-- Opening balance postings only exist to explain balances that were set
+-- directly; the balances themselves stay on the accounts.
+DELETE FROM entries e USING accounts a
+WHERE a.id = e.account_id AND a.kind <> 'user';
+DELETE FROM entries e USING journals j
+WHERE j.id = e.journal_id AND j.kind = 'opening_balance';
+
+ALTER TABLE "transfers" DROP COLUMN journal_id;
+ALTER TABLE "entries" DROP COLUMN journal_id;
+
+DELETE FROM accounts WHERE kind <> 'user';
+DROP INDEX IF EXISTS "unique_system_account_currency";
+ALTER TABLE "accounts" DROP CONSTRAINT IF EXISTS "account_owner_matches_kind";
+ALTER TABLE "accounts" ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE "accounts" DROP COLUMN kind;
+
+DROP TABLE IF EXISTS "journals";
@@ -0,0 +1,138 @@
+-- Every movement of money is a journal: a group of entries (postings) that
+-- sum to zero per currency. Account balances are a cached projection of the
+-- entries posted against them and are only changed by posting a journal.
+CREATE TABLE "journals" (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(32) NOT NULL,
+    description VARCHAR(256) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- System accounts (equity, clearing, ...) take the other side of postings
+-- that do not move money between two customers. They have no owner.
+ALTER TABLE "accounts" ADD COLUMN kind VARCHAR(20) NOT NULL DEFAULT 'user';
+ALTER TABLE "accounts" ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE "accounts" ADD CONSTRAINT "account_owner_matches_kind"
+CHECK ((kind = 'user') = (user_id IS NOT NULL));
+
+CREATE UNIQUE INDEX "unique_system_account_currency"
+ON "accounts" (kind, currency) WHERE kind <> 'user';
+
+ALTER TABLE "entries" ADD COLUMN journal_id BIGINT REFERENCES journals(id);
+ALTER TABLE "transfers" ADD COLUMN journal_id BIGINT REFERENCES journals(id);
+
+-- Backfill: every existing transfer becomes its own journal. TransferTx wrote
+-- both entries in the same transaction as the transfer, so they share its
+-- created_at.
+ALTER TABLE "journals" ADD COLUMN legacy_transfer_id BIGINT;
+
+INSERT INTO journals (kind, description, created_at, legacy_transfer_id)
+SELECT 'transfer', 'transfer from account ' || from_account_id || ' to account ' || to_account_id, created_at, id
+FROM transfers ORDER BY id;
+
+UPDATE transfers t SET journal_id = j.id
+FROM journals j WHERE j.legacy_transfer_id = t.id;
+
+UPDATE entries e SET journal_id = t.journal_id
+FROM transfers t
+WHERE e.journal_id IS NULL
+AND e.created_at = t.created_at
+AND (
+    (e.account_id = t.from_account_id AND e.amount = -t.amount)
+    OR (e.account_id = t.to_account_id AND e.amount = t.amount)
+);
+
+ALTER TABLE "journals" DROP COLUMN legacy_transfer_id;
+
+-- Whatever the transfers do not explain (balances set directly, stray entries)
+-- is booked as an opening balance against a per-currency equity account, so
+-- that every account starts out with balance = SUM(entries).
+INSERT INTO accounts (kind, currency)
+SELECT DISTINCT 'equity', currency FROM accounts WHERE kind = 'user';
+
+ALTER TABLE "journals" ADD COLUMN opening_currency VARCHAR(10);
+
+INSERT INTO journals (kind, description, opening_currency)
+SELECT 'opening_balance', 'opening balances', currency FROM accounts WHERE kind = 'equity';
+
+UPDATE entries e SET journal_id = j.id
+FROM accounts a, journals j
+WHERE e.journal_id IS NULL
+AND a.id = e.account_id
+AND j.opening_currency = a.currency;
+
+INSERT INTO entries (account_id, amount, type, journal_id)
+SELECT a.id, a.balance - COALESCE(s.total, 0), 'credit', j.id
+FROM accounts a
+JOIN journals j ON j.opening_currency = a.currency
+LEFT JOIN (
+    SELECT account_id, SUM(amount) AS total FROM entries GROUP BY account_id
+) s ON s.account_id = a.id
+WHERE a.kind = 'user' AND a.balance <> COALESCE(s.total, 0);
+
+INSERT INTO entries (account_id, amount, type, journal_id)
+SELECT q.id, -SUM(e.amount), 'credit', j.id
+FROM journals j
+JOIN accounts q ON q.kind = 'equity' AND q.currency = j.opening_currency
+JOIN entries e ON e.journal_id = j.id
+GROUP BY q.id, j.id
+HAVING SUM(e.amount) <> 0;
+
+UPDATE accounts a SET balance = COALESCE((SELECT SUM(amount) FROM entries WHERE account_id = a.id), 0)
+WHERE kind = 'equity';
+
+DELETE FROM journals j
+WHERE kind = 'opening_balance'
+AND NOT EXISTS (SELECT 1 FROM entries e WHERE e.journal_id = j.id);
+
+ALTER TABLE "journals" DROP COLUMN opening_currency;
+
+-- The old schema allowed zero-amount entries, which move nothing and have no
+-- sign to derive a type from. They don't count towards any balance, so they
+-- are dropped rather than rejected by the constraint below.
+DELETE FROM entries WHERE amount = 0;
+
+-- The entry type is now derived from the sign of the amount.
+UPDATE entries SET type = CASE WHEN amount < 0 THEN 'debit' ELSE 'credit' END;
+
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM accounts a
+        WHERE a.balance <> COALESCE((SELECT SUM(amount) FROM entries WHERE account_id = a.id), 0)
+    ) THEN
+        RAISE EXCEPTION 'ledger backfill left accounts whose balance does not match their entries';
+    END IF;
+END $$;
+
+ALTER TABLE "entries" ALTER COLUMN journal_id SET NOT NULL;
+ALTER TABLE "transfers" ALTER COLUMN journal_id SET NOT NULL;
+
+ALTER TABLE "entries" ADD CONSTRAINT "entry_type_matches_amount"
+CHECK ((type = 'debit' AND amount < 0) OR (type = 'credit' AND amount > 0));
+
+CREATE INDEX "entries_journal_id_idx" ON "entries" (journal_id);
+CREATE INDEX "entries_account_id_idx" ON "entries" (account_id);
+
+-- Postings of a journal must sum to zero per currency. The check is deferred
+-- to commit so that a journal's entries can be inserted one at a time.
+CREATE FUNCTION check_journal_balanced() RETURNS trigger AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM entries e
+        JOIN accounts a ON a.id = e.account_id
+        WHERE e.journal_id = NEW.journal_id
+        GROUP BY a.currency
+        HAVING SUM(e.amount) <> 0
+    ) THEN
+        RAISE EXCEPTION 'journal % does not balance', NEW.journal_id
+        USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER "entries_journal_balanced"
+AFTER INSERT OR UPDATE ON "entries"
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW EXECUTE FUNCTION check_journal_balanced();
@@ -0,0 +1,16 @@
+CREATE TABLE "idempotency_keys" (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    response_status INTEGER,
+    response_body BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMPTZ,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+ALTER TABLE "idempotency_keys" ADD CONSTRAINT "unique_user_idempotency_key"
+UNIQUE (user_id, key);
+
+CREATE INDEX "idempotency_keys_created_at_idx" ON "idempotency_keys" (created_at);
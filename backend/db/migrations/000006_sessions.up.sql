@@ -0,0 +1,22 @@
+-- A session is one login. Its refresh tokens form a rotation family: each
+-- refresh consumes the current token and issues the next one, and presenting
+-- an already used token revokes the whole session.
+CREATE TABLE "sessions" (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMPTZ,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX "sessions_user_id_idx" ON "sessions" (user_id);
+
+CREATE TABLE "refresh_tokens" (
+    id BIGSERIAL PRIMARY KEY,
+    session_id BIGINT NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS "users_created_at_idx";
+DROP INDEX IF EXISTS "transfers_to_account_id_id_idx";
+DROP INDEX IF EXISTS "transfers_from_account_id_id_idx";
+
+DROP INDEX IF EXISTS "entries_account_id_id_idx";
+CREATE INDEX "entries_account_id_idx" ON "entries" (account_id);
+
+DROP INDEX IF EXISTS "accounts_user_id_id_idx";
\ No newline at end of file
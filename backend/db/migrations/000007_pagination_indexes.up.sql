@@ -0,0 +1,11 @@
+-- List endpoints page by id (newest first) within an owner, so every listing
+-- is an index range scan instead of an OFFSET scan.
+CREATE INDEX "accounts_user_id_id_idx" ON "accounts" (user_id, id);
+
+DROP INDEX IF EXISTS "entries_account_id_idx";
+CREATE INDEX "entries_account_id_id_idx" ON "entries" (account_id, id);
+
+CREATE INDEX "transfers_from_account_id_id_idx" ON "transfers" (from_account_id, id);
+CREATE INDEX "transfers_to_account_id_id_idx" ON "transfers" (to_account_id, id);
+
+CREATE INDEX "users_created_at_idx" ON "users" (created_at);
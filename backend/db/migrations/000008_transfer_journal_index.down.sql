@@ -0,0 +1 @@
+DROP INDEX IF EXISTS "unique_transfer_journal";
\ No newline at end of file
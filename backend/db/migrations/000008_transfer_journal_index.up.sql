@@ -0,0 +1,2 @@
+-- Account history looks up the transfer behind each journal.
+CREATE UNIQUE INDEX "unique_transfer_journal" ON "transfers" (journal_id);
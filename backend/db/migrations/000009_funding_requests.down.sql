@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "funding_requests";
\ No newline at end of file
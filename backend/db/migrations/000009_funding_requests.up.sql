@@ -0,0 +1,33 @@
+-- Deposits and withdrawals move money between a customer account and the
+-- outside world through a funding provider. The ledger side of each goes
+-- through the per-currency clearing account, which holds money that is on its
+-- way in or out.
+--
+-- A deposit posts nothing until the provider settles it. A withdrawal holds
+-- the funds as soon as it is requested (journal_id) and gives them back with
+-- a reversal journal if the provider fails it.
+CREATE TABLE "funding_requests" (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL,
+    direction VARCHAR(16) NOT NULL,
+    amount BIGINT NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    provider VARCHAR(32) NOT NULL,
+    provider_reference VARCHAR(128),
+    failure_reason VARCHAR(256) NOT NULL DEFAULT '',
+    journal_id BIGINT,
+    reversal_journal_id BIGINT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    settled_at TIMESTAMPTZ,
+    FOREIGN KEY (account_id) REFERENCES accounts(id),
+    FOREIGN KEY (journal_id) REFERENCES journals(id),
+    FOREIGN KEY (reversal_journal_id) REFERENCES journals(id),
+    CONSTRAINT "funding_direction" CHECK (direction IN ('deposit', 'withdrawal')),
+    CONSTRAINT "funding_status" CHECK (status IN ('pending', 'settled', 'failed')),
+    CONSTRAINT "funding_amount_positive" CHECK (amount > 0)
+);
+
+CREATE INDEX "funding_requests_account_id_id_idx" ON "funding_requests" (account_id, id);
+CREATE INDEX "funding_requests_pending_idx" ON "funding_requests" (id) WHERE status = 'pending';
@@ -0,0 +1,45 @@
+-- Mid-market rates as served by the rate provider. Every quote points at the
+-- rate it was priced from, so conversions can be audited later.
+CREATE TABLE "fx_rates" (
+    id BIGSERIAL PRIMARY KEY,
+    base_currency VARCHAR(10) NOT NULL,
+    quote_currency VARCHAR(10) NOT NULL,
+    rate NUMERIC(24, 12) NOT NULL,
+    source VARCHAR(32) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT "fx_rate_positive" CHECK (rate > 0)
+);
+
+CREATE INDEX "fx_rates_pair_idx" ON "fx_rates" (base_currency, quote_currency, id);
+
+-- A quote locks a customer rate for a short while. Executing it posts one
+-- journal through the FX house accounts of both currencies; sell_amount is
+-- what leaves the customer, fee_amount is kept in the sell currency and
+-- spread_amount is what the customer rate kept below mid, in the buy currency.
+CREATE TABLE "fx_quotes" (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    from_account_id BIGINT NOT NULL,
+    to_account_id BIGINT NOT NULL,
+    rate_id BIGINT NOT NULL,
+    sell_amount BIGINT NOT NULL,
+    sell_currency VARCHAR(10) NOT NULL,
+    buy_amount BIGINT NOT NULL,
+    buy_currency VARCHAR(10) NOT NULL,
+    fee_amount BIGINT NOT NULL,
+    spread_amount BIGINT NOT NULL,
+    rate NUMERIC(24, 12) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    journal_id BIGINT,
+    executed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (from_account_id) REFERENCES accounts(id),
+    FOREIGN KEY (to_account_id) REFERENCES accounts(id),
+    FOREIGN KEY (rate_id) REFERENCES fx_rates(id),
+    FOREIGN KEY (journal_id) REFERENCES journals(id),
+    CONSTRAINT "fx_quote_amounts" CHECK (sell_amount > 0 AND buy_amount > 0 AND fee_amount >= 0 AND spread_amount >= 0),
+    CONSTRAINT "fx_quote_currencies" CHECK (sell_currency <> buy_currency)
+);
+
+CREATE INDEX "fx_quotes_user_id_idx" ON "fx_quotes" (user_id);
@@ -0,0 +1,3 @@
+ALTER TABLE "users" DROP COLUMN IF EXISTS is_admin;
+ALTER TABLE "accounts" DROP CONSTRAINT IF EXISTS "accounts_currency_fkey";
+DROP TABLE IF EXISTS "currencies";
\ No newline at end of file
@@ -0,0 +1,72 @@
+-- ISO 4217 currencies. Only enabled currencies can be used for new accounts
+-- and money movements; disabled ones still format existing balances.
+-- min_amount and max_amount bound a single movement in minor units, and zero
+-- means no bound.
+CREATE TABLE "currencies" (
+    code VARCHAR(3) PRIMARY KEY,
+    numeric_code VARCHAR(3) NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    exponent INTEGER NOT NULL,
+    symbol VARCHAR(8) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT false,
+    min_amount BIGINT NOT NULL DEFAULT 0,
+    max_amount BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT "currency_code_format" CHECK (code ~ '^[A-Z]{3}$'),
+    CONSTRAINT "currency_exponent_range" CHECK (exponent BETWEEN 0 AND 4),
+    CONSTRAINT "currency_limits" CHECK (min_amount >= 0 AND max_amount >= 0 AND (max_amount = 0 OR max_amount >= min_amount))
+);
+
+INSERT INTO currencies (code, numeric_code, name, exponent, symbol, enabled) VALUES
+    ('AED', '784', 'UAE Dirham', 2, 'د.إ', false),
+    ('AUD', '036', 'Australian Dollar', 2, 'A$', false),
+    ('BHD', '048', 'Bahraini Dinar', 3, '.د.ب', false),
+    ('BRL', '986', 'Brazilian Real', 2, 'R$', false),
+    ('BWP', '072', 'Pula', 2, 'P', false),
+    ('CAD', '124', 'Canadian Dollar', 2, 'C$', false),
+    ('CHF', '756', 'Swiss Franc', 2, 'CHF', false),
+    ('CNY', '156', 'Yuan Renminbi', 2, '¥', false),
+    ('DKK', '208', 'Danish Krone', 2, 'kr', false),
+    ('EGP', '818', 'Egyptian Pound', 2, 'E£', false),
+    ('ETB', '230', 'Ethiopian Birr', 2, 'Br', false),
+    ('EUR', '978', 'Euro', 2, '€', false),
+    ('GBP', '826', 'Pound Sterling', 2, '£', false),
+    ('GHS', '936', 'Ghana Cedi', 2, 'GH₵', false),
+    ('HKD', '344', 'Hong Kong Dollar', 2, 'HK$', false),
+    ('INR', '356', 'Indian Rupee', 2, '₹', false),
+    ('JOD', '400', 'Jordanian Dinar', 3, 'JD', false),
+    ('JPY', '392', 'Yen', 0, '¥', false),
+    ('KES', '404', 'Kenyan Shilling', 2, 'KSh', false),
+    ('KRW', '410', 'Won', 0, '₩', false),
+    ('KWD', '414', 'Kuwaiti Dinar', 3, 'KD', false),
+    ('MAD', '504', 'Moroccan Dirham', 2, 'MAD', false),
+    ('MUR', '480', 'Mauritius Rupee', 2, '₨', false),
+    ('MXN', '484', 'Mexican Peso', 2, 'MX$', false),
+    ('MZN', '943', 'Mozambique Metical', 2, 'MT', false),
+    ('NAD', '516', 'Namibia Dollar', 2, 'N$', false),
+    ('NGN', '566', 'Naira', 2, '₦', true),
+    ('NOK', '578', 'Norwegian Krone', 2, 'kr', false),
+    ('NZD', '554', 'New Zealand Dollar', 2, 'NZ$', false),
+    ('OMR', '512', 'Rial Omani', 3, 'RO', false),
+    ('PLN', '985', 'Zloty', 2, 'zł', false),
+    ('QAR', '634', 'Qatari Rial', 2, 'QR', false),
+    ('RWF', '646', 'Rwanda Franc', 0, 'FRw', false),
+    ('SAR', '682', 'Saudi Riyal', 2, 'SR', false),
+    ('SEK', '752', 'Swedish Krona', 2, 'kr', false),
+    ('SGD', '702', 'Singapore Dollar', 2, 'S$', false),
+    ('TND', '788', 'Tunisian Dinar', 3, 'DT', false),
+    ('TRY', '949', 'Turkish Lira', 2, '₺', false),
+    ('TZS', '834', 'Tanzanian Shilling', 2, 'TSh', false),
+    ('UGX', '800', 'Uganda Shilling', 0, 'USh', false),
+    ('USD', '840', 'US Dollar', 2, '$', true),
+    ('XAF', '950', 'CFA Franc BEAC', 0, 'FCFA', false),
+    ('XOF', '952', 'CFA Franc BCEAO', 0, 'CFA', false),
+    ('ZAR', '710', 'Rand', 2, 'R', true),
+    ('ZMW', '967', 'Zambian Kwacha', 2, 'ZK', false);
+
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_currency_fkey"
+FOREIGN KEY (currency) REFERENCES currencies(code);
+
+-- Until roles exist, admins are flagged on the user. Set it by hand:
+-- UPDATE users SET is_admin = true WHERE email = '...';
+ALTER TABLE "users" ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS "account_status_changes";
+DROP INDEX IF EXISTS "unique_user_currency";
+ALTER TABLE "accounts" ADD CONSTRAINT "unique_user_currency" UNIQUE (user_id, currency);
+ALTER TABLE "accounts" DROP CONSTRAINT IF EXISTS "account_status";
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS closed_at;
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS status_reason;
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS status;
\ No newline at end of file
@@ -0,0 +1,30 @@
+-- Accounts move through active, frozen, dormant and closed. Frozen and
+-- dormant accounts take credits but no debits; closed accounts take nothing
+-- and are kept, never deleted, because entries and transfers reference them.
+ALTER TABLE "accounts" ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'active';
+ALTER TABLE "accounts" ADD COLUMN status_reason VARCHAR(256) NOT NULL DEFAULT '';
+ALTER TABLE "accounts" ADD COLUMN closed_at TIMESTAMPTZ;
+
+ALTER TABLE "accounts" ADD CONSTRAINT "account_status"
+CHECK (status IN ('active', 'frozen', 'dormant', 'closed'));
+
+-- A closed account no longer counts towards one account per currency, so a
+-- user can open a new one after closing the old.
+ALTER TABLE "accounts" DROP CONSTRAINT "unique_user_currency";
+CREATE UNIQUE INDEX "unique_user_currency" ON "accounts" (user_id, currency)
+WHERE status <> 'closed';
+
+-- Every status change, with who made it and why.
+CREATE TABLE "account_status_changes" (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL,
+    from_status VARCHAR(16) NOT NULL,
+    to_status VARCHAR(16) NOT NULL,
+    reason VARCHAR(256) NOT NULL,
+    actor_id BIGINT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (account_id) REFERENCES accounts(id),
+    FOREIGN KEY (actor_id) REFERENCES users(id)
+);
+
+CREATE INDEX "account_status_changes_account_id_idx" ON "account_status_changes" (account_id, id);
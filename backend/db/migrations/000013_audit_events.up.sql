@@ -0,0 +1,37 @@
+-- Every state change is recorded here. Rows are chained: hash covers the
+-- event and the hash of the event before it, so editing or removing a row
+-- breaks the chain from that point on. before_state and after_state are JSON,
+-- not JSONB, so the text that was hashed is the text that is stored.
+CREATE TABLE "audit_events" (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id BIGINT,
+    action VARCHAR(64) NOT NULL,
+    target_type VARCHAR(32) NOT NULL,
+    target_id VARCHAR(64) NOT NULL,
+    before_state JSON NOT NULL DEFAULT 'null',
+    after_state JSON NOT NULL DEFAULT 'null',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent VARCHAR(256) NOT NULL DEFAULT '',
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    prev_hash CHAR(64) NOT NULL,
+    hash CHAR(64) NOT NULL UNIQUE
+);
+
+CREATE INDEX "audit_events_target_idx" ON "audit_events" (target_type, target_id, id);
+CREATE INDEX "audit_events_actor_idx" ON "audit_events" (actor_id, id);
+CREATE INDEX "audit_events_action_idx" ON "audit_events" (action, id);
+
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "audit_events_append_only"
+BEFORE UPDATE OR DELETE ON "audit_events"
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER "audit_events_no_truncate"
+BEFORE TRUNCATE ON "audit_events"
+FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
@@ -0,0 +1,7 @@
+ALTER TABLE "users" ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT false;
+UPDATE "users" SET is_admin = true
+WHERE id IN (SELECT ur.user_id FROM user_roles ur JOIN roles r ON r.id = ur.role_id WHERE r.name = 'admin');
+DROP TABLE IF EXISTS "user_roles";
+DROP TABLE IF EXISTS "role_permissions";
+DROP TABLE IF EXISTS "roles";
+DROP TABLE IF EXISTS "permissions";
\ No newline at end of file
@@ -0,0 +1,64 @@
+-- Permissions are named capabilities checked by the API, e.g. accounts.read.
+-- Roles group them and users hold any number of roles. Regular customers hold
+-- none: they can only reach their own resources.
+CREATE TABLE "permissions" (
+    name VARCHAR(64) PRIMARY KEY,
+    description VARCHAR(256) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE "roles" (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(32) NOT NULL UNIQUE,
+    description VARCHAR(256) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE "role_permissions" (
+    role_id BIGINT NOT NULL,
+    permission VARCHAR(64) NOT NULL,
+    PRIMARY KEY (role_id, permission),
+    FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE,
+    FOREIGN KEY (permission) REFERENCES permissions(name) ON DELETE CASCADE
+);
+
+CREATE TABLE "user_roles" (
+    user_id BIGINT NOT NULL,
+    role_id BIGINT NOT NULL,
+    granted_by BIGINT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, role_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE,
+    FOREIGN KEY (granted_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX "user_roles_role_id_idx" ON "user_roles" (role_id);
+
+INSERT INTO "permissions" (name, description) VALUES
+('users.read', 'List and view any user'),
+('accounts.read', 'List and view any account'),
+('accounts.status', 'Freeze, unfreeze and change the status of any account'),
+('transfers.read', 'List and view any transfer'),
+('currencies.manage', 'Enable and disable currencies and set their limits'),
+('audit.read', 'Read the audit log'),
+('roles.manage', 'Grant and revoke roles');
+
+INSERT INTO "roles" (name, description) VALUES
+('admin', 'Full access to the admin API'),
+('support', 'Read-only access to customers, accounts and transfers');
+
+INSERT INTO "role_permissions" (role_id, permission)
+SELECT r.id, p.name FROM roles r CROSS JOIN permissions p
+WHERE r.name = 'admin';
+
+INSERT INTO "role_permissions" (role_id, permission)
+SELECT r.id, p.name FROM roles r
+JOIN permissions p ON p.name IN ('users.read', 'accounts.read', 'transfers.read', 'audit.read')
+WHERE r.name = 'support';
+
+-- Users flagged as admins keep their access through the admin role.
+INSERT INTO "user_roles" (user_id, role_id)
+SELECT u.id, r.id FROM users u CROSS JOIN roles r
+WHERE u.is_admin AND r.name = 'admin';
+
+ALTER TABLE "users" DROP COLUMN is_admin;
@@ -0,0 +1,13 @@
+-- Password reset links carry a random token; only its SHA-256 is stored.
+-- A token works once and until expires_at.
+CREATE TABLE "password_reset_tokens" (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX "password_reset_tokens_user_id_idx" ON "password_reset_tokens" (user_id, created_at);
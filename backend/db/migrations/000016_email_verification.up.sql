@@ -0,0 +1,18 @@
+ALTER TABLE "users" ADD COLUMN "email_verified_at" TIMESTAMP;
+
+-- Users who registered before verification existed keep full access.
+UPDATE "users" SET "email_verified_at" = "created_at";
+
+-- Verification links carry a random token; only its SHA-256 is stored.
+-- A token works once and until expires_at.
+CREATE TABLE "email_verification_tokens" (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX "email_verification_tokens_user_id_idx" ON "email_verification_tokens" (user_id, created_at);
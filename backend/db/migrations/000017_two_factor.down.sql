@@ -0,0 +1,9 @@
+ALTER TABLE "currencies" DROP COLUMN IF EXISTS "step_up_amount";
+
+ALTER TABLE "sessions" DROP COLUMN IF EXISTS "step_up_at";
+
+DROP TABLE IF EXISTS "login_challenges";
+
+DROP TABLE IF EXISTS "recovery_codes";
+
+DROP TABLE IF EXISTS "totp_credentials";
\ No newline at end of file
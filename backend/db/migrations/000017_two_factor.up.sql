@@ -0,0 +1,47 @@
+-- One TOTP authenticator per user. The secret is sealed with
+-- TOTP_ENCRYPTION_KEY; it only protects logins once confirmed_at is set.
+-- last_used_step is the last accepted 30 second step, so a code can't be
+-- replayed, and failed_attempts counts wrong codes in a row.
+CREATE TABLE "totp_credentials" (
+    user_id BIGINT PRIMARY KEY,
+    secret TEXT NOT NULL,
+    confirmed_at TIMESTAMPTZ,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    failed_attempts INTEGER NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- One-time codes for when the authenticator is lost. Only their SHA-256 is
+-- stored.
+CREATE TABLE "recovery_codes" (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    used_at TIMESTAMPTZ,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (user_id, code_hash)
+);
+
+-- A password accepted for a user with two-factor authentication yields a
+-- challenge, which is exchanged for a session together with a code.
+CREATE TABLE "login_challenges" (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- When the session last passed a two-factor check, for operations that want
+-- a recent one.
+ALTER TABLE "sessions" ADD COLUMN "step_up_at" TIMESTAMPTZ;
+
+-- Movements of at least step_up_amount minor units need a recent two-factor
+-- check. Zero means never.
+ALTER TABLE "currencies" ADD COLUMN "step_up_amount" BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE "currencies" ADD CONSTRAINT "currency_step_up_amount" CHECK (step_up_amount >= 0);
@@ -0,0 +1,11 @@
+-- Failed logins per key, such as an email or a client IP, for the login
+-- throttle. Keys are recorded whether or not an account exists, so the
+-- throttle behaves the same for both.
+CREATE TABLE "login_attempts" (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failed_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ
+);
+
+CREATE INDEX "login_attempts_last_failed_at_idx" ON "login_attempts" (last_failed_at);
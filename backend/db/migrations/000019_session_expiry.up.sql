@@ -0,0 +1,10 @@
+-- A session lasts as long as its newest refresh token, so that access tokens
+-- issued for it stop working once it can no longer be refreshed.
+ALTER TABLE "sessions" ADD COLUMN "expires_at" TIMESTAMPTZ;
+
+UPDATE sessions s SET expires_at = COALESCE(
+    (SELECT MAX(expires_at) FROM refresh_tokens WHERE session_id = s.id),
+    s.created_at
+);
+
+ALTER TABLE "sessions" ALTER COLUMN "expires_at" SET NOT NULL;
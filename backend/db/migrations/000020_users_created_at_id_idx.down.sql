@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "users_created_at_id_idx";
+CREATE INDEX "users_created_at_idx" ON "users" (created_at);
\ No newline at end of file
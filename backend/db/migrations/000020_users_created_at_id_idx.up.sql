@@ -0,0 +1,4 @@
+-- The users list filters on created_at and pages by id, so both belong in
+-- the index.
+DROP INDEX IF EXISTS "users_created_at_idx";
+CREATE INDEX "users_created_at_id_idx" ON "users" (created_at, id);
@@ -0,0 +1,156 @@
+// Package migrations embeds the SQL migrations, so a binary knows which
+// schema version it was built for and can apply them itself.
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+// Latest returns the version of the newest migration.
+func Latest() (uint, error) {
+	files, err := fs.Glob(FS, "*.up.sql")
+	if err != nil {
+		return 0, err
+	}
+
+	var latest uint
+	for _, name := range files {
+		version, err := Version(name)
+		if err != nil {
+			return 0, err
+		}
+		latest = max(latest, version)
+	}
+
+	return latest, nil
+}
+
+// Version parses the version from a migration file name such as
+// 000001_init.up.sql.
+func Version(name string) (uint, error) {
+	prefix, _, ok := strings.Cut(name, "_")
+	if !ok {
+		return 0, fmt.Errorf("migration %q has no version", name)
+	}
+
+	version, err := strconv.ParseUint(prefix, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("migration %q has no version: %w", name, err)
+	}
+
+	return uint(version), nil
+}
+
+// New returns a migrator for the embedded migrations on conn. Up, Down,
+// Steps and Force hold a Postgres advisory lock while they run, so two
+// processes migrating the same database take turns. Closing the migrator
+// closes conn. Progress is logged to logger when it isn't nil.
+func New(conn *sql.DB, logger *slog.Logger) (*migrate.Migrate, error) {
+	source, err := iofs.New(FS, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := postgres.WithInstance(conn, &postgres.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+	if err != nil {
+		return nil, err
+	}
+
+	if logger != nil {
+		m.Log = migrateLogger{logger}
+	}
+
+	return m, nil
+}
+
+// Up applies every pending migration to conn and closes it. It returns the
+// version the database is at afterwards.
+func Up(conn *sql.DB, logger *slog.Logger) (uint, error) {
+	m, err := New(conn, logger)
+	if err != nil {
+		conn.Close()
+		return 0, err
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return 0, err
+	}
+
+	version, _, err := m.Version()
+	return version, err
+}
+
+var migrationName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Create adds empty up and down files for the next version to dir, which
+// should be the source directory of the embedded files, and returns their
+// paths.
+func Create(dir string, name string) (string, string, error) {
+	if !migrationName.MatchString(name) {
+		return "", "", fmt.Errorf("migration name %q must be lowercase letters, digits and underscores", name)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.up.sql"))
+	if err != nil {
+		return "", "", err
+	}
+
+	var latest uint
+	for _, file := range files {
+		version, err := Version(filepath.Base(file))
+		if err != nil {
+			return "", "", err
+		}
+		latest = max(latest, version)
+	}
+
+	prefix := filepath.Join(dir, fmt.Sprintf("%06d_%s", latest+1, name))
+	up, down := prefix+".up.sql", prefix+".down.sql"
+
+	for _, path := range []string{up, down} {
+		// O_EXCL so an existing migration is never overwritten.
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return "", "", err
+		}
+		file.Close()
+	}
+
+	return up, down, nil
+}
+
+// migrateLogger sends migrate's progress messages to slog.
+type migrateLogger struct {
+	logger *slog.Logger
+}
+
+func (l migrateLogger) Printf(format string, v ...any) {
+	l.logger.Info(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (l migrateLogger) Verbose() bool {
+	return false
+}
@@ -0,0 +1,80 @@
+package migrations
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationsAreSequential(t *testing.T) {
+	files, err := fs.Glob(FS, "*.sql")
+	require.NoError(t, err)
+
+	ups := map[uint]bool{}
+	downs := map[uint]bool{}
+	for _, name := range files {
+		version, err := Version(name)
+		require.NoError(t, err)
+
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			ups[version] = true
+		case strings.HasSuffix(name, ".down.sql"):
+			downs[version] = true
+		default:
+			t.Errorf("%s is neither an up nor a down migration", name)
+		}
+	}
+
+	latest, err := Latest()
+	require.NoError(t, err)
+	require.NotZero(t, latest)
+
+	for version := uint(1); version <= latest; version++ {
+		assert.True(t, ups[version], "missing up migration %d", version)
+		assert.True(t, downs[version], "missing down migration %d", version)
+	}
+	assert.Len(t, ups, int(latest))
+	assert.Len(t, downs, int(latest))
+}
+
+func TestVersion(t *testing.T) {
+	version, err := Version("000012_audit_log.up.sql")
+	require.NoError(t, err)
+	assert.Equal(t, uint(12), version)
+
+	_, err = Version("audit_log.up.sql")
+	assert.Error(t, err)
+}
+
+func TestMigrationsAreNotEmpty(t *testing.T) {
+	files, err := fs.Glob(FS, "*.sql")
+	require.NoError(t, err)
+
+	for _, name := range files {
+		content, err := fs.ReadFile(FS, name)
+		require.NoError(t, err)
+		assert.NotEmpty(t, strings.TrimSpace(string(content)), "%s is empty", name)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "000001_init.up.sql"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "000002_accounts.up.sql"), nil, 0o644))
+
+	up, down, err := Create(dir, "add_cards")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "000003_add_cards.up.sql"), up)
+	assert.Equal(t, filepath.Join(dir, "000003_add_cards.down.sql"), down)
+	assert.FileExists(t, up)
+	assert.FileExists(t, down)
+
+	_, _, err = Create(dir, "Add Cards")
+	assert.Error(t, err)
+}
@@ -7,18 +7,78 @@ INSERT INTO accounts (
 -- name: GetAccountByID :one
 SELECT * FROM accounts WHERE id = $1;
 
+-- name: GetAccountForUpdate :one
+SELECT * FROM accounts WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
 -- name: GetAccountByUserID :many
 SELECT * FROM accounts WHERE user_id = $1;
 
 -- name: ListAccounts :many
-SELECT * FROM accounts ORDER BY id 
-LIMIT $1 OFFSET $2;
+SELECT * FROM accounts
+WHERE (sqlc.narg(before_id)::BIGINT IS NULL OR id < sqlc.narg(before_id))
+AND (sqlc.narg(user_id)::INT IS NULL OR user_id = sqlc.narg(user_id))
+AND (sqlc.narg(currency)::VARCHAR IS NULL OR currency = sqlc.narg(currency))
+AND (sqlc.narg(status)::VARCHAR IS NULL OR status = sqlc.narg(status))
+AND (sqlc.narg(kind)::VARCHAR IS NULL OR kind = sqlc.narg(kind))
+AND (sqlc.narg(created_from)::TIMESTAMPTZ IS NULL OR created_at >= sqlc.narg(created_from))
+AND (sqlc.narg(created_to)::TIMESTAMPTZ IS NULL OR created_at < sqlc.narg(created_to))
+ORDER BY id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: ListAccountsByUserID :many
+SELECT * FROM accounts
+WHERE user_id = sqlc.arg(user_id)
+AND (sqlc.narg(before_id)::BIGINT IS NULL OR id < sqlc.narg(before_id))
+AND (sqlc.narg(currency)::VARCHAR IS NULL OR currency = sqlc.narg(currency))
+AND (sqlc.narg(created_from)::TIMESTAMPTZ IS NULL OR created_at >= sqlc.narg(created_from))
+AND (sqlc.narg(created_to)::TIMESTAMPTZ IS NULL OR created_at < sqlc.narg(created_to))
+AND (sqlc.narg(min_balance)::BIGINT IS NULL OR balance >= sqlc.narg(min_balance))
+AND (sqlc.narg(max_balance)::BIGINT IS NULL OR balance <= sqlc.narg(max_balance))
+ORDER BY id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: AddAccountBalance :one
+UPDATE accounts SET balance = balance + sqlc.arg(amount) 
+WHERE id = sqlc.arg(id) RETURNING *;
+
+-- name: UpsertSystemAccount :one
+INSERT INTO accounts (
+    kind,
+    currency
+) VALUES ($1, $2)
+ON CONFLICT (kind, currency) WHERE kind <> 'user'
+DO UPDATE SET kind = EXCLUDED.kind
+RETURNING *;
+
+-- name: ListUnreconciledAccounts :many
+SELECT a.id, a.balance, COALESCE(SUM(e.amount), 0)::BIGINT AS entries_total
+FROM accounts a
+LEFT JOIN entries e ON e.account_id = a.id
+GROUP BY a.id
+HAVING a.balance <> COALESCE(SUM(e.amount), 0)
+ORDER BY a.id;
+
+-- name: SetAccountStatus :one
+UPDATE accounts SET status = sqlc.arg(status), status_reason = sqlc.arg(status_reason),
+closed_at = CASE WHEN sqlc.arg(status)::VARCHAR = 'closed' THEN now() END
+WHERE id = sqlc.arg(id) RETURNING *;
+
+-- name: CreateAccountStatusChange :one
+INSERT INTO account_status_changes (
+    account_id,
+    from_status,
+    to_status,
+    reason,
+    actor_id
+) VALUES ($1, $2, $3, $4, $5) RETURNING *;
+
+-- name: ListAccountStatusChanges :many
+SELECT * FROM account_status_changes WHERE account_id = $1
+ORDER BY id DESC;
 
--- name: UpdateAccountBalance :one
-UPDATE accounts SET balance = $1 WHERE id = $2 RETURNING *;
+-- name: DeleteAllAccountStatusChanges :exec
+DELETE FROM account_status_changes;
 
--- name: DeleteAccount :exec
-DELETE FROM accounts WHERE id = $1;
- 
 -- name: DeleteAllAccounts :exec
 DELETE FROM accounts;
\ No newline at end of file
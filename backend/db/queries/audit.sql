@@ -0,0 +1,38 @@
+-- name: LockAuditChain :exec
+SELECT pg_advisory_xact_lock(hashtext('audit_events'));
+
+-- name: GetLastAuditHash :one
+SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1;
+
+-- name: CreateAuditEvent :one
+INSERT INTO audit_events (
+    actor_id,
+    action,
+    target_type,
+    target_id,
+    before_state,
+    after_state,
+    ip,
+    user_agent,
+    request_id,
+    created_at,
+    prev_hash,
+    hash
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING *;
+
+-- name: ListAuditEvents :many
+SELECT * FROM audit_events
+WHERE (sqlc.narg(before_id)::BIGINT IS NULL OR id < sqlc.narg(before_id))
+AND (sqlc.narg(actor_id)::BIGINT IS NULL OR actor_id = sqlc.narg(actor_id))
+AND (sqlc.narg(action)::VARCHAR IS NULL OR action = sqlc.narg(action))
+AND (sqlc.narg(target_type)::VARCHAR IS NULL OR target_type = sqlc.narg(target_type))
+AND (sqlc.narg(target_id)::VARCHAR IS NULL OR target_id = sqlc.narg(target_id))
+AND (sqlc.narg(created_from)::TIMESTAMPTZ IS NULL OR created_at >= sqlc.narg(created_from))
+AND (sqlc.narg(created_to)::TIMESTAMPTZ IS NULL OR created_at < sqlc.narg(created_to))
+ORDER BY id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: ListAuditEventsAfter :many
+SELECT * FROM audit_events WHERE id > $1
+ORDER BY id
+LIMIT $2;
\ No newline at end of file
@@ -0,0 +1,37 @@
+-- name: ListCurrencies :many
+SELECT * FROM currencies ORDER BY code;
+
+-- name: GetCurrency :one
+SELECT * FROM currencies WHERE code = $1 LIMIT 1;
+
+-- name: UpsertCurrency :one
+INSERT INTO currencies (
+    code,
+    numeric_code,
+    name,
+    exponent,
+    symbol,
+    enabled,
+    min_amount,
+    max_amount,
+    step_up_amount
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (code) DO UPDATE SET
+    numeric_code = EXCLUDED.numeric_code,
+    name = EXCLUDED.name,
+    exponent = EXCLUDED.exponent,
+    symbol = EXCLUDED.symbol,
+    enabled = EXCLUDED.enabled,
+    min_amount = EXCLUDED.min_amount,
+    max_amount = EXCLUDED.max_amount,
+    step_up_amount = EXCLUDED.step_up_amount,
+    updated_at = now()
+RETURNING *;
+
+-- name: SetCurrencyEnabled :one
+UPDATE currencies SET enabled = $1, updated_at = now()
+WHERE code = $2 RETURNING *;
+
+-- name: SetCurrencyLimits :one
+UPDATE currencies SET min_amount = $1, max_amount = $2, step_up_amount = $3, updated_at = now()
+WHERE code = $4 RETURNING *;
\ No newline at end of file
@@ -0,0 +1,18 @@
+-- name: CreateEmailVerificationToken :one
+INSERT INTO email_verification_tokens (
+    user_id,
+    token_hash,
+    expires_at
+) VALUES ($1, $2, $3) RETURNING *;
+
+-- name: GetEmailVerificationTokenForUpdate :one
+SELECT * FROM email_verification_tokens WHERE token_hash = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: CountRecentEmailVerificationTokens :one
+SELECT COUNT(*) FROM email_verification_tokens
+WHERE user_id = $1 AND created_at > $2;
+
+-- name: UseEmailVerificationTokens :exec
+UPDATE email_verification_tokens SET used_at = now()
+WHERE user_id = $1 AND used_at IS NULL;
\ No newline at end of file
@@ -1,8 +1,10 @@
 -- name: CreateEntry :one
 INSERT INTO entries (
+    journal_id,
     account_id,
-    amount
-) VALUES ($1, $2) RETURNING *;
+    amount,
+    type
+) VALUES ($1, $2, $3, $4) RETURNING *;
 
 -- name: GetEntryByID :one
 SELECT * FROM entries WHERE id = $1;
@@ -10,9 +12,50 @@ SELECT * FROM entries WHERE id = $1;
 -- name: GetEntriesByAccountID :many
 SELECT * FROM entries WHERE account_id = $1;
 
+-- name: GetEntriesByJournalID :many
+SELECT * FROM entries WHERE journal_id = $1 ORDER BY id;
+
 -- name: ListEntries :many
-SELECT * FROM entries ORDER BY id 
-LIMIT $1 OFFSET $2;
+SELECT * FROM entries
+WHERE (sqlc.narg(before_id)::BIGINT IS NULL OR id < sqlc.narg(before_id))
+ORDER BY id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: ListEntriesByAccountID :many
+SELECT * FROM entries
+WHERE account_id = sqlc.arg(account_id)
+AND (sqlc.narg(before_id)::BIGINT IS NULL OR id < sqlc.narg(before_id))
+AND (sqlc.narg(created_from)::TIMESTAMPTZ IS NULL OR created_at >= sqlc.narg(created_from))
+AND (sqlc.narg(created_to)::TIMESTAMPTZ IS NULL OR created_at < sqlc.narg(created_to))
+AND (sqlc.narg(min_amount)::BIGINT IS NULL OR ABS(amount) >= sqlc.narg(min_amount))
+AND (sqlc.narg(max_amount)::BIGINT IS NULL OR ABS(amount) <= sqlc.narg(max_amount))
+ORDER BY id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: ListAccountTransactions :many
+SELECT e.id, e.journal_id, e.amount, e.created_at, j.kind, j.description,
+    t.id AS transfer_id,
+    c.account_id AS counterparty_account_id,
+    ca.kind AS counterparty_kind
+FROM entries e
+JOIN journals j ON j.id = e.journal_id
+LEFT JOIN transfers t ON t.journal_id = e.journal_id
+LEFT JOIN LATERAL (
+    SELECT o.account_id FROM entries o
+    WHERE o.journal_id = e.journal_id AND o.account_id <> e.account_id
+    ORDER BY o.id
+    LIMIT 1
+) c ON true
+LEFT JOIN accounts ca ON ca.id = c.account_id
+WHERE e.account_id = sqlc.arg(account_id)
+AND (sqlc.narg(before_id)::BIGINT IS NULL OR e.id < sqlc.narg(before_id))
+ORDER BY e.id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: SumEntriesAfter :one
+SELECT COALESCE(SUM(amount), 0)::BIGINT AS total
+FROM entries
+WHERE account_id = sqlc.arg(account_id) AND id > sqlc.arg(after_id);
 
 -- name: DeleteAllEntries :exec
 DELETE FROM entries;
\ No newline at end of file
@@ -0,0 +1,51 @@
+-- name: CreateFundingRequest :one
+INSERT INTO funding_requests (
+    account_id,
+    direction,
+    amount,
+    currency,
+    provider,
+    journal_id
+) VALUES ($1, $2, $3, $4, $5, $6) RETURNING *;
+
+-- name: GetFundingRequestByID :one
+SELECT * FROM funding_requests WHERE id = $1;
+
+-- name: GetFundingRequestForUpdate :one
+SELECT * FROM funding_requests WHERE id = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: SetFundingRequestReference :one
+UPDATE funding_requests SET provider_reference = $1, updated_at = now()
+WHERE id = $2 RETURNING *;
+
+-- name: SettleFundingRequest :one
+UPDATE funding_requests SET status = 'settled', journal_id = COALESCE(sqlc.narg(journal_id), journal_id),
+    settled_at = now(), updated_at = now()
+WHERE id = sqlc.arg(id) RETURNING *;
+
+-- name: FailFundingRequest :one
+UPDATE funding_requests SET status = 'failed', failure_reason = sqlc.arg(failure_reason),
+    reversal_journal_id = sqlc.narg(reversal_journal_id), updated_at = now()
+WHERE id = sqlc.arg(id) RETURNING *;
+
+-- name: ListPendingFundingRequests :many
+SELECT * FROM funding_requests
+WHERE status = 'pending'
+AND (provider_reference IS NOT NULL OR created_at < sqlc.arg(unsubmitted_before))
+ORDER BY id
+LIMIT sqlc.arg(page_limit);
+
+-- name: ListFundingRequestsByAccountID :many
+SELECT * FROM funding_requests
+WHERE account_id = sqlc.arg(account_id)
+AND (sqlc.narg(before_id)::BIGINT IS NULL OR id < sqlc.narg(before_id))
+ORDER BY id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: CountPendingFundingRequests :one
+SELECT COUNT(*) FROM funding_requests
+WHERE account_id = $1 AND status = 'pending';
+
+-- name: DeleteAllFundingRequests :exec
+DELETE FROM funding_requests;
\ No newline at end of file
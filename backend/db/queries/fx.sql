@@ -0,0 +1,43 @@
+-- name: CreateFxRate :one
+INSERT INTO fx_rates (
+    base_currency,
+    quote_currency,
+    rate,
+    source
+) VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: GetFxRateByID :one
+SELECT * FROM fx_rates WHERE id = $1;
+
+-- name: CreateFxQuote :one
+INSERT INTO fx_quotes (
+    user_id,
+    from_account_id,
+    to_account_id,
+    rate_id,
+    sell_amount,
+    sell_currency,
+    buy_amount,
+    buy_currency,
+    fee_amount,
+    spread_amount,
+    rate,
+    expires_at
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING *;
+
+-- name: GetFxQuoteByID :one
+SELECT * FROM fx_quotes WHERE id = $1;
+
+-- name: GetFxQuoteForUpdate :one
+SELECT * FROM fx_quotes WHERE id = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: MarkFxQuoteExecuted :one
+UPDATE fx_quotes SET journal_id = $1, executed_at = now()
+WHERE id = $2 RETURNING *;
+
+-- name: DeleteAllFxQuotes :exec
+DELETE FROM fx_quotes;
+
+-- name: DeleteAllFxRates :exec
+DELETE FROM fx_rates;
\ No newline at end of file
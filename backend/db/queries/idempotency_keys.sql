@@ -0,0 +1,27 @@
+-- name: ClaimIdempotencyKey :one
+INSERT INTO idempotency_keys (
+    user_id,
+    key,
+    request_hash
+) VALUES (sqlc.arg(user_id), sqlc.arg(key), sqlc.arg(request_hash))
+ON CONFLICT (user_id, key) DO UPDATE
+SET request_hash = EXCLUDED.request_hash,
+    response_status = NULL,
+    response_body = NULL,
+    created_at = now(),
+    completed_at = NULL
+WHERE idempotency_keys.created_at < sqlc.arg(expired_before)
+RETURNING *;
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys WHERE user_id = $1 AND key = $2 LIMIT 1;
+
+-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys SET response_status = $1, response_body = $2, completed_at = now()
+WHERE id = $3;
+
+-- name: DeleteIdempotencyKey :exec
+DELETE FROM idempotency_keys WHERE id = $1;
+
+-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys WHERE created_at < $1;
\ No newline at end of file
@@ -0,0 +1,19 @@
+-- name: CreateJournal :one
+INSERT INTO journals (
+    kind,
+    description
+) VALUES ($1, $2) RETURNING *;
+
+-- name: GetJournalByID :one
+SELECT * FROM journals WHERE id = $1;
+
+-- name: ListUnbalancedJournals :many
+SELECT e.journal_id, a.currency, SUM(e.amount)::BIGINT AS total
+FROM entries e
+JOIN accounts a ON a.id = e.account_id
+GROUP BY e.journal_id, a.currency
+HAVING SUM(e.amount) <> 0
+ORDER BY e.journal_id;
+
+-- name: DeleteAllJournals :exec
+DELETE FROM journals;
\ No newline at end of file
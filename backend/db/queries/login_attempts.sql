@@ -0,0 +1,27 @@
+-- name: GetLoginAttempt :one
+SELECT * FROM login_attempts WHERE key = $1 LIMIT 1;
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_attempts (
+    key,
+    failures,
+    last_failed_at
+) VALUES (sqlc.arg(key), 1, sqlc.arg(failed_at))
+ON CONFLICT (key) DO UPDATE SET
+    failures = CASE
+        WHEN login_attempts.last_failed_at < sqlc.arg(window_start) THEN 1
+        ELSE login_attempts.failures + 1
+    END,
+    last_failed_at = EXCLUDED.last_failed_at
+RETURNING *;
+
+-- name: LockLoginAttempt :exec
+UPDATE login_attempts SET locked_until = $1 WHERE key = $2;
+
+-- name: DeleteLoginAttempt :exec
+DELETE FROM login_attempts WHERE key = $1;
+
+-- name: DeleteStaleLoginAttempts :execrows
+DELETE FROM login_attempts
+WHERE last_failed_at < $1
+AND (locked_until IS NULL OR locked_until < now());
\ No newline at end of file
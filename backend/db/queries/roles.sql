@@ -0,0 +1,38 @@
+-- name: ListRoles :many
+SELECT * FROM roles ORDER BY name;
+
+-- name: GetRoleByName :one
+SELECT * FROM roles WHERE name = $1 LIMIT 1;
+
+-- name: ListRolePermissions :many
+SELECT role_id, permission FROM role_permissions
+ORDER BY role_id, permission;
+
+-- name: ListUserRoles :many
+SELECT r.* FROM roles r
+JOIN user_roles ur ON ur.role_id = r.id
+WHERE ur.user_id = $1
+ORDER BY r.name;
+
+-- name: ListUserPermissions :many
+SELECT DISTINCT rp.permission FROM role_permissions rp
+JOIN user_roles ur ON ur.role_id = rp.role_id
+WHERE ur.user_id = $1
+ORDER BY rp.permission;
+
+-- name: GrantUserRole :execrows
+INSERT INTO user_roles (
+    user_id,
+    role_id,
+    granted_by
+) VALUES ($1, $2, $3)
+ON CONFLICT (user_id, role_id) DO NOTHING;
+
+-- name: RevokeUserRole :execrows
+DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2;
+
+-- name: CountUsersWithRoleForUpdate :one
+SELECT COUNT(*) FROM (
+    SELECT user_id FROM user_roles WHERE role_id = $1
+    FOR UPDATE
+) holders;
\ No newline at end of file
@@ -0,0 +1,36 @@
+-- name: CreateSession :one
+INSERT INTO sessions (
+    user_id,
+    expires_at
+) VALUES ($1, $2) RETURNING *;
+
+-- name: GetSessionByID :one
+SELECT * FROM sessions WHERE id = $1 LIMIT 1;
+
+-- name: RevokeSession :exec
+UPDATE sessions SET revoked_at = now() 
+WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: ExtendSession :exec
+UPDATE sessions SET expires_at = $2 WHERE id = $1;
+
+-- name: SetSessionStepUp :exec
+UPDATE sessions SET step_up_at = now() WHERE id = $1;
+
+-- name: RevokeUserSessions :exec
+UPDATE sessions SET revoked_at = now()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (
+    session_id,
+    token_hash,
+    expires_at
+) VALUES ($1, $2, $3) RETURNING *;
+
+-- name: GetRefreshTokenForUpdate :one
+SELECT * FROM refresh_tokens WHERE token_hash = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: MarkRefreshTokenUsed :exec
+UPDATE refresh_tokens SET used_at = now() WHERE id = $1;
\ No newline at end of file
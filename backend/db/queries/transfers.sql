@@ -1,9 +1,10 @@
 -- name: CreateTransfer :one
 INSERT INTO transfers (
+    journal_id,
     from_account_id,
     to_account_id,
     amount
-) VALUES ($1, $2, $3) RETURNING *;  
+) VALUES ($1, $2, $3, $4) RETURNING *;  
 
 -- name: GetTransferByID :one
 SELECT * FROM transfers WHERE id = $1;
@@ -15,8 +16,27 @@ SELECT * FROM transfers WHERE from_account_id = $1;
 SELECT * FROM transfers WHERE to_account_id = $1;
 
 -- name: ListTransfers :many
-SELECT * FROM transfers ORDER BY id 
-LIMIT $1 OFFSET $2;
+SELECT t.id, t.from_account_id, t.to_account_id, t.amount, t.created_at, t.journal_id, a.currency
+FROM transfers t
+JOIN accounts a ON a.id = t.from_account_id
+WHERE (sqlc.narg(before_id)::BIGINT IS NULL OR t.id < sqlc.narg(before_id))
+AND (sqlc.narg(account_id)::INT IS NULL OR t.from_account_id = sqlc.narg(account_id) OR t.to_account_id = sqlc.narg(account_id))
+AND (sqlc.narg(currency)::VARCHAR IS NULL OR a.currency = sqlc.narg(currency))
+AND (sqlc.narg(created_from)::TIMESTAMPTZ IS NULL OR t.created_at >= sqlc.narg(created_from))
+AND (sqlc.narg(created_to)::TIMESTAMPTZ IS NULL OR t.created_at < sqlc.narg(created_to))
+ORDER BY t.id DESC
+LIMIT sqlc.arg(page_limit);
+
+-- name: ListTransfersByAccountIDs :many
+SELECT * FROM transfers
+WHERE (from_account_id = ANY(sqlc.arg(account_ids)::INT[]) OR to_account_id = ANY(sqlc.arg(account_ids)::INT[]))
+AND (sqlc.narg(before_id)::BIGINT IS NULL OR id < sqlc.narg(before_id))
+AND (sqlc.narg(created_from)::TIMESTAMPTZ IS NULL OR created_at >= sqlc.narg(created_from))
+AND (sqlc.narg(created_to)::TIMESTAMPTZ IS NULL OR created_at < sqlc.narg(created_to))
+AND (sqlc.narg(min_amount)::BIGINT IS NULL OR amount >= sqlc.narg(min_amount))
+AND (sqlc.narg(max_amount)::BIGINT IS NULL OR amount <= sqlc.narg(max_amount))
+ORDER BY id DESC
+LIMIT sqlc.arg(page_limit);
 
 -- name: DeleteAllTransfers :exec
 DELETE FROM transfers;
\ No newline at end of file
@@ -0,0 +1,71 @@
+-- name: CreatePendingTOTPCredential :one
+INSERT INTO totp_credentials (
+    user_id,
+    secret
+) VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET
+    secret = EXCLUDED.secret,
+    last_used_step = 0,
+    failed_attempts = 0,
+    last_failed_at = NULL,
+    created_at = now()
+WHERE totp_credentials.confirmed_at IS NULL
+RETURNING *;
+
+-- name: GetTOTPCredential :one
+SELECT * FROM totp_credentials WHERE user_id = $1 LIMIT 1;
+
+-- name: GetTOTPCredentialForUpdate :one
+SELECT * FROM totp_credentials WHERE user_id = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: ConfirmTOTPCredential :exec
+UPDATE totp_credentials SET confirmed_at = now(), last_used_step = $2
+WHERE user_id = $1;
+
+-- name: RecordSecondFactorSuccess :exec
+UPDATE totp_credentials SET
+    last_used_step = GREATEST(last_used_step, sqlc.arg(last_used_step)::BIGINT),
+    failed_attempts = 0,
+    last_failed_at = NULL
+WHERE user_id = sqlc.arg(user_id);
+
+-- name: RecordSecondFactorFailure :exec
+UPDATE totp_credentials SET
+    failed_attempts = failed_attempts + 1,
+    last_failed_at = now()
+WHERE user_id = $1;
+
+-- name: DeleteTOTPCredential :exec
+DELETE FROM totp_credentials WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (
+    user_id,
+    code_hash
+) VALUES ($1, $2);
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes SET used_at = now()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*) FROM recovery_codes
+WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes WHERE user_id = $1;
+
+-- name: CreateLoginChallenge :one
+INSERT INTO login_challenges (
+    user_id,
+    token_hash,
+    expires_at
+) VALUES ($1, $2, $3) RETURNING *;
+
+-- name: GetLoginChallengeForUpdate :one
+SELECT * FROM login_challenges WHERE token_hash = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: UseLoginChallenge :exec
+UPDATE login_challenges SET used_at = now() WHERE id = $1;
\ No newline at end of file
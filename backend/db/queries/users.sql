@@ -11,13 +11,21 @@ SELECT * FROM users WHERE id = $1 LIMIT 1;
 SELECT * FROM users WHERE email = $1 LIMIT 1;
 
 -- name: ListUsers :many
-SELECT * FROM users ORDER BY id 
-LIMIT $1 OFFSET $2;
+SELECT * FROM users
+WHERE (sqlc.narg(before_id)::BIGINT IS NULL OR id < sqlc.narg(before_id))
+AND (sqlc.narg(created_from)::TIMESTAMPTZ IS NULL OR created_at >= sqlc.narg(created_from))
+AND (sqlc.narg(created_to)::TIMESTAMPTZ IS NULL OR created_at < sqlc.narg(created_to))
+ORDER BY id DESC
+LIMIT sqlc.arg(page_limit);
 
 -- name: UpdateUserPassword :one
 UPDATE users SET hashed_password = $1, updated_at = $2 
 WHERE id = $3 RETURNING *;
 
+-- name: MarkUserEmailVerified :one
+UPDATE users SET email_verified_at = now(), updated_at = now()
+WHERE id = $1 RETURNING *;
+
 -- name: DeleteUser :exec
 DELETE FROM users WHERE id = $1;
 
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+var (
+	ErrAccountFrozen      = errors.New("account is frozen")
+	ErrAccountDormant     = errors.New("account is dormant")
+	ErrAccountClosed      = errors.New("account is closed")
+	ErrAccountNotEmpty    = errors.New("account balance must be zero to close it")
+	ErrAccountBusy        = errors.New("account has pending funding requests")
+	ErrInvalidTransition  = errors.New("account status transition is not allowed")
+	ErrSystemAccountState = errors.New("system accounts are always active")
+)
+
+const (
+	AccountStatusActive  = "active"
+	AccountStatusFrozen  = "frozen"
+	AccountStatusDormant = "dormant"
+	AccountStatusClosed  = "closed"
+)
+
+// accountTransitions lists where each status may move to. A frozen account
+// has to be unfrozen before anything else happens to it, and a closed
+// account can only be reopened.
+var accountTransitions = map[string][]string{
+	AccountStatusActive:  {AccountStatusFrozen, AccountStatusDormant, AccountStatusClosed},
+	AccountStatusFrozen:  {AccountStatusActive},
+	AccountStatusDormant: {AccountStatusActive, AccountStatusFrozen, AccountStatusClosed},
+	AccountStatusClosed:  {AccountStatusActive},
+}
+
+// CanTransition reports whether an account may move from one status to
+// another.
+func CanTransition(from string, to string) bool {
+	for _, next := range accountTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPosting enforces the account status on one posting: closed accounts
+// take nothing, frozen and dormant accounts take credits only.
+func checkPosting(account Account, debit bool) error {
+	switch account.Status {
+	case AccountStatusClosed:
+		return fmt.Errorf("%w: account %d", ErrAccountClosed, account.ID)
+	case AccountStatusFrozen:
+		if debit {
+			return fmt.Errorf("%w: account %d", ErrAccountFrozen, account.ID)
+		}
+	case AccountStatusDormant:
+		if debit {
+			return fmt.Errorf("%w: account %d", ErrAccountDormant, account.ID)
+		}
+	}
+	return nil
+}
+
+type ChangeAccountStatusParams struct {
+	AccountID int64
+	Status    string
+	Reason    string
+	// ActorID is the user who made the change, unset for the system.
+	ActorID sql.NullInt64
+	// FromStatuses, when set, narrows the statuses the account may move
+	// from. It is checked under the row lock, so a concurrent change can't
+	// slip in between the check and the update.
+	FromStatuses []string
+}
+
+// ChangeAccountStatusTx moves an account to a new status and records the
+// transition. The account row is locked like postJournal locks it, so a
+// status change and a posting on the same account never interleave.
+func (store *Store) ChangeAccountStatusTx(ctx context.Context, arg ChangeAccountStatusParams) (Account, error) {
+	var account Account
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		current, err := q.GetAccountForUpdate(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		if current.Kind != AccountKindUser {
+			return ErrSystemAccountState
+		}
+
+		if len(arg.FromStatuses) > 0 && !slices.Contains(arg.FromStatuses, current.Status) {
+			return statusError(current)
+		}
+
+		if !CanTransition(current.Status, arg.Status) {
+			return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, current.Status, arg.Status)
+		}
+
+		if arg.Status == AccountStatusClosed {
+			if current.Balance != 0 {
+				return ErrAccountNotEmpty
+			}
+
+			pending, err := q.CountPendingFundingRequests(ctx, current.ID)
+			if err != nil {
+				return err
+			}
+			if pending > 0 {
+				return ErrAccountBusy
+			}
+		}
+
+		account, err = q.SetAccountStatus(ctx, SetAccountStatusParams{
+			Status:       arg.Status,
+			StatusReason: arg.Reason,
+			ID:           current.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = q.CreateAccountStatusChange(ctx, CreateAccountStatusChangeParams{
+			AccountID:  current.ID,
+			FromStatus: current.Status,
+			ToStatus:   arg.Status,
+			Reason:     arg.Reason,
+			ActorID:    arg.ActorID,
+		})
+		if err != nil {
+			return err
+		}
+
+		return appendAudit(ctx, q, AuditRecord{
+			Action:     "account.status_change",
+			TargetType: "account",
+			TargetID:   strconv.FormatInt(account.ID, 10),
+			Before:     current,
+			After:      account,
+		})
+	})
+
+	return account, err
+}
+
+// statusError explains why an account in its current status can't be
+// changed by the caller.
+func statusError(account Account) error {
+	switch account.Status {
+	case AccountStatusFrozen:
+		return ErrAccountFrozen
+	case AccountStatusDormant:
+		return ErrAccountDormant
+	case AccountStatusClosed:
+		return ErrAccountClosed
+	}
+	return fmt.Errorf("%w: from %s", ErrInvalidTransition, account.Status)
+}
@@ -7,18 +7,48 @@ package db
 
 import (
 	"context"
+	"database/sql"
+
+	"github/kasho/backend/utils"
 )
 
+const addAccountBalance = `-- name: AddAccountBalance :one
+UPDATE accounts SET balance = balance + $1 
+WHERE id = $2 RETURNING id, user_id, balance, currency, created_at, kind, status, status_reason, closed_at
+`
+
+type AddAccountBalanceParams struct {
+	Amount utils.Amount `json:"amount"`
+	ID     int64        `json:"id"`
+}
+
+func (q *Queries) AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, addAccountBalance, arg.Amount, arg.ID)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.Kind,
+		&i.Status,
+		&i.StatusReason,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
 const createAccount = `-- name: CreateAccount :one
 INSERT INTO accounts (
     user_id,
     currency
-) VALUES ($1, $2) RETURNING id, user_id, balance, currency, created_at
+) VALUES ($1, $2) RETURNING id, user_id, balance, currency, created_at, kind, status, status_reason, closed_at
 `
 
 type CreateAccountParams struct {
-	UserID   int32  `json:"user_id"`
-	Currency string `json:"currency"`
+	UserID   sql.NullInt32 `json:"user_id"`
+	Currency string        `json:"currency"`
 }
 
 func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error) {
@@ -30,16 +60,53 @@ func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.Kind,
+		&i.Status,
+		&i.StatusReason,
+		&i.ClosedAt,
 	)
 	return i, err
 }
 
-const deleteAccount = `-- name: DeleteAccount :exec
-DELETE FROM accounts WHERE id = $1
+const createAccountStatusChange = `-- name: CreateAccountStatusChange :one
+INSERT INTO account_status_changes (
+    account_id,
+    from_status,
+    to_status,
+    reason,
+    actor_id
+) VALUES ($1, $2, $3, $4, $5) RETURNING id, account_id, from_status, to_status, reason, actor_id, created_at
 `
 
-func (q *Queries) DeleteAccount(ctx context.Context, id int64) error {
-	_, err := q.db.ExecContext(ctx, deleteAccount, id)
+type CreateAccountStatusChangeParams struct {
+	AccountID  int64         `json:"account_id"`
+	FromStatus string        `json:"from_status"`
+	ToStatus   string        `json:"to_status"`
+	Reason     string        `json:"reason"`
+	ActorID    sql.NullInt64 `json:"actor_id"`
+}
+
+func (q *Queries) CreateAccountStatusChange(ctx context.Context, arg CreateAccountStatusChangeParams) (AccountStatusChange, error) {
+	row := q.db.QueryRowContext(ctx, createAccountStatusChange, arg.AccountID, arg.FromStatus, arg.ToStatus, arg.Reason, arg.ActorID)
+	var i AccountStatusChange
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.FromStatus,
+		&i.ToStatus,
+		&i.Reason,
+		&i.ActorID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteAllAccountStatusChanges = `-- name: DeleteAllAccountStatusChanges :exec
+DELETE FROM account_status_changes
+`
+
+func (q *Queries) DeleteAllAccountStatusChanges(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteAllAccountStatusChanges)
 	return err
 }
 
@@ -53,7 +120,7 @@ func (q *Queries) DeleteAllAccounts(ctx context.Context) error {
 }
 
 const getAccountByID = `-- name: GetAccountByID :one
-SELECT id, user_id, balance, currency, created_at FROM accounts WHERE id = $1
+SELECT id, user_id, balance, currency, created_at, kind, status, status_reason, closed_at FROM accounts WHERE id = $1
 `
 
 func (q *Queries) GetAccountByID(ctx context.Context, id int64) (Account, error) {
@@ -65,15 +132,19 @@ func (q *Queries) GetAccountByID(ctx context.Context, id int64) (Account, error)
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.Kind,
+		&i.Status,
+		&i.StatusReason,
+		&i.ClosedAt,
 	)
 	return i, err
 }
 
 const getAccountByUserID = `-- name: GetAccountByUserID :many
-SELECT id, user_id, balance, currency, created_at FROM accounts WHERE user_id = $1
+SELECT id, user_id, balance, currency, created_at, kind, status, status_reason, closed_at FROM accounts WHERE user_id = $1
 `
 
-func (q *Queries) GetAccountByUserID(ctx context.Context, userID int32) ([]Account, error) {
+func (q *Queries) GetAccountByUserID(ctx context.Context, userID sql.NullInt32) ([]Account, error) {
 	rows, err := q.db.QueryContext(ctx, getAccountByUserID, userID)
 	if err != nil {
 		return nil, err
@@ -88,6 +159,68 @@ func (q *Queries) GetAccountByUserID(ctx context.Context, userID int32) ([]Accou
 			&i.Balance,
 			&i.Currency,
 			&i.CreatedAt,
+			&i.Kind,
+			&i.Status,
+			&i.StatusReason,
+			&i.ClosedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAccountForUpdate = `-- name: GetAccountForUpdate :one
+SELECT id, user_id, balance, currency, created_at, kind, status, status_reason, closed_at FROM accounts WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) GetAccountForUpdate(ctx context.Context, id int64) (Account, error) {
+	row := q.db.QueryRowContext(ctx, getAccountForUpdate, id)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.Kind,
+		&i.Status,
+		&i.StatusReason,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
+const listAccountStatusChanges = `-- name: ListAccountStatusChanges :many
+SELECT id, account_id, from_status, to_status, reason, actor_id, created_at FROM account_status_changes WHERE account_id = $1
+ORDER BY id DESC
+`
+
+func (q *Queries) ListAccountStatusChanges(ctx context.Context, accountID int64) ([]AccountStatusChange, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountStatusChanges, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AccountStatusChange{}
+	for rows.Next() {
+		var i AccountStatusChange
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.FromStatus,
+			&i.ToStatus,
+			&i.Reason,
+			&i.ActorID,
+			&i.CreatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -103,17 +236,88 @@ func (q *Queries) GetAccountByUserID(ctx context.Context, userID int32) ([]Accou
 }
 
 const listAccounts = `-- name: ListAccounts :many
-SELECT id, user_id, balance, currency, created_at FROM accounts ORDER BY id 
-LIMIT $1 OFFSET $2
+SELECT id, user_id, balance, currency, created_at, kind, status, status_reason, closed_at FROM accounts
+WHERE ($1::BIGINT IS NULL OR id < $1)
+AND ($2::INT IS NULL OR user_id = $2)
+AND ($3::VARCHAR IS NULL OR currency = $3)
+AND ($4::VARCHAR IS NULL OR status = $4)
+AND ($5::VARCHAR IS NULL OR kind = $5)
+AND ($6::TIMESTAMPTZ IS NULL OR created_at >= $6)
+AND ($7::TIMESTAMPTZ IS NULL OR created_at < $7)
+ORDER BY id DESC
+LIMIT $8
 `
 
 type ListAccountsParams struct {
-	Limit  int32 `json:"limit"`
-	Offset int32 `json:"offset"`
+	BeforeID    sql.NullInt64  `json:"before_id"`
+	UserID      sql.NullInt32  `json:"user_id"`
+	Currency    sql.NullString `json:"currency"`
+	Status      sql.NullString `json:"status"`
+	Kind        sql.NullString `json:"kind"`
+	CreatedFrom sql.NullTime   `json:"created_from"`
+	CreatedTo   sql.NullTime   `json:"created_to"`
+	PageLimit   int32          `json:"page_limit"`
 }
 
 func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error) {
-	rows, err := q.db.QueryContext(ctx, listAccounts, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, listAccounts, arg.BeforeID, arg.UserID, arg.Currency, arg.Status, arg.Kind, arg.CreatedFrom, arg.CreatedTo, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.Kind,
+			&i.Status,
+			&i.StatusReason,
+			&i.ClosedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAccountsByUserID = `-- name: ListAccountsByUserID :many
+SELECT id, user_id, balance, currency, created_at, kind, status, status_reason, closed_at FROM accounts
+WHERE user_id = $1
+AND ($2::BIGINT IS NULL OR id < $2)
+AND ($3::VARCHAR IS NULL OR currency = $3)
+AND ($4::TIMESTAMPTZ IS NULL OR created_at >= $4)
+AND ($5::TIMESTAMPTZ IS NULL OR created_at < $5)
+AND ($6::BIGINT IS NULL OR balance >= $6)
+AND ($7::BIGINT IS NULL OR balance <= $7)
+ORDER BY id DESC
+LIMIT $8
+`
+
+type ListAccountsByUserIDParams struct {
+	UserID      sql.NullInt32  `json:"user_id"`
+	BeforeID    sql.NullInt64  `json:"before_id"`
+	Currency    sql.NullString `json:"currency"`
+	CreatedFrom sql.NullTime   `json:"created_from"`
+	CreatedTo   sql.NullTime   `json:"created_to"`
+	MinBalance  sql.NullInt64  `json:"min_balance"`
+	MaxBalance  sql.NullInt64  `json:"max_balance"`
+	PageLimit   int32          `json:"page_limit"`
+}
+
+func (q *Queries) ListAccountsByUserID(ctx context.Context, arg ListAccountsByUserIDParams) ([]Account, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountsByUserID, arg.UserID, arg.BeforeID, arg.Currency, arg.CreatedFrom, arg.CreatedTo, arg.MinBalance, arg.MaxBalance, arg.PageLimit)
 	if err != nil {
 		return nil, err
 	}
@@ -127,6 +331,52 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 			&i.Balance,
 			&i.Currency,
 			&i.CreatedAt,
+			&i.Kind,
+			&i.Status,
+			&i.StatusReason,
+			&i.ClosedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnreconciledAccounts = `-- name: ListUnreconciledAccounts :many
+SELECT a.id, a.balance, COALESCE(SUM(e.amount), 0)::BIGINT AS entries_total
+FROM accounts a
+LEFT JOIN entries e ON e.account_id = a.id
+GROUP BY a.id
+HAVING a.balance <> COALESCE(SUM(e.amount), 0)
+ORDER BY a.id
+`
+
+type ListUnreconciledAccountsRow struct {
+	ID           int64        `json:"id"`
+	Balance      utils.Amount `json:"balance"`
+	EntriesTotal int64        `json:"entries_total"`
+}
+
+func (q *Queries) ListUnreconciledAccounts(ctx context.Context) ([]ListUnreconciledAccountsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUnreconciledAccounts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListUnreconciledAccountsRow{}
+	for rows.Next() {
+		var i ListUnreconciledAccountsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Balance,
+			&i.EntriesTotal,
 		); err != nil {
 			return nil, err
 		}
@@ -141,17 +391,52 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 	return items, nil
 }
 
-const updateAccountBalance = `-- name: UpdateAccountBalance :one
-UPDATE accounts SET balance = $1 WHERE id = $2 RETURNING id, user_id, balance, currency, created_at
+const setAccountStatus = `-- name: SetAccountStatus :one
+UPDATE accounts SET status = $1, status_reason = $2,
+closed_at = CASE WHEN $1::VARCHAR = 'closed' THEN now() END
+WHERE id = $3 RETURNING id, user_id, balance, currency, created_at, kind, status, status_reason, closed_at
 `
 
-type UpdateAccountBalanceParams struct {
-	Balance float64 `json:"balance"`
-	ID      int64   `json:"id"`
+type SetAccountStatusParams struct {
+	Status       string `json:"status"`
+	StatusReason string `json:"status_reason"`
+	ID           int64  `json:"id"`
+}
+
+func (q *Queries) SetAccountStatus(ctx context.Context, arg SetAccountStatusParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, setAccountStatus, arg.Status, arg.StatusReason, arg.ID)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.Kind,
+		&i.Status,
+		&i.StatusReason,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
+const upsertSystemAccount = `-- name: UpsertSystemAccount :one
+INSERT INTO accounts (
+    kind,
+    currency
+) VALUES ($1, $2)
+ON CONFLICT (kind, currency) WHERE kind <> 'user'
+DO UPDATE SET kind = EXCLUDED.kind
+RETURNING id, user_id, balance, currency, created_at, kind, status, status_reason, closed_at
+`
+
+type UpsertSystemAccountParams struct {
+	Kind     string `json:"kind"`
+	Currency string `json:"currency"`
 }
 
-func (q *Queries) UpdateAccountBalance(ctx context.Context, arg UpdateAccountBalanceParams) (Account, error) {
-	row := q.db.QueryRowContext(ctx, updateAccountBalance, arg.Balance, arg.ID)
+func (q *Queries) UpsertSystemAccount(ctx context.Context, arg UpsertSystemAccountParams) (Account, error) {
+	row := q.db.QueryRowContext(ctx, upsertSystemAccount, arg.Kind, arg.Currency)
 	var i Account
 	err := row.Scan(
 		&i.ID,
@@ -159,6 +444,10 @@ func (q *Queries) UpdateAccountBalance(ctx context.Context, arg UpdateAccountBal
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.Kind,
+		&i.Status,
+		&i.StatusReason,
+		&i.ClosedAt,
 	)
 	return i, err
 }
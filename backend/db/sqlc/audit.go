@@ -0,0 +1,182 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+	"unicode/utf8"
+
+	"github/kasho/backend/audit"
+)
+
+// AuditRecord describes one change to be written to the audit log. Before
+// and After are marshalled to JSON; either may be nil.
+type AuditRecord struct {
+	Action     string
+	TargetType string
+	TargetID   string
+	Before     any
+	After      any
+}
+
+// appendAudit writes record to the audit log as part of the transaction of
+// q, so the change and its record commit together. It serialises writers on
+// an advisory lock to keep the chain linear, and the lock is held until
+// commit: call it last in the transaction, after every row lock is taken.
+func appendAudit(ctx context.Context, q *Queries, record AuditRecord) error {
+	before, err := json.Marshal(record.Before)
+	if err != nil {
+		return err
+	}
+
+	after, err := json.Marshal(record.After)
+	if err != nil {
+		return err
+	}
+
+	actor := audit.ActorFrom(ctx)
+
+	if err := q.LockAuditChain(ctx); err != nil {
+		return err
+	}
+
+	prevHash, err := q.GetLastAuditHash(ctx)
+	if err == sql.ErrNoRows {
+		prevHash = audit.GenesisHash
+	} else if err != nil {
+		return err
+	}
+
+	r := audit.Record{
+		ActorID:    actor.UserID,
+		Action:     record.Action,
+		TargetType: record.TargetType,
+		TargetID:   record.TargetID,
+		Before:     before,
+		After:      after,
+		IP:         truncate(actor.IP, 64),
+		UserAgent:  truncate(actor.UserAgent, 256),
+		RequestID:  truncate(actor.RequestID, 64),
+		CreatedAt:  time.Now().UTC().Truncate(time.Microsecond),
+	}
+
+	_, err = q.CreateAuditEvent(ctx, CreateAuditEventParams{
+		ActorID:     sql.NullInt64{Int64: r.ActorID, Valid: r.ActorID != 0},
+		Action:      r.Action,
+		TargetType:  r.TargetType,
+		TargetID:    r.TargetID,
+		BeforeState: r.Before,
+		AfterState:  r.After,
+		Ip:          r.IP,
+		UserAgent:   r.UserAgent,
+		RequestID:   r.RequestID,
+		CreatedAt:   r.CreatedAt,
+		PrevHash:    prevHash,
+		Hash:        r.Hash(prevHash),
+	})
+	return err
+}
+
+// AuditTx runs fn in a transaction and records the change it describes.
+// It is for changes that are a single query; Store methods that change
+// state record themselves.
+func (store *Store) AuditTx(ctx context.Context, fn func(q *Queries) (AuditRecord, error)) error {
+	return store.execTx(ctx, func(q *Queries) error {
+		record, err := fn(q)
+		if err != nil {
+			return err
+		}
+		return appendAudit(ctx, q, record)
+	})
+}
+
+// AuditState is what the audit log keeps of a user. The password hash is
+// left out.
+func (u User) AuditState() any {
+	state := struct {
+		ID              int64      `json:"id"`
+		Email           string     `json:"email"`
+		EmailVerifiedAt *time.Time `json:"email_verified_at"`
+		CreatedAt       time.Time  `json:"created_at"`
+	}{ID: u.ID, Email: u.Email, CreatedAt: u.CreatedAt}
+
+	if u.EmailVerifiedAt.Valid {
+		state.EmailVerifiedAt = &u.EmailVerifiedAt.Time
+	}
+
+	return state
+}
+
+// Link converts a stored event to its place in the chain.
+func (e AuditEvent) Link() audit.Link {
+	return audit.Link{
+		ID: e.ID,
+		Record: audit.Record{
+			ActorID:    e.ActorID.Int64,
+			Action:     e.Action,
+			TargetType: e.TargetType,
+			TargetID:   e.TargetID,
+			Before:     e.BeforeState,
+			After:      e.AfterState,
+			IP:         e.Ip,
+			UserAgent:  e.UserAgent,
+			RequestID:  e.RequestID,
+			CreatedAt:  e.CreatedAt,
+		},
+		PrevHash: e.PrevHash,
+		Hash:     e.Hash,
+	}
+}
+
+type AuditReport struct {
+	Checked int64         `json:"checked"`
+	Breaks  []audit.Break `json:"breaks"`
+}
+
+func (r AuditReport) OK() bool {
+	return len(r.Breaks) == 0
+}
+
+const auditVerifyBatch = 1000
+
+// VerifyAuditChain walks the whole audit log in id order and reports every
+// event that does not follow from the one before it.
+func (store *Store) VerifyAuditChain(ctx context.Context) (AuditReport, error) {
+	report := AuditReport{Breaks: []audit.Break{}}
+	prevHash := audit.GenesisHash
+	var afterID int64
+
+	for {
+		events, err := store.ListAuditEventsAfter(ctx, ListAuditEventsAfterParams{ID: afterID, Limit: auditVerifyBatch})
+		if err != nil {
+			return report, err
+		}
+
+		links := make([]audit.Link, 0, len(events))
+		for _, e := range events {
+			links = append(links, e.Link())
+		}
+
+		var breaks []audit.Break
+		prevHash, breaks = audit.Verify(prevHash, links)
+		report.Breaks = append(report.Breaks, breaks...)
+		report.Checked += int64(len(events))
+
+		if len(events) < auditVerifyBatch {
+			return report, nil
+		}
+		afterID = events[len(events)-1].ID
+	}
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
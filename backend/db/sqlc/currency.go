@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github/kasho/backend/utils"
+)
+
+// Registry converts the row to the form utils.Currencies holds.
+func (c Currency) Registry() utils.Currency {
+	return utils.Currency{
+		Code:         c.Code,
+		Numeric:      c.NumericCode,
+		Name:         c.Name,
+		Exponent:     int(c.Exponent),
+		Symbol:       c.Symbol,
+		Enabled:      c.Enabled,
+		MinAmount:    c.MinAmount,
+		MaxAmount:    c.MaxAmount,
+		StepUpAmount: c.StepUpAmount,
+	}
+}
+
+// LoadCurrencies reads the currencies table in registry form.
+func (store *Store) LoadCurrencies(ctx context.Context) ([]utils.Currency, error) {
+	rows, err := store.ListCurrencies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	currencies := make([]utils.Currency, 0, len(rows))
+	for _, row := range rows {
+		currencies = append(currencies, row.Registry())
+	}
+
+	return currencies, nil
+}
+
+// SyncCurrencies writes currencies managed in config to the currencies
+// table. Currencies not listed are left as they are.
+func (store *Store) SyncCurrencies(ctx context.Context, currencies []utils.Currency) error {
+	return store.execTx(ctx, func(q *Queries) error {
+		records := make([]AuditRecord, 0, len(currencies))
+
+		for _, c := range currencies {
+			var before any
+			current, err := q.GetCurrency(ctx, c.Code)
+			if err == nil {
+				before = current
+			} else if err != sql.ErrNoRows {
+				return err
+			}
+
+			after, err := q.UpsertCurrency(ctx, UpsertCurrencyParams{
+				Code:         c.Code,
+				NumericCode:  c.Numeric,
+				Name:         c.Name,
+				Exponent:     int32(c.Exponent),
+				Symbol:       c.Symbol,
+				Enabled:      c.Enabled,
+				MinAmount:    c.MinAmount,
+				MaxAmount:    c.MaxAmount,
+				StepUpAmount: c.StepUpAmount,
+			})
+			if err != nil {
+				return err
+			}
+
+			// Only changes are audited, not every startup.
+			if before != nil && current.Registry() == after.Registry() {
+				continue
+			}
+
+			records = append(records, AuditRecord{
+				Action:     "currency.sync",
+				TargetType: "currency",
+				TargetID:   c.Code,
+				Before:     before,
+				After:      after,
+			})
+		}
+
+		for _, record := range records {
+			if err := appendAudit(ctx, q, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrVerificationTokenInvalid = errors.New("email verification token is invalid or expired")
+	ErrEmailAlreadyVerified     = errors.New("email is already verified")
+)
+
+type RegisterUserParams struct {
+	Email          string
+	HashedPassword string
+	// The hash of the token emailed to the user to verify their address.
+	VerificationTokenHash string
+	VerificationExpiresAt time.Time
+}
+
+// RegisterUserTx creates an unverified user together with their first
+// email verification token.
+func (store *Store) RegisterUserTx(ctx context.Context, arg RegisterUserParams) (User, error) {
+	var user User
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		user, err = q.CreateUser(ctx, CreateUserParams{
+			Email:          arg.Email,
+			HashedPassword: arg.HashedPassword,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = q.CreateEmailVerificationToken(ctx, CreateEmailVerificationTokenParams{
+			UserID:    user.ID,
+			TokenHash: arg.VerificationTokenHash,
+			ExpiresAt: arg.VerificationExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		return appendAudit(ctx, q, AuditRecord{
+			Action:     "user.register",
+			TargetType: "user",
+			TargetID:   strconv.FormatInt(user.ID, 10),
+			After:      user.AuditState(),
+		})
+	})
+
+	return user, err
+}
+
+type RequestEmailVerificationParams struct {
+	UserID    int64
+	TokenHash string
+	ExpiresAt time.Time
+}
+
+// RequestEmailVerificationTx stores the hash of a new verification token
+// for a user whose email isn't verified yet. Earlier tokens stay valid until
+// they expire, so any of the emails sent can be used.
+func (store *Store) RequestEmailVerificationTx(ctx context.Context, arg RequestEmailVerificationParams) (EmailVerificationToken, error) {
+	var token EmailVerificationToken
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		user, err := q.GetUserByID(ctx, arg.UserID)
+		if err != nil {
+			return err
+		}
+		if user.EmailVerifiedAt.Valid {
+			return ErrEmailAlreadyVerified
+		}
+
+		token, err = q.CreateEmailVerificationToken(ctx, CreateEmailVerificationTokenParams{
+			UserID:    arg.UserID,
+			TokenHash: arg.TokenHash,
+			ExpiresAt: arg.ExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		return appendAudit(ctx, q, AuditRecord{
+			Action:     "user.email_verification_request",
+			TargetType: "user",
+			TargetID:   strconv.FormatInt(arg.UserID, 10),
+			After: struct {
+				ExpiresAt time.Time `json:"expires_at"`
+			}{token.ExpiresAt},
+		})
+	})
+
+	return token, err
+}
+
+// VerifyEmailTx marks the email of the token's user as verified and retires
+// their other verification tokens. The token row is locked, so two requests
+// racing with the same token can't both use it.
+func (store *Store) VerifyEmailTx(ctx context.Context, tokenHash string) (User, error) {
+	var user User
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		token, err := q.GetEmailVerificationTokenForUpdate(ctx, tokenHash)
+		if err == sql.ErrNoRows {
+			return ErrVerificationTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		if token.UsedAt.Valid || !time.Now().Before(token.ExpiresAt) {
+			return ErrVerificationTokenInvalid
+		}
+
+		before, err := q.GetUserByID(ctx, token.UserID)
+		if err != nil {
+			return err
+		}
+
+		user, err = q.MarkUserEmailVerified(ctx, token.UserID)
+		if err != nil {
+			return err
+		}
+
+		if err := q.UseEmailVerificationTokens(ctx, token.UserID); err != nil {
+			return err
+		}
+
+		return appendAudit(ctx, q, AuditRecord{
+			Action:     "user.email_verify",
+			TargetType: "user",
+			TargetID:   strconv.FormatInt(user.ID, 10),
+			Before:     before.AuditState(),
+			After:      user.AuditState(),
+		})
+	})
+
+	return user, err
+}
@@ -7,22 +7,30 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
+
+	"github/kasho/backend/utils"
 )
 
 const createEntry = `-- name: CreateEntry :one
 INSERT INTO entries (
+    journal_id,
     account_id,
-    amount
-) VALUES ($1, $2) RETURNING id, account_id, amount, type, created_at
+    amount,
+    type
+) VALUES ($1, $2, $3, $4) RETURNING id, account_id, amount, type, created_at, journal_id
 `
 
 type CreateEntryParams struct {
-	AccountID int32   `json:"account_id"`
-	Amount    float64 `json:"amount"`
+	JournalID int64        `json:"journal_id"`
+	AccountID int32        `json:"account_id"`
+	Amount    utils.Amount `json:"amount"`
+	Type      string       `json:"type"`
 }
 
 func (q *Queries) CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error) {
-	row := q.db.QueryRowContext(ctx, createEntry, arg.AccountID, arg.Amount)
+	row := q.db.QueryRowContext(ctx, createEntry, arg.JournalID, arg.AccountID, arg.Amount, arg.Type)
 	var i Entry
 	err := row.Scan(
 		&i.ID,
@@ -30,6 +38,7 @@ func (q *Queries) CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry
 		&i.Amount,
 		&i.Type,
 		&i.CreatedAt,
+		&i.JournalID,
 	)
 	return i, err
 }
@@ -44,7 +53,7 @@ func (q *Queries) DeleteAllEntries(ctx context.Context) error {
 }
 
 const getEntriesByAccountID = `-- name: GetEntriesByAccountID :many
-SELECT id, account_id, amount, type, created_at FROM entries WHERE account_id = $1
+SELECT id, account_id, amount, type, created_at, journal_id FROM entries WHERE account_id = $1
 `
 
 func (q *Queries) GetEntriesByAccountID(ctx context.Context, accountID int32) ([]Entry, error) {
@@ -62,6 +71,41 @@ func (q *Queries) GetEntriesByAccountID(ctx context.Context, accountID int32) ([
 			&i.Amount,
 			&i.Type,
 			&i.CreatedAt,
+			&i.JournalID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getEntriesByJournalID = `-- name: GetEntriesByJournalID :many
+SELECT id, account_id, amount, type, created_at, journal_id FROM entries WHERE journal_id = $1 ORDER BY id
+`
+
+func (q *Queries) GetEntriesByJournalID(ctx context.Context, journalID int64) ([]Entry, error) {
+	rows, err := q.db.QueryContext(ctx, getEntriesByJournalID, journalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Entry{}
+	for rows.Next() {
+		var i Entry
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.Type,
+			&i.CreatedAt,
+			&i.JournalID,
 		); err != nil {
 			return nil, err
 		}
@@ -77,7 +121,7 @@ func (q *Queries) GetEntriesByAccountID(ctx context.Context, accountID int32) ([
 }
 
 const getEntryByID = `-- name: GetEntryByID :one
-SELECT id, account_id, amount, type, created_at FROM entries WHERE id = $1
+SELECT id, account_id, amount, type, created_at, journal_id FROM entries WHERE id = $1
 `
 
 func (q *Queries) GetEntryByID(ctx context.Context, id int64) (Entry, error) {
@@ -89,22 +133,149 @@ func (q *Queries) GetEntryByID(ctx context.Context, id int64) (Entry, error) {
 		&i.Amount,
 		&i.Type,
 		&i.CreatedAt,
+		&i.JournalID,
 	)
 	return i, err
 }
 
+const listAccountTransactions = `-- name: ListAccountTransactions :many
+SELECT e.id, e.journal_id, e.amount, e.created_at, j.kind, j.description,
+    t.id AS transfer_id,
+    c.account_id AS counterparty_account_id,
+    ca.kind AS counterparty_kind
+FROM entries e
+JOIN journals j ON j.id = e.journal_id
+LEFT JOIN transfers t ON t.journal_id = e.journal_id
+LEFT JOIN LATERAL (
+    SELECT o.account_id FROM entries o
+    WHERE o.journal_id = e.journal_id AND o.account_id <> e.account_id
+    ORDER BY o.id
+    LIMIT 1
+) c ON true
+LEFT JOIN accounts ca ON ca.id = c.account_id
+WHERE e.account_id = $1
+AND ($2::BIGINT IS NULL OR e.id < $2)
+ORDER BY e.id DESC
+LIMIT $3
+`
+
+type ListAccountTransactionsParams struct {
+	AccountID int32         `json:"account_id"`
+	BeforeID  sql.NullInt64 `json:"before_id"`
+	PageLimit int32         `json:"page_limit"`
+}
+
+type ListAccountTransactionsRow struct {
+	ID                    int64          `json:"id"`
+	JournalID             int64          `json:"journal_id"`
+	Amount                utils.Amount   `json:"amount"`
+	CreatedAt             time.Time      `json:"created_at"`
+	Kind                  string         `json:"kind"`
+	Description           string         `json:"description"`
+	TransferID            sql.NullInt64  `json:"transfer_id"`
+	CounterpartyAccountID sql.NullInt32  `json:"counterparty_account_id"`
+	CounterpartyKind      sql.NullString `json:"counterparty_kind"`
+}
+
+func (q *Queries) ListAccountTransactions(ctx context.Context, arg ListAccountTransactionsParams) ([]ListAccountTransactionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listAccountTransactions, arg.AccountID, arg.BeforeID, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListAccountTransactionsRow{}
+	for rows.Next() {
+		var i ListAccountTransactionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.JournalID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.Kind,
+			&i.Description,
+			&i.TransferID,
+			&i.CounterpartyAccountID,
+			&i.CounterpartyKind,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listEntries = `-- name: ListEntries :many
-SELECT id, account_id, amount, type, created_at FROM entries ORDER BY id 
-LIMIT $1 OFFSET $2
+SELECT id, account_id, amount, type, created_at, journal_id FROM entries
+WHERE ($1::BIGINT IS NULL OR id < $1)
+ORDER BY id DESC
+LIMIT $2
 `
 
 type ListEntriesParams struct {
-	Limit  int32 `json:"limit"`
-	Offset int32 `json:"offset"`
+	BeforeID  sql.NullInt64 `json:"before_id"`
+	PageLimit int32         `json:"page_limit"`
 }
 
 func (q *Queries) ListEntries(ctx context.Context, arg ListEntriesParams) ([]Entry, error) {
-	rows, err := q.db.QueryContext(ctx, listEntries, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, listEntries, arg.BeforeID, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Entry{}
+	for rows.Next() {
+		var i Entry
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.Type,
+			&i.CreatedAt,
+			&i.JournalID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listEntriesByAccountID = `-- name: ListEntriesByAccountID :many
+SELECT id, account_id, amount, type, created_at, journal_id FROM entries
+WHERE account_id = $1
+AND ($2::BIGINT IS NULL OR id < $2)
+AND ($3::TIMESTAMPTZ IS NULL OR created_at >= $3)
+AND ($4::TIMESTAMPTZ IS NULL OR created_at < $4)
+AND ($5::BIGINT IS NULL OR ABS(amount) >= $5)
+AND ($6::BIGINT IS NULL OR ABS(amount) <= $6)
+ORDER BY id DESC
+LIMIT $7
+`
+
+type ListEntriesByAccountIDParams struct {
+	AccountID   int32         `json:"account_id"`
+	BeforeID    sql.NullInt64 `json:"before_id"`
+	CreatedFrom sql.NullTime  `json:"created_from"`
+	CreatedTo   sql.NullTime  `json:"created_to"`
+	MinAmount   sql.NullInt64 `json:"min_amount"`
+	MaxAmount   sql.NullInt64 `json:"max_amount"`
+	PageLimit   int32         `json:"page_limit"`
+}
+
+func (q *Queries) ListEntriesByAccountID(ctx context.Context, arg ListEntriesByAccountIDParams) ([]Entry, error) {
+	rows, err := q.db.QueryContext(ctx, listEntriesByAccountID, arg.AccountID, arg.BeforeID, arg.CreatedFrom, arg.CreatedTo, arg.MinAmount, arg.MaxAmount, arg.PageLimit)
 	if err != nil {
 		return nil, err
 	}
@@ -118,6 +289,7 @@ func (q *Queries) ListEntries(ctx context.Context, arg ListEntriesParams) ([]Ent
 			&i.Amount,
 			&i.Type,
 			&i.CreatedAt,
+			&i.JournalID,
 		); err != nil {
 			return nil, err
 		}
@@ -131,3 +303,21 @@ func (q *Queries) ListEntries(ctx context.Context, arg ListEntriesParams) ([]Ent
 	}
 	return items, nil
 }
+
+const sumEntriesAfter = `-- name: SumEntriesAfter :one
+SELECT COALESCE(SUM(amount), 0)::BIGINT AS total
+FROM entries
+WHERE account_id = $1 AND id > $2
+`
+
+type SumEntriesAfterParams struct {
+	AccountID int32 `json:"account_id"`
+	AfterID   int64 `json:"after_id"`
+}
+
+func (q *Queries) SumEntriesAfter(ctx context.Context, arg SumEntriesAfterParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, sumEntriesAfter, arg.AccountID, arg.AfterID)
+	var total int64
+	err := row.Scan(&total)
+	return total, err
+}
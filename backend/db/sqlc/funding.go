@@ -0,0 +1,214 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github/kasho/backend/utils"
+)
+
+var ErrFundingNotPending = errors.New("funding request is no longer pending")
+
+const AccountKindClearing = "clearing"
+
+const (
+	JournalKindDeposit            = "deposit"
+	JournalKindWithdrawal         = "withdrawal"
+	JournalKindWithdrawalReversal = "withdrawal_reversal"
+)
+
+const (
+	FundingDeposit    = "deposit"
+	FundingWithdrawal = "withdrawal"
+)
+
+const (
+	FundingStatusPending = "pending"
+	FundingStatusSettled = "settled"
+	FundingStatusFailed  = "failed"
+)
+
+type CreateFundingTxParams struct {
+	AccountID int64
+	Direction string
+	Amount    utils.Money
+	Provider  string
+}
+
+// CreateFundingTx records a pending deposit or withdrawal. A withdrawal
+// moves the money into the clearing account straight away so it cannot be
+// spent twice while the provider works on it.
+func (store *Store) CreateFundingTx(ctx context.Context, arg CreateFundingTxParams) (FundingRequest, error) {
+	var request FundingRequest
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		account, err := q.GetAccountByID(ctx, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		if account.Currency != arg.Amount.Currency {
+			return ErrCurrencyMismatch
+		}
+
+		var journalID sql.NullInt64
+
+		if arg.Direction == FundingWithdrawal {
+			journal, err := postClearing(ctx, q, arg.AccountID, arg.Amount, JournalKindWithdrawal,
+				fmt.Sprintf("withdrawal from account %d", arg.AccountID), true)
+			if err != nil {
+				return err
+			}
+			journalID = sql.NullInt64{Int64: journal.Journal.ID, Valid: true}
+		}
+
+		request, err = q.CreateFundingRequest(ctx, CreateFundingRequestParams{
+			AccountID: arg.AccountID,
+			Direction: arg.Direction,
+			Amount:    arg.Amount.Amount,
+			Currency:  arg.Amount.Currency,
+			Provider:  arg.Provider,
+			JournalID: journalID,
+		})
+		if err != nil {
+			return err
+		}
+
+		return appendAudit(ctx, q, fundingAudit("funding.create", nil, request))
+	})
+
+	return request, err
+}
+
+// SettleFundingTx completes a pending request. Deposits are credited to the
+// customer now; withdrawals already left the account when they were created.
+func (store *Store) SettleFundingTx(ctx context.Context, id int64) (FundingRequest, error) {
+	var request FundingRequest
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		pending, err := lockPendingFunding(ctx, q, id)
+		if err != nil {
+			return err
+		}
+
+		var journalID sql.NullInt64
+
+		if pending.Direction == FundingDeposit {
+			journal, err := postClearing(ctx, q, pending.AccountID, utils.NewMoney(pending.Amount, pending.Currency),
+				JournalKindDeposit, fmt.Sprintf("deposit to account %d", pending.AccountID), false)
+			if err != nil {
+				return err
+			}
+			journalID = sql.NullInt64{Int64: journal.Journal.ID, Valid: true}
+		}
+
+		request, err = q.SettleFundingRequest(ctx, SettleFundingRequestParams{
+			ID:        id,
+			JournalID: journalID,
+		})
+		if err != nil {
+			return err
+		}
+
+		return appendAudit(ctx, q, fundingAudit("funding.settle", pending, request))
+	})
+
+	return request, err
+}
+
+// FailFundingTx gives up on a pending request. Funds held for a withdrawal
+// are returned to the customer with a reversal journal.
+func (store *Store) FailFundingTx(ctx context.Context, id int64, reason string) (FundingRequest, error) {
+	var request FundingRequest
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		pending, err := lockPendingFunding(ctx, q, id)
+		if err != nil {
+			return err
+		}
+
+		var reversalID sql.NullInt64
+
+		if pending.Direction == FundingWithdrawal {
+			journal, err := postClearing(ctx, q, pending.AccountID, utils.NewMoney(pending.Amount, pending.Currency),
+				JournalKindWithdrawalReversal, fmt.Sprintf("reversal of withdrawal %d", pending.ID), false)
+			if err != nil {
+				return err
+			}
+			reversalID = sql.NullInt64{Int64: journal.Journal.ID, Valid: true}
+		}
+
+		request, err = q.FailFundingRequest(ctx, FailFundingRequestParams{
+			ID:                id,
+			FailureReason:     reason,
+			ReversalJournalID: reversalID,
+		})
+		if err != nil {
+			return err
+		}
+
+		return appendAudit(ctx, q, fundingAudit("funding.fail", pending, request))
+	})
+
+	return request, err
+}
+
+func fundingAudit(action string, before any, after FundingRequest) AuditRecord {
+	return AuditRecord{
+		Action:     action,
+		TargetType: "funding_request",
+		TargetID:   strconv.FormatInt(after.ID, 10),
+		Before:     before,
+		After:      after,
+	}
+}
+
+func lockPendingFunding(ctx context.Context, q *Queries, id int64) (FundingRequest, error) {
+	request, err := q.GetFundingRequestForUpdate(ctx, id)
+	if err != nil {
+		return request, err
+	}
+
+	if request.Status != FundingStatusPending {
+		return request, ErrFundingNotPending
+	}
+
+	return request, nil
+}
+
+// postClearing moves amount between a customer account and the clearing
+// account of its currency: out of the customer when debit is set, into the
+// customer otherwise.
+func postClearing(ctx context.Context, q *Queries, accountID int64, amount utils.Money, kind string, description string, debit bool) (JournalResult, error) {
+	clearing, err := q.UpsertSystemAccount(ctx, UpsertSystemAccountParams{
+		Kind:     AccountKindClearing,
+		Currency: amount.Currency,
+	})
+	if err != nil {
+		return JournalResult{}, err
+	}
+
+	customer := amount.Amount
+	if debit {
+		if customer, err = customer.Neg(); err != nil {
+			return JournalResult{}, err
+		}
+	}
+
+	other, err := customer.Neg()
+	if err != nil {
+		return JournalResult{}, err
+	}
+
+	return postJournal(ctx, q, JournalParams{
+		Kind:        kind,
+		Description: description,
+		Postings: []Posting{
+			{AccountID: accountID, Amount: customer},
+			{AccountID: clearing.ID, Amount: other},
+		},
+	})
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github/kasho/backend/utils"
+)
+
+var (
+	ErrQuoteExpired = errors.New("fx quote has expired")
+	ErrQuoteUsed    = errors.New("fx quote has already been executed")
+)
+
+// The FX house account of a currency takes the other side of every
+// conversion, so its balance is Kasho's position in that currency. Fees are
+// kept apart in the fee account so they can be told from the position.
+const (
+	AccountKindFX  = "fx"
+	AccountKindFee = "fee"
+)
+
+const JournalKindFXConversion = "fx_conversion"
+
+type ConvertTxParams struct {
+	QuoteID int64
+	UserID  int64
+}
+
+type ConvertTxResult struct {
+	Quote       FxQuote `json:"quote"`
+	Journal     Journal `json:"journal"`
+	FromAccount Account `json:"from_account"`
+	ToAccount   Account `json:"to_account"`
+}
+
+// ConvertTx executes a quote. The customer is debited the sell amount and
+// credited the buy amount; the FX house accounts of both currencies take the
+// other sides and the fee goes to the fee account of the sell currency. A
+// quote can only be executed once, by the user it was issued to, before it
+// expires.
+func (store *Store) ConvertTx(ctx context.Context, arg ConvertTxParams) (ConvertTxResult, error) {
+	var result ConvertTxResult
+
+	quote, err := store.GetFxQuoteByID(ctx, arg.QuoteID)
+	if err != nil {
+		return result, err
+	}
+
+	if quote.UserID != arg.UserID {
+		return result, sql.ErrNoRows
+	}
+
+	// House accounts are created before the transaction. Upserting them
+	// inside it would lock them in sell-then-buy order, which deadlocks with
+	// a conversion going the other way.
+	sellHouse, err := store.UpsertSystemAccount(ctx, UpsertSystemAccountParams{Kind: AccountKindFX, Currency: quote.SellCurrency})
+	if err != nil {
+		return result, err
+	}
+	buyHouse, err := store.UpsertSystemAccount(ctx, UpsertSystemAccountParams{Kind: AccountKindFX, Currency: quote.BuyCurrency})
+	if err != nil {
+		return result, err
+	}
+	fees, err := store.UpsertSystemAccount(ctx, UpsertSystemAccountParams{Kind: AccountKindFee, Currency: quote.SellCurrency})
+	if err != nil {
+		return result, err
+	}
+
+	err = store.execTx(ctx, func(q *Queries) error {
+		quote, err := q.GetFxQuoteForUpdate(ctx, arg.QuoteID)
+		if err != nil {
+			return err
+		}
+
+		if quote.ExecutedAt.Valid {
+			return ErrQuoteUsed
+		}
+
+		if !time.Now().Before(quote.ExpiresAt) {
+			return ErrQuoteExpired
+		}
+
+		sell, err := quote.SellAmount.Neg()
+		if err != nil {
+			return err
+		}
+
+		converted, err := quote.SellAmount.Sub(quote.FeeAmount)
+		if err != nil {
+			return err
+		}
+
+		buy, err := quote.BuyAmount.Neg()
+		if err != nil {
+			return err
+		}
+
+		postings := []Posting{
+			{AccountID: quote.FromAccountID, Amount: sell},
+			{AccountID: sellHouse.ID, Amount: converted},
+			{AccountID: buyHouse.ID, Amount: buy},
+			{AccountID: quote.ToAccountID, Amount: quote.BuyAmount},
+		}
+		if quote.FeeAmount > 0 {
+			postings = append(postings, Posting{AccountID: fees.ID, Amount: quote.FeeAmount})
+		}
+
+		journal, err := postJournal(ctx, q, JournalParams{
+			Kind: JournalKindFXConversion,
+			Description: fmt.Sprintf("conversion of %s to %s from account %d to account %d",
+				utils.NewMoney(quote.SellAmount, quote.SellCurrency), quote.BuyCurrency, quote.FromAccountID, quote.ToAccountID),
+			Postings: postings,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Quote, err = q.MarkFxQuoteExecuted(ctx, MarkFxQuoteExecutedParams{
+			JournalID: sql.NullInt64{Int64: journal.Journal.ID, Valid: true},
+			ID:        quote.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Journal = journal.Journal
+		result.FromAccount = journal.Accounts[quote.FromAccountID]
+		result.ToAccount = journal.Accounts[quote.ToAccountID]
+
+		return appendAudit(ctx, q, AuditRecord{
+			Action:     "fx.convert",
+			TargetType: "fx_quote",
+			TargetID:   strconv.FormatInt(quote.ID, 10),
+			Before:     quote,
+			After:      result.Quote,
+		})
+	})
+
+	return result, err
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github/kasho/backend/utils"
+)
+
+type AccountHistoryParams struct {
+	AccountID int64
+	// BeforeID resumes the history below the given entry id.
+	BeforeID sql.NullInt64
+	Limit    int32
+}
+
+// AccountTransaction is one entry of an account's history with the balance
+// the account had right after it was posted.
+type AccountTransaction struct {
+	ListAccountTransactionsRow
+	BalanceAfter utils.Amount `json:"balance_after"`
+}
+
+// AccountHistory returns an account's entries newest first. Running balances
+// are derived from the current balance minus every newer entry, read from a
+// single snapshot so that concurrent postings cannot skew them.
+func (store *Store) AccountHistory(ctx context.Context, arg AccountHistoryParams) ([]AccountTransaction, error) {
+	tx, err := store.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	q := New(store.wrap(tx))
+
+	rows, err := q.ListAccountTransactions(ctx, ListAccountTransactionsParams{
+		AccountID: int32(arg.AccountID),
+		BeforeID:  arg.BeforeID,
+		PageLimit: arg.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	history := []AccountTransaction{}
+	if len(rows) == 0 {
+		return history, tx.Commit()
+	}
+
+	account, err := q.GetAccountByID(ctx, arg.AccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	newer, err := q.SumEntriesAfter(ctx, SumEntriesAfterParams{
+		AccountID: int32(arg.AccountID),
+		AfterID:   rows[0].ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := account.Balance.Sub(utils.Amount(newer))
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows are contiguous and newest first, so each balance is the previous
+	// one minus the newer row's amount.
+	for _, row := range rows {
+		history = append(history, AccountTransaction{ListAccountTransactionsRow: row, BalanceAfter: balance})
+
+		if balance, err = balance.Sub(row.Amount); err != nil {
+			return nil, err
+		}
+	}
+
+	return history, tx.Commit()
+}
@@ -0,0 +1,233 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strconv"
+
+	"github/kasho/backend/utils"
+)
+
+var (
+	ErrUnbalancedJournal = errors.New("journal postings do not balance")
+	ErrInvalidPosting    = errors.New("journal needs at least two non-zero postings")
+)
+
+const (
+	AccountKindUser   = "user"
+	AccountKindEquity = "equity"
+)
+
+const (
+	JournalKindTransfer       = "transfer"
+	JournalKindOpeningBalance = "opening_balance"
+)
+
+// Posting is one leg of a journal. Negative amounts debit the account and
+// positive amounts credit it.
+type Posting struct {
+	AccountID int64
+	Amount    utils.Amount
+}
+
+type JournalParams struct {
+	Kind        string
+	Description string
+	Postings    []Posting
+}
+
+type JournalResult struct {
+	Journal Journal `json:"journal"`
+	// Entries are returned in the same order as the postings.
+	Entries []Entry `json:"entries"`
+	// Accounts holds every touched account after the journal was applied.
+	Accounts map[int64]Account `json:"accounts"`
+}
+
+// PostJournal records a balanced journal and applies it to the account
+// balances in a single transaction.
+func (store *Store) PostJournal(ctx context.Context, arg JournalParams) (JournalResult, error) {
+	var result JournalResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		result, err = postJournal(ctx, q, arg)
+		if err != nil {
+			return err
+		}
+
+		return appendAudit(ctx, q, AuditRecord{
+			Action:     "journal.post",
+			TargetType: "journal",
+			TargetID:   strconv.FormatInt(result.Journal.ID, 10),
+			After:      struct {
+				Journal Journal `json:"journal"`
+				Entries []Entry `json:"entries"`
+			}{result.Journal, result.Entries},
+		})
+	})
+
+	return result, err
+}
+
+// postJournal is the only code path that changes account balances. It locks
+// every touched account in ascending id order, so concurrent journals over
+// the same accounts cannot deadlock, and checks that the postings sum to zero
+// per currency, that every account's status allows its posting and that no
+// customer account is overdrawn.
+func postJournal(ctx context.Context, q *Queries, arg JournalParams) (JournalResult, error) {
+	var result JournalResult
+
+	if len(arg.Postings) < 2 {
+		return result, ErrInvalidPosting
+	}
+
+	net := map[int64]utils.Amount{}
+	for _, p := range arg.Postings {
+		if p.Amount == 0 {
+			return result, ErrInvalidPosting
+		}
+
+		sum, err := net[p.AccountID].Add(p.Amount)
+		if err != nil {
+			return result, err
+		}
+		net[p.AccountID] = sum
+	}
+
+	ids := make([]int64, 0, len(net))
+	for id := range net {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	accounts := map[int64]Account{}
+	for _, id := range ids {
+		account, err := q.GetAccountForUpdate(ctx, id)
+		if err != nil {
+			return result, err
+		}
+		accounts[id] = account
+	}
+
+	totals := map[string]utils.Amount{}
+	for _, p := range arg.Postings {
+		currency := accounts[p.AccountID].Currency
+
+		sum, err := totals[currency].Add(p.Amount)
+		if err != nil {
+			return result, err
+		}
+		totals[currency] = sum
+	}
+
+	for _, total := range totals {
+		if total != 0 {
+			return result, ErrUnbalancedJournal
+		}
+	}
+
+	for _, p := range arg.Postings {
+		if err := checkPosting(accounts[p.AccountID], p.Amount < 0); err != nil {
+			return result, err
+		}
+	}
+
+	for _, id := range ids {
+		account := accounts[id]
+
+		balance, err := account.Balance.Add(net[id])
+		if err != nil {
+			return result, err
+		}
+
+		// System accounts are allowed to run negative; they hold the other
+		// side of money entering or leaving the ledger.
+		if account.Kind == AccountKindUser && balance < 0 {
+			return result, ErrInsufficientFunds
+		}
+	}
+
+	journal, err := q.CreateJournal(ctx, CreateJournalParams{
+		Kind:        arg.Kind,
+		Description: arg.Description,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.Journal = journal
+
+	for _, p := range arg.Postings {
+		entryType := EntryTypeCredit
+		if p.Amount < 0 {
+			entryType = EntryTypeDebit
+		}
+
+		entry, err := q.CreateEntry(ctx, CreateEntryParams{
+			JournalID: journal.ID,
+			AccountID: int32(p.AccountID),
+			Amount:    p.Amount,
+			Type:      entryType,
+		})
+		if err != nil {
+			return result, err
+		}
+
+		result.Entries = append(result.Entries, entry)
+	}
+
+	result.Accounts = map[int64]Account{}
+	for _, id := range ids {
+		account, err := q.AddAccountBalance(ctx, AddAccountBalanceParams{
+			ID:     id,
+			Amount: net[id],
+		})
+		if err != nil {
+			return result, err
+		}
+
+		result.Accounts[id] = account
+	}
+
+	return result, nil
+}
+
+type LedgerReport struct {
+	UnreconciledAccounts []ListUnreconciledAccountsRow `json:"unreconciled_accounts"`
+	UnbalancedJournals   []ListUnbalancedJournalsRow   `json:"unbalanced_journals"`
+}
+
+// OK reports whether every account balance equals the sum of its entries and
+// every journal balances per currency.
+func (r LedgerReport) OK() bool {
+	return len(r.UnreconciledAccounts) == 0 && len(r.UnbalancedJournals) == 0
+}
+
+// VerifyLedger checks the ledger invariants against a single consistent
+// snapshot of the database.
+func (store *Store) VerifyLedger(ctx context.Context) (LedgerReport, error) {
+	var report LedgerReport
+
+	tx, err := store.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return report, err
+	}
+	defer tx.Rollback()
+
+	q := New(store.wrap(tx))
+
+	report.UnreconciledAccounts, err = q.ListUnreconciledAccounts(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	report.UnbalancedJournals, err = q.ListUnbalancedJournals(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	return report, tx.Commit()
+}
@@ -5,37 +5,244 @@
 package db
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
+
+	"github/kasho/backend/utils"
 )
 
 type Account struct {
-	ID        int64     `json:"id"`
-	UserID    int32     `json:"user_id"`
-	Balance   float64   `json:"balance"`
-	Currency  string    `json:"currency"`
-	CreatedAt time.Time `json:"created_at"`
+	ID           int64         `json:"id"`
+	UserID       sql.NullInt32 `json:"user_id"`
+	Balance      utils.Amount  `json:"balance"`
+	Currency     string        `json:"currency"`
+	CreatedAt    time.Time     `json:"created_at"`
+	Kind         string        `json:"kind"`
+	Status       string        `json:"status"`
+	StatusReason string        `json:"status_reason"`
+	ClosedAt     sql.NullTime  `json:"closed_at"`
+}
+
+type AccountStatusChange struct {
+	ID         int64         `json:"id"`
+	AccountID  int64         `json:"account_id"`
+	FromStatus string        `json:"from_status"`
+	ToStatus   string        `json:"to_status"`
+	Reason     string        `json:"reason"`
+	ActorID    sql.NullInt64 `json:"actor_id"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+type AuditEvent struct {
+	ID          int64           `json:"id"`
+	ActorID     sql.NullInt64   `json:"actor_id"`
+	Action      string          `json:"action"`
+	TargetType  string          `json:"target_type"`
+	TargetID    string          `json:"target_id"`
+	BeforeState json.RawMessage `json:"before_state"`
+	AfterState  json.RawMessage `json:"after_state"`
+	Ip          string          `json:"ip"`
+	UserAgent   string          `json:"user_agent"`
+	RequestID   string          `json:"request_id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	PrevHash    string          `json:"prev_hash"`
+	Hash        string          `json:"hash"`
+}
+
+type Currency struct {
+	Code         string       `json:"code"`
+	NumericCode  string       `json:"numeric_code"`
+	Name         string       `json:"name"`
+	Exponent     int32        `json:"exponent"`
+	Symbol       string       `json:"symbol"`
+	Enabled      bool         `json:"enabled"`
+	MinAmount    utils.Amount `json:"min_amount"`
+	MaxAmount    utils.Amount `json:"max_amount"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	StepUpAmount utils.Amount `json:"step_up_amount"`
+}
+
+type EmailVerificationToken struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"user_id"`
+	TokenHash string       `json:"token_hash"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
 }
 
 type Entry struct {
-	ID        int64     `json:"id"`
-	AccountID int32     `json:"account_id"`
-	Amount    float64   `json:"amount"`
-	Type      string    `json:"type"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        int64        `json:"id"`
+	AccountID int32        `json:"account_id"`
+	Amount    utils.Amount `json:"amount"`
+	Type      string       `json:"type"`
+	CreatedAt time.Time    `json:"created_at"`
+	JournalID int64        `json:"journal_id"`
 }
 
-type Transfer struct {
+type FundingRequest struct {
+	ID                int64          `json:"id"`
+	AccountID         int64          `json:"account_id"`
+	Direction         string         `json:"direction"`
+	Amount            utils.Amount   `json:"amount"`
+	Currency          string         `json:"currency"`
+	Status            string         `json:"status"`
+	Provider          string         `json:"provider"`
+	ProviderReference sql.NullString `json:"provider_reference"`
+	FailureReason     string         `json:"failure_reason"`
+	JournalID         sql.NullInt64  `json:"journal_id"`
+	ReversalJournalID sql.NullInt64  `json:"reversal_journal_id"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	SettledAt         sql.NullTime   `json:"settled_at"`
+}
+
+type FxQuote struct {
+	ID            int64         `json:"id"`
+	UserID        int64         `json:"user_id"`
+	FromAccountID int64         `json:"from_account_id"`
+	ToAccountID   int64         `json:"to_account_id"`
+	RateID        int64         `json:"rate_id"`
+	SellAmount    utils.Amount  `json:"sell_amount"`
+	SellCurrency  string        `json:"sell_currency"`
+	BuyAmount     utils.Amount  `json:"buy_amount"`
+	BuyCurrency   string        `json:"buy_currency"`
+	FeeAmount     utils.Amount  `json:"fee_amount"`
+	SpreadAmount  utils.Amount  `json:"spread_amount"`
+	Rate          string        `json:"rate"`
+	ExpiresAt     time.Time     `json:"expires_at"`
+	JournalID     sql.NullInt64 `json:"journal_id"`
+	ExecutedAt    sql.NullTime  `json:"executed_at"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+type FxRate struct {
 	ID            int64     `json:"id"`
-	FromAccountID int32     `json:"from_account_id"`
-	ToAccountID   int32     `json:"to_account_id"`
-	Amount        float64   `json:"amount"`
+	BaseCurrency  string    `json:"base_currency"`
+	QuoteCurrency string    `json:"quote_currency"`
+	Rate          string    `json:"rate"`
+	Source        string    `json:"source"`
 	CreatedAt     time.Time `json:"created_at"`
 }
 
+type IdempotencyKey struct {
+	ID             int64         `json:"id"`
+	UserID         int64         `json:"user_id"`
+	Key            string        `json:"key"`
+	RequestHash    string        `json:"request_hash"`
+	ResponseStatus sql.NullInt32 `json:"response_status"`
+	ResponseBody   []byte        `json:"response_body"`
+	CreatedAt      time.Time     `json:"created_at"`
+	CompletedAt    sql.NullTime  `json:"completed_at"`
+}
+
+type Journal struct {
+	ID          int64     `json:"id"`
+	Kind        string    `json:"kind"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type LoginAttempt struct {
+	Key          string       `json:"key"`
+	Failures     int32        `json:"failures"`
+	LastFailedAt time.Time    `json:"last_failed_at"`
+	LockedUntil  sql.NullTime `json:"locked_until"`
+}
+
+type LoginChallenge struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"user_id"`
+	TokenHash string       `json:"token_hash"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type PasswordResetToken struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"user_id"`
+	TokenHash string       `json:"token_hash"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type RecoveryCode struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"user_id"`
+	CodeHash  string       `json:"code_hash"`
+	CreatedAt time.Time    `json:"created_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type RefreshToken struct {
+	ID        int64        `json:"id"`
+	SessionID int64        `json:"session_id"`
+	TokenHash string       `json:"token_hash"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type Role struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type RolePermission struct {
+	RoleID     int64  `json:"role_id"`
+	Permission string `json:"permission"`
+}
+
+type Session struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"user_id"`
+	CreatedAt time.Time    `json:"created_at"`
+	RevokedAt sql.NullTime `json:"revoked_at"`
+	StepUpAt  sql.NullTime `json:"step_up_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+}
+
+type TotpCredential struct {
+	UserID         int64        `json:"user_id"`
+	Secret         string       `json:"secret"`
+	ConfirmedAt    sql.NullTime `json:"confirmed_at"`
+	LastUsedStep   int64        `json:"last_used_step"`
+	FailedAttempts int32        `json:"failed_attempts"`
+	LastFailedAt   sql.NullTime `json:"last_failed_at"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+type Transfer struct {
+	ID            int64        `json:"id"`
+	FromAccountID int32        `json:"from_account_id"`
+	ToAccountID   int32        `json:"to_account_id"`
+	Amount        utils.Amount `json:"amount"`
+	CreatedAt     time.Time    `json:"created_at"`
+	JournalID     int64        `json:"journal_id"`
+}
+
 type User struct {
-	ID             int64     `json:"id"`
-	Email          string    `json:"email"`
-	HashedPassword string    `json:"hashed_password"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID              int64        `json:"id"`
+	Email           string       `json:"email"`
+	HashedPassword  string       `json:"hashed_password"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+	EmailVerifiedAt sql.NullTime `json:"email_verified_at"`
+}
+
+type UserRole struct {
+	UserID    int64         `json:"user_id"`
+	RoleID    int64         `json:"role_id"`
+	GrantedBy sql.NullInt64 `json:"granted_by"`
+	CreatedAt time.Time     `json:"created_at"`
 }
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var ErrResetTokenInvalid = errors.New("password reset token is invalid or expired")
+
+type RequestPasswordResetParams struct {
+	UserID    int64
+	TokenHash string
+	ExpiresAt time.Time
+}
+
+// RequestPasswordResetTx stores the hash of a new reset token. Earlier
+// tokens of the user stay valid until they expire or one of them is used.
+func (store *Store) RequestPasswordResetTx(ctx context.Context, arg RequestPasswordResetParams) (PasswordResetToken, error) {
+	var token PasswordResetToken
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		token, err = q.CreatePasswordResetToken(ctx, CreatePasswordResetTokenParams{
+			UserID:    arg.UserID,
+			TokenHash: arg.TokenHash,
+			ExpiresAt: arg.ExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		return appendAudit(ctx, q, AuditRecord{
+			Action:     "user.password_reset_request",
+			TargetType: "user",
+			TargetID:   strconv.FormatInt(arg.UserID, 10),
+			After: struct {
+				ExpiresAt time.Time `json:"expires_at"`
+			}{token.ExpiresAt},
+		})
+	})
+
+	return token, err
+}
+
+type ChangePasswordParams struct {
+	UserID         int64
+	HashedPassword string
+}
+
+// ChangePasswordTx sets a new password for a user who proved they know the
+// current one. See setPassword for what else it invalidates.
+func (store *Store) ChangePasswordTx(ctx context.Context, arg ChangePasswordParams) (User, error) {
+	var user User
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		user, err = setPassword(ctx, q, arg.UserID, arg.HashedPassword, "user.password_change")
+		return err
+	})
+
+	return user, err
+}
+
+type ResetPasswordParams struct {
+	TokenHash      string
+	HashedPassword string
+}
+
+// ResetPasswordTx sets a new password with a reset token. The token row is
+// locked, so two requests racing with the same token can't both use it.
+func (store *Store) ResetPasswordTx(ctx context.Context, arg ResetPasswordParams) (User, error) {
+	var user User
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		token, err := q.GetPasswordResetTokenForUpdate(ctx, arg.TokenHash)
+		if err == sql.ErrNoRows {
+			return ErrResetTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		if token.UsedAt.Valid || !time.Now().Before(token.ExpiresAt) {
+			return ErrResetTokenInvalid
+		}
+
+		user, err = setPassword(ctx, q, token.UserID, arg.HashedPassword, "user.password_reset")
+		return err
+	})
+
+	return user, err
+}
+
+// setPassword replaces the password hash and invalidates everything issued
+// under the old password: outstanding reset tokens and every session, so a
+// device holding a stolen token is signed out.
+func setPassword(ctx context.Context, q *Queries, userID int64, hashedPassword string, action string) (User, error) {
+	user, err := q.UpdateUserPassword(ctx, UpdateUserPasswordParams{
+		HashedPassword: hashedPassword,
+		UpdatedAt:      time.Now(),
+		ID:             userID,
+	})
+	if err != nil {
+		return user, err
+	}
+
+	if err := q.UsePasswordResetTokens(ctx, userID); err != nil {
+		return user, err
+	}
+
+	if err := q.RevokeUserSessions(ctx, userID); err != nil {
+		return user, err
+	}
+
+	return user, appendAudit(ctx, q, AuditRecord{
+		Action:     action,
+		TargetType: "user",
+		TargetID:   strconv.FormatInt(userID, 10),
+		After:      user.AuditState(),
+	})
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+)
+
+var (
+	ErrUnknownRole = errors.New("role does not exist")
+	ErrRoleNotHeld = errors.New("user does not hold the role")
+	ErrLastAdmin   = errors.New("the last admin cannot lose the admin role")
+)
+
+const (
+	RoleAdmin   = "admin"
+	RoleSupport = "support"
+)
+
+// Permissions the API checks. They are seeded by the migrations; a role is
+// given one by adding it to role_permissions.
+const (
+	PermissionUsersRead        = "users.read"
+	PermissionAccountsRead     = "accounts.read"
+	PermissionAccountsStatus   = "accounts.status"
+	PermissionTransfersRead    = "transfers.read"
+	PermissionCurrenciesManage = "currencies.manage"
+	PermissionAuditRead        = "audit.read"
+	PermissionRolesManage      = "roles.manage"
+)
+
+// Access is what a user may do, as carried in their access tokens.
+type Access struct {
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// UserAccess returns the roles of a user and the permissions they grant.
+func (store *Store) UserAccess(ctx context.Context, userID int64) (Access, error) {
+	var access Access
+
+	roles, err := store.ListUserRoles(ctx, userID)
+	if err != nil {
+		return access, err
+	}
+
+	access.Roles = make([]string, 0, len(roles))
+	for _, role := range roles {
+		access.Roles = append(access.Roles, role.Name)
+	}
+
+	access.Permissions, err = store.ListUserPermissions(ctx, userID)
+	return access, err
+}
+
+type RoleWithPermissions struct {
+	Role
+	Permissions []string `json:"permissions"`
+}
+
+// RolesWithPermissions lists every role with the permissions it grants.
+func (store *Store) RolesWithPermissions(ctx context.Context) ([]RoleWithPermissions, error) {
+	roles, err := store.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	grants, err := store.ListRolePermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := map[int64][]string{}
+	for _, grant := range grants {
+		permissions[grant.RoleID] = append(permissions[grant.RoleID], grant.Permission)
+	}
+
+	result := make([]RoleWithPermissions, 0, len(roles))
+	for _, role := range roles {
+		granted := permissions[role.ID]
+		if granted == nil {
+			granted = []string{}
+		}
+		result = append(result, RoleWithPermissions{Role: role, Permissions: granted})
+	}
+
+	return result, nil
+}
+
+type UserRoleParams struct {
+	UserID int64
+	Role   string
+	// ActorID is the user making the change, unset for the system.
+	ActorID sql.NullInt64
+}
+
+// GrantRoleTx gives a user a role. Granting a role the user already holds
+// changes nothing. The new permissions reach the user's access tokens when
+// they are next refreshed.
+func (store *Store) GrantRoleTx(ctx context.Context, arg UserRoleParams) (Role, error) {
+	var role Role
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		role, err = q.GetRoleByName(ctx, arg.Role)
+		if err == sql.ErrNoRows {
+			return ErrUnknownRole
+		}
+		if err != nil {
+			return err
+		}
+
+		granted, err := q.GrantUserRole(ctx, GrantUserRoleParams{
+			UserID:    arg.UserID,
+			RoleID:    role.ID,
+			GrantedBy: arg.ActorID,
+		})
+		if err != nil || granted == 0 {
+			return err
+		}
+
+		return appendAudit(ctx, q, roleAudit("role.grant", arg.UserID, role))
+	})
+
+	return role, err
+}
+
+// RevokeRoleTx takes a role away from a user and revokes their sessions,
+// so that access tokens still carrying the role stop working at once. The
+// last admin keeps the admin role, so the admin API can't lock itself out.
+func (store *Store) RevokeRoleTx(ctx context.Context, arg UserRoleParams) error {
+	return store.execTx(ctx, func(q *Queries) error {
+		role, err := q.GetRoleByName(ctx, arg.Role)
+		if err == sql.ErrNoRows {
+			return ErrUnknownRole
+		}
+		if err != nil {
+			return err
+		}
+
+		// Locking the holders first serialises concurrent revocations, so
+		// two of them can't each see another admin left and both succeed.
+		holders, err := q.CountUsersWithRoleForUpdate(ctx, role.ID)
+		if err != nil {
+			return err
+		}
+
+		revoked, err := q.RevokeUserRole(ctx, RevokeUserRoleParams{
+			UserID: arg.UserID,
+			RoleID: role.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if revoked == 0 {
+			return ErrRoleNotHeld
+		}
+		if role.Name == RoleAdmin && holders <= 1 {
+			return ErrLastAdmin
+		}
+
+		if err := q.RevokeUserSessions(ctx, arg.UserID); err != nil {
+			return err
+		}
+
+		return appendAudit(ctx, q, roleAudit("role.revoke", arg.UserID, role))
+	})
+}
+
+func roleAudit(action string, userID int64, role Role) AuditRecord {
+	return AuditRecord{
+		Action:     action,
+		TargetType: "user",
+		TargetID:   strconv.FormatInt(userID, 10),
+		After: struct {
+			Role string `json:"role"`
+		}{role.Name},
+	}
+}
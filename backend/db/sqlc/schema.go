@@ -0,0 +1,28 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Ping checks that the database can be reached.
+func (store *Store) Ping(ctx context.Context) error {
+	return store.db.PingContext(ctx)
+}
+
+// Close closes the connection pool. Queries in progress finish first.
+func (store *Store) Close() error {
+	return store.db.Close()
+}
+
+// SchemaVersion reads the version the migrations left in schema_migrations.
+// Dirty means the last migration failed partway and needs fixing by hand.
+// A database that was never migrated is at version 0.
+func (store *Store) SchemaVersion(ctx context.Context) (version uint, dirty bool, err error) {
+	err = store.db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+
+	return version, dirty, err
+}
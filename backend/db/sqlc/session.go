@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+)
+
+type SessionTxResult struct {
+	Session      Session      `json:"session"`
+	RefreshToken RefreshToken `json:"refresh_token"`
+}
+
+type CreateSessionTxParams struct {
+	UserID           int64
+	RefreshTokenHash string
+	ExpiresAt        time.Time
+}
+
+// CreateSessionTx starts a new login session with its first refresh token.
+func (store *Store) CreateSessionTx(ctx context.Context, arg CreateSessionTxParams) (SessionTxResult, error) {
+	var result SessionTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		result, err = startSession(ctx, q, arg)
+		if err != nil {
+			return err
+		}
+
+		return appendAudit(ctx, q, sessionAudit("session.create", result.Session, ""))
+	})
+
+	return result, err
+}
+
+func startSession(ctx context.Context, q *Queries, arg CreateSessionTxParams) (SessionTxResult, error) {
+	var result SessionTxResult
+	var err error
+
+	result.Session, err = q.CreateSession(ctx, CreateSessionParams{
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.RefreshToken, err = q.CreateRefreshToken(ctx, CreateRefreshTokenParams{
+		SessionID: result.Session.ID,
+		TokenHash: arg.RefreshTokenHash,
+		ExpiresAt: arg.ExpiresAt,
+	})
+	return result, err
+}
+
+type RotateRefreshTokenTxParams struct {
+	TokenHash    string
+	NewTokenHash string
+	ExpiresAt    time.Time
+}
+
+// RotateRefreshTokenTx consumes a refresh token and issues the next one in
+// the same session, extending the session to the new token's expiry. Refresh tokens are single use: presenting one that was
+// already rotated means it has leaked, so the whole session is revoked and
+// ErrRefreshTokenReused is returned.
+func (store *Store) RotateRefreshTokenTx(ctx context.Context, arg RotateRefreshTokenTxParams) (SessionTxResult, error) {
+	var result SessionTxResult
+	reused := false
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		token, err := q.GetRefreshTokenForUpdate(ctx, arg.TokenHash)
+		if err == sql.ErrNoRows {
+			return ErrRefreshTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		result.Session, err = q.GetSessionByID(ctx, token.SessionID)
+		if err != nil {
+			return err
+		}
+
+		if result.Session.RevokedAt.Valid {
+			return ErrRefreshTokenInvalid
+		}
+
+		if token.UsedAt.Valid {
+			// Commit the revocation; the error is reported after the tx.
+			reused = true
+			if err := q.RevokeSession(ctx, result.Session.ID); err != nil {
+				return err
+			}
+			return appendAudit(ctx, q, sessionAudit("session.revoke", result.Session, "refresh token reused"))
+		}
+
+		if time.Now().After(token.ExpiresAt) {
+			return ErrRefreshTokenInvalid
+		}
+
+		if err := q.MarkRefreshTokenUsed(ctx, token.ID); err != nil {
+			return err
+		}
+
+		if err := q.ExtendSession(ctx, ExtendSessionParams{ID: result.Session.ID, ExpiresAt: arg.ExpiresAt}); err != nil {
+			return err
+		}
+		result.Session.ExpiresAt = arg.ExpiresAt
+
+		result.RefreshToken, err = q.CreateRefreshToken(ctx, CreateRefreshTokenParams{
+			SessionID: result.Session.ID,
+			TokenHash: arg.NewTokenHash,
+			ExpiresAt: arg.ExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		return appendAudit(ctx, q, sessionAudit("session.refresh", result.Session, ""))
+	})
+
+	if err == nil && reused {
+		return SessionTxResult{}, ErrRefreshTokenReused
+	}
+
+	return result, err
+}
+
+// sessionAudit records a session change. Refresh token hashes stay out of
+// the audit log.
+func sessionAudit(action string, session Session, reason string) AuditRecord {
+	return AuditRecord{
+		Action:     action,
+		TargetType: "session",
+		TargetID:   strconv.FormatInt(session.ID, 10),
+		After: struct {
+			Session
+			Reason string `json:"reason,omitempty"`
+		}{session, reason},
+	}
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github/kasho/backend/utils"
+)
+
+var (
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrCurrencyMismatch  = errors.New("account currency mismatch")
+)
+
+const (
+	EntryTypeDebit  = "debit"
+	EntryTypeCredit = "credit"
+)
+
+// Store provides all functions to execute db queries individually
+// as well as inside a single SQL transaction.
+type Store struct {
+	*Queries
+	db   *sql.DB
+	wrap Wrap
+}
+
+// Wrap decorates the connection the store queries through, to time or trace
+// queries. It is applied to the pool and to every transaction.
+type Wrap func(DBTX) DBTX
+
+// QueryName returns the sqlc name of a query, taken from the "-- name:"
+// comment sqlc puts at the start of each one. Hand-written queries are
+// "other".
+func QueryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "other"
+	}
+
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
+
+func NewStore(db *sql.DB, wraps ...Wrap) *Store {
+	wrap := func(conn DBTX) DBTX {
+		for _, w := range wraps {
+			conn = w(conn)
+		}
+		return conn
+	}
+
+	return &Store{
+		Queries: New(wrap(db)),
+		db:      db,
+		wrap:    wrap,
+	}
+}
+
+// execTx runs fn within a database transaction, rolling back if fn fails.
+func (store *Store) execTx(ctx context.Context, fn func(*Queries) error) error {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(New(store.wrap(tx))); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("tx err: %v, rb err: %v", err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type TransferTxParams struct {
+	FromAccountID int64       `json:"from_account_id"`
+	ToAccountID   int64       `json:"to_account_id"`
+	Amount        utils.Money `json:"amount"`
+}
+
+type TransferTxResult struct {
+	Transfer    Transfer `json:"transfer"`
+	FromAccount Account  `json:"from_account"`
+	ToAccount   Account  `json:"to_account"`
+	FromEntry   Entry    `json:"from_entry"`
+	ToEntry     Entry    `json:"to_entry"`
+}
+
+// TransferTx moves money from one account to another. It posts a two-legged
+// journal and records the transfer against it in a single transaction.
+func (store *Store) TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error) {
+	var result TransferTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		fromAccount, err := q.GetAccountByID(ctx, arg.FromAccountID)
+		if err != nil {
+			return err
+		}
+
+		toAccount, err := q.GetAccountByID(ctx, arg.ToAccountID)
+		if err != nil {
+			return err
+		}
+
+		if fromAccount.Currency != arg.Amount.Currency || toAccount.Currency != arg.Amount.Currency {
+			return ErrCurrencyMismatch
+		}
+
+		debit, err := arg.Amount.Amount.Neg()
+		if err != nil {
+			return err
+		}
+
+		journal, err := postJournal(ctx, q, JournalParams{
+			Kind:        JournalKindTransfer,
+			Description: fmt.Sprintf("transfer from account %d to account %d", arg.FromAccountID, arg.ToAccountID),
+			Postings: []Posting{
+				{AccountID: arg.FromAccountID, Amount: debit},
+				{AccountID: arg.ToAccountID, Amount: arg.Amount.Amount},
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Transfer, err = q.CreateTransfer(ctx, CreateTransferParams{
+			JournalID:     journal.Journal.ID,
+			FromAccountID: int32(arg.FromAccountID),
+			ToAccountID:   int32(arg.ToAccountID),
+			Amount:        arg.Amount.Amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.FromEntry = journal.Entries[0]
+		result.ToEntry = journal.Entries[1]
+		result.FromAccount = journal.Accounts[arg.FromAccountID]
+		result.ToAccount = journal.Accounts[arg.ToAccountID]
+
+		return appendAudit(ctx, q, AuditRecord{
+			Action:     "transfer.create",
+			TargetType: "transfer",
+			TargetID:   strconv.FormatInt(result.Transfer.ID, 10),
+			After:      result.Transfer,
+		})
+	})
+
+	return result, err
+}
@@ -7,24 +7,31 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github/kasho/backend/utils"
 )
 
 const createTransfer = `-- name: CreateTransfer :one
 INSERT INTO transfers (
+    journal_id,
     from_account_id,
     to_account_id,
     amount
-) VALUES ($1, $2, $3) RETURNING id, from_account_id, to_account_id, amount, created_at
+) VALUES ($1, $2, $3, $4) RETURNING id, from_account_id, to_account_id, amount, created_at, journal_id
 `
 
 type CreateTransferParams struct {
-	FromAccountID int32   `json:"from_account_id"`
-	ToAccountID   int32   `json:"to_account_id"`
-	Amount        float64 `json:"amount"`
+	JournalID     int64        `json:"journal_id"`
+	FromAccountID int32        `json:"from_account_id"`
+	ToAccountID   int32        `json:"to_account_id"`
+	Amount        utils.Amount `json:"amount"`
 }
 
 func (q *Queries) CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error) {
-	row := q.db.QueryRowContext(ctx, createTransfer, arg.FromAccountID, arg.ToAccountID, arg.Amount)
+	row := q.db.QueryRowContext(ctx, createTransfer, arg.JournalID, arg.FromAccountID, arg.ToAccountID, arg.Amount)
 	var i Transfer
 	err := row.Scan(
 		&i.ID,
@@ -32,6 +39,7 @@ func (q *Queries) CreateTransfer(ctx context.Context, arg CreateTransferParams)
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.JournalID,
 	)
 	return i, err
 }
@@ -46,7 +54,7 @@ func (q *Queries) DeleteAllTransfers(ctx context.Context) error {
 }
 
 const getTransferByID = `-- name: GetTransferByID :one
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers WHERE id = $1
+SELECT id, from_account_id, to_account_id, amount, created_at, journal_id FROM transfers WHERE id = $1
 `
 
 func (q *Queries) GetTransferByID(ctx context.Context, id int64) (Transfer, error) {
@@ -58,12 +66,13 @@ func (q *Queries) GetTransferByID(ctx context.Context, id int64) (Transfer, erro
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.JournalID,
 	)
 	return i, err
 }
 
 const getTransfersByFromAccountID = `-- name: GetTransfersByFromAccountID :many
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers WHERE from_account_id = $1
+SELECT id, from_account_id, to_account_id, amount, created_at, journal_id FROM transfers WHERE from_account_id = $1
 `
 
 func (q *Queries) GetTransfersByFromAccountID(ctx context.Context, fromAccountID int32) ([]Transfer, error) {
@@ -81,6 +90,7 @@ func (q *Queries) GetTransfersByFromAccountID(ctx context.Context, fromAccountID
 			&i.ToAccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.JournalID,
 		); err != nil {
 			return nil, err
 		}
@@ -96,7 +106,7 @@ func (q *Queries) GetTransfersByFromAccountID(ctx context.Context, fromAccountID
 }
 
 const getTransfersByToAccountID = `-- name: GetTransfersByToAccountID :many
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers WHERE to_account_id = $1
+SELECT id, from_account_id, to_account_id, amount, created_at, journal_id FROM transfers WHERE to_account_id = $1
 `
 
 func (q *Queries) GetTransfersByToAccountID(ctx context.Context, toAccountID int32) ([]Transfer, error) {
@@ -114,6 +124,7 @@ func (q *Queries) GetTransfersByToAccountID(ctx context.Context, toAccountID int
 			&i.ToAccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.JournalID,
 		); err != nil {
 			return nil, err
 		}
@@ -129,17 +140,92 @@ func (q *Queries) GetTransfersByToAccountID(ctx context.Context, toAccountID int
 }
 
 const listTransfers = `-- name: ListTransfers :many
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers ORDER BY id 
-LIMIT $1 OFFSET $2
+SELECT t.id, t.from_account_id, t.to_account_id, t.amount, t.created_at, t.journal_id, a.currency
+FROM transfers t
+JOIN accounts a ON a.id = t.from_account_id
+WHERE ($1::BIGINT IS NULL OR t.id < $1)
+AND ($2::INT IS NULL OR t.from_account_id = $2 OR t.to_account_id = $2)
+AND ($3::VARCHAR IS NULL OR a.currency = $3)
+AND ($4::TIMESTAMPTZ IS NULL OR t.created_at >= $4)
+AND ($5::TIMESTAMPTZ IS NULL OR t.created_at < $5)
+ORDER BY t.id DESC
+LIMIT $6
 `
 
 type ListTransfersParams struct {
-	Limit  int32 `json:"limit"`
-	Offset int32 `json:"offset"`
+	BeforeID    sql.NullInt64  `json:"before_id"`
+	AccountID   sql.NullInt32  `json:"account_id"`
+	Currency    sql.NullString `json:"currency"`
+	CreatedFrom sql.NullTime   `json:"created_from"`
+	CreatedTo   sql.NullTime   `json:"created_to"`
+	PageLimit   int32          `json:"page_limit"`
+}
+
+type ListTransfersRow struct {
+	ID            int64        `json:"id"`
+	FromAccountID int32        `json:"from_account_id"`
+	ToAccountID   int32        `json:"to_account_id"`
+	Amount        utils.Amount `json:"amount"`
+	CreatedAt     time.Time    `json:"created_at"`
+	JournalID     int64        `json:"journal_id"`
+	Currency      string       `json:"currency"`
+}
+
+func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([]ListTransfersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTransfers, arg.BeforeID, arg.AccountID, arg.Currency, arg.CreatedFrom, arg.CreatedTo, arg.PageLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListTransfersRow{}
+	for rows.Next() {
+		var i ListTransfersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.JournalID,
+			&i.Currency,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTransfersByAccountIDs = `-- name: ListTransfersByAccountIDs :many
+SELECT id, from_account_id, to_account_id, amount, created_at, journal_id FROM transfers
+WHERE (from_account_id = ANY($1::INT[]) OR to_account_id = ANY($1::INT[]))
+AND ($2::BIGINT IS NULL OR id < $2)
+AND ($3::TIMESTAMPTZ IS NULL OR created_at >= $3)
+AND ($4::TIMESTAMPTZ IS NULL OR created_at < $4)
+AND ($5::BIGINT IS NULL OR amount >= $5)
+AND ($6::BIGINT IS NULL OR amount <= $6)
+ORDER BY id DESC
+LIMIT $7
+`
+
+type ListTransfersByAccountIDsParams struct {
+	AccountIds  []int32       `json:"account_ids"`
+	BeforeID    sql.NullInt64 `json:"before_id"`
+	CreatedFrom sql.NullTime  `json:"created_from"`
+	CreatedTo   sql.NullTime  `json:"created_to"`
+	MinAmount   sql.NullInt64 `json:"min_amount"`
+	MaxAmount   sql.NullInt64 `json:"max_amount"`
+	PageLimit   int32         `json:"page_limit"`
 }
 
-func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([]Transfer, error) {
-	rows, err := q.db.QueryContext(ctx, listTransfers, arg.Limit, arg.Offset)
+func (q *Queries) ListTransfersByAccountIDs(ctx context.Context, arg ListTransfersByAccountIDsParams) ([]Transfer, error) {
+	rows, err := q.db.QueryContext(ctx, listTransfersByAccountIDs, pq.Array(arg.AccountIds), arg.BeforeID, arg.CreatedFrom, arg.CreatedTo, arg.MinAmount, arg.MaxAmount, arg.PageLimit)
 	if err != nil {
 		return nil, err
 	}
@@ -153,6 +239,7 @@ func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([
 			&i.ToAccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.JournalID,
 		); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,315 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github/kasho/backend/audit"
+)
+
+var (
+	ErrTwoFactorEnabled      = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled   = errors.New("two-factor authentication is not enabled")
+	ErrSecondFactorInvalid   = errors.New("two-factor code is invalid")
+	ErrSecondFactorLocked    = errors.New("too many wrong two-factor codes, try again later")
+	ErrLoginChallengeInvalid = errors.New("login challenge is invalid or expired")
+)
+
+const (
+	// MaxSecondFactorFailures wrong codes in a row lock the second factor
+	// for SecondFactorLockout, which keeps the million TOTP codes out of
+	// reach of guessing.
+	MaxSecondFactorFailures = 5
+	SecondFactorLockout     = 15 * time.Minute
+)
+
+// TOTPCheck validates a code against the stored, sealed, secret and
+// returns the time step it matched. Only the caller can open the secret.
+type TOTPCheck func(sealedSecret string, lastUsedStep int64) (step int64, ok bool)
+
+// SecondFactor is what a user presents as proof: either a TOTP code, which
+// TOTP checks, or the hash of one of their recovery codes.
+type SecondFactor struct {
+	TOTP             TOTPCheck
+	RecoveryCodeHash string
+}
+
+// EnrolTOTP stores a new, unconfirmed TOTP secret for the user, replacing
+// an earlier unconfirmed one. It does nothing for logins until confirmed.
+func (store *Store) EnrolTOTP(ctx context.Context, userID int64, sealedSecret string) error {
+	_, err := store.CreatePendingTOTPCredential(ctx, CreatePendingTOTPCredentialParams{
+		UserID: userID,
+		Secret: sealedSecret,
+	})
+	// The upsert skips confirmed credentials and returns no row.
+	if err == sql.ErrNoRows {
+		return ErrTwoFactorEnabled
+	}
+	return err
+}
+
+type ConfirmTOTPParams struct {
+	UserID int64
+	TOTP   TOTPCheck
+	// Hashes of the recovery codes to issue with it.
+	RecoveryCodeHashes []string
+}
+
+// ConfirmTOTPTx turns two-factor authentication on once the user proves
+// their authenticator produces codes for the enrolled secret.
+func (store *Store) ConfirmTOTPTx(ctx context.Context, arg ConfirmTOTPParams) error {
+	return store.execTx(ctx, func(q *Queries) error {
+		credential, err := q.GetTOTPCredentialForUpdate(ctx, arg.UserID)
+		if err == sql.ErrNoRows {
+			return ErrTwoFactorNotEnabled
+		}
+		if err != nil {
+			return err
+		}
+
+		if credential.ConfirmedAt.Valid {
+			return ErrTwoFactorEnabled
+		}
+
+		step, ok := arg.TOTP(credential.Secret, 0)
+		if !ok {
+			return ErrSecondFactorInvalid
+		}
+
+		if err := q.ConfirmTOTPCredential(ctx, ConfirmTOTPCredentialParams{
+			UserID:       arg.UserID,
+			LastUsedStep: step,
+		}); err != nil {
+			return err
+		}
+
+		if err := replaceRecoveryCodes(ctx, q, arg.UserID, arg.RecoveryCodeHashes); err != nil {
+			return err
+		}
+
+		return appendAudit(ctx, q, twoFactorAudit("user.2fa_enable", arg.UserID, len(arg.RecoveryCodeHashes)))
+	})
+}
+
+type RecoveryCodesParams struct {
+	UserID             int64
+	Factor             SecondFactor
+	RecoveryCodeHashes []string
+}
+
+// RegenerateRecoveryCodesTx replaces every recovery code of the user,
+// used or not, with new ones.
+func (store *Store) RegenerateRecoveryCodesTx(ctx context.Context, arg RecoveryCodesParams) error {
+	return store.withSecondFactor(ctx, arg.UserID, arg.Factor, func(q *Queries) error {
+		if err := replaceRecoveryCodes(ctx, q, arg.UserID, arg.RecoveryCodeHashes); err != nil {
+			return err
+		}
+
+		return appendAudit(ctx, q, twoFactorAudit("user.2fa_recovery_codes", arg.UserID, len(arg.RecoveryCodeHashes)))
+	})
+}
+
+// DisableTOTPTx turns two-factor authentication off and drops the recovery
+// codes. It takes a current code, so a stolen session alone can't do it.
+func (store *Store) DisableTOTPTx(ctx context.Context, userID int64, factor SecondFactor) error {
+	return store.withSecondFactor(ctx, userID, factor, func(q *Queries) error {
+		if err := q.DeleteTOTPCredential(ctx, userID); err != nil {
+			return err
+		}
+
+		if err := q.DeleteRecoveryCodes(ctx, userID); err != nil {
+			return err
+		}
+
+		return appendAudit(ctx, q, twoFactorAudit("user.2fa_disable", userID, 0))
+	})
+}
+
+type StepUpParams struct {
+	UserID    int64
+	SessionID int64
+	Factor    SecondFactor
+}
+
+// StepUpTx records that the session just passed a two-factor check, for
+// operations that require a recent one.
+func (store *Store) StepUpTx(ctx context.Context, arg StepUpParams) error {
+	return store.withSecondFactor(ctx, arg.UserID, arg.Factor, func(q *Queries) error {
+		if err := q.SetSessionStepUp(ctx, arg.SessionID); err != nil {
+			return err
+		}
+
+		session, err := q.GetSessionByID(ctx, arg.SessionID)
+		if err != nil {
+			return err
+		}
+
+		return appendAudit(ctx, q, sessionAudit("session.step_up", session, ""))
+	})
+}
+
+type CompleteLoginParams struct {
+	ChallengeHash    string
+	Factor           SecondFactor
+	RefreshTokenHash string
+	ExpiresAt        time.Time
+}
+
+// CompleteLoginTx exchanges a login challenge and a second factor for a
+// session. The challenge works once; the session starts stepped up.
+func (store *Store) CompleteLoginTx(ctx context.Context, arg CompleteLoginParams) (SessionTxResult, error) {
+	var result SessionTxResult
+	failed := false
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		challenge, err := q.GetLoginChallengeForUpdate(ctx, arg.ChallengeHash)
+		if err == sql.ErrNoRows {
+			return ErrLoginChallengeInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		if challenge.UsedAt.Valid || !time.Now().Before(challenge.ExpiresAt) {
+			return ErrLoginChallengeInvalid
+		}
+
+		ok, err := verifySecondFactor(ctx, q, challenge.UserID, arg.Factor)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// Commit the failure; the error is reported after the tx.
+			failed = true
+			return nil
+		}
+
+		if err := q.UseLoginChallenge(ctx, challenge.ID); err != nil {
+			return err
+		}
+
+		result, err = startSession(ctx, q, CreateSessionTxParams{
+			UserID:           challenge.UserID,
+			RefreshTokenHash: arg.RefreshTokenHash,
+			ExpiresAt:        arg.ExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := q.SetSessionStepUp(ctx, result.Session.ID); err != nil {
+			return err
+		}
+
+		// The caller didn't know who was logging in, the challenge does.
+		return appendAudit(audit.WithUser(ctx, challenge.UserID), q, sessionAudit("session.create", result.Session, "two-factor login"))
+	})
+
+	if err == nil && failed {
+		return SessionTxResult{}, ErrSecondFactorInvalid
+	}
+
+	return result, err
+}
+
+// withSecondFactor runs change once factor is verified for the user. A
+// wrong code is counted towards the lockout and reported as
+// ErrSecondFactorInvalid without running change.
+func (store *Store) withSecondFactor(ctx context.Context, userID int64, factor SecondFactor, change func(q *Queries) error) error {
+	failed := false
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		ok, err := verifySecondFactor(ctx, q, userID, factor)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			failed = true
+			return nil
+		}
+
+		return change(q)
+	})
+
+	if err == nil && failed {
+		return ErrSecondFactorInvalid
+	}
+
+	return err
+}
+
+// verifySecondFactor checks factor for the user, spending the recovery
+// code or TOTP step it matched. The credential row is locked, so a code
+// can't be used twice by concurrent requests.
+func verifySecondFactor(ctx context.Context, q *Queries, userID int64, factor SecondFactor) (bool, error) {
+	credential, err := q.GetTOTPCredentialForUpdate(ctx, userID)
+	if err == sql.ErrNoRows || (err == nil && !credential.ConfirmedAt.Valid) {
+		return false, ErrTwoFactorNotEnabled
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if credential.FailedAttempts >= MaxSecondFactorFailures &&
+		credential.LastFailedAt.Valid && time.Since(credential.LastFailedAt.Time) < SecondFactorLockout {
+		return false, ErrSecondFactorLocked
+	}
+
+	step, ok := credential.LastUsedStep, false
+
+	switch {
+	case factor.RecoveryCodeHash != "":
+		used, err := q.UseRecoveryCode(ctx, UseRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: factor.RecoveryCodeHash,
+		})
+		if err != nil {
+			return false, err
+		}
+		ok = used == 1
+	case factor.TOTP != nil:
+		step, ok = factor.TOTP(credential.Secret, credential.LastUsedStep)
+	}
+
+	if !ok {
+		return false, q.RecordSecondFactorFailure(ctx, userID)
+	}
+
+	return true, q.RecordSecondFactorSuccess(ctx, RecordSecondFactorSuccessParams{
+		LastUsedStep: step,
+		UserID:       userID,
+	})
+}
+
+func replaceRecoveryCodes(ctx context.Context, q *Queries, userID int64, hashes []string) error {
+	if err := q.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		if err := q.CreateRecoveryCode(ctx, CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: hash,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// twoFactorAudit records a two-factor change. Secrets and codes stay out of
+// the audit log; only how many recovery codes were issued is kept.
+func twoFactorAudit(action string, userID int64, recoveryCodes int) AuditRecord {
+	return AuditRecord{
+		Action:     action,
+		TargetType: "user",
+		TargetID:   strconv.FormatInt(userID, 10),
+		After: struct {
+			RecoveryCodes int `json:"recovery_codes"`
+		}{recoveryCodes},
+	}
+}
@@ -7,6 +7,7 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"time"
 )
 
@@ -14,7 +15,7 @@ const createUser = `-- name: CreateUser :one
 INSERT INTO users (
     email,
     hashed_password
-) VALUES ($1, $2) RETURNING id, email, hashed_password, created_at, updated_at
+) VALUES ($1, $2) RETURNING id, email, hashed_password, created_at, updated_at, email_verified_at
 `
 
 type CreateUserParams struct {
@@ -31,6 +32,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.HashedPassword,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
@@ -54,7 +56,7 @@ func (q *Queries) DeleteUser(ctx context.Context, id int64) error {
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, email, hashed_password, created_at, updated_at FROM users WHERE email = $1 LIMIT 1
+SELECT id, email, hashed_password, created_at, updated_at, email_verified_at FROM users WHERE email = $1 LIMIT 1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -66,12 +68,13 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.HashedPassword,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, email, hashed_password, created_at, updated_at FROM users WHERE id = $1 LIMIT 1
+SELECT id, email, hashed_password, created_at, updated_at, email_verified_at FROM users WHERE id = $1 LIMIT 1
 `
 
 func (q *Queries) GetUserByID(ctx context.Context, id int64) (User, error) {
@@ -83,22 +86,29 @@ func (q *Queries) GetUserByID(ctx context.Context, id int64) (User, error) {
 		&i.HashedPassword,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
 const listUsers = `-- name: ListUsers :many
-SELECT id, email, hashed_password, created_at, updated_at FROM users ORDER BY id 
-LIMIT $1 OFFSET $2
+SELECT id, email, hashed_password, created_at, updated_at, email_verified_at FROM users
+WHERE ($1::BIGINT IS NULL OR id < $1)
+AND ($2::TIMESTAMPTZ IS NULL OR created_at >= $2)
+AND ($3::TIMESTAMPTZ IS NULL OR created_at < $3)
+ORDER BY id DESC
+LIMIT $4
 `
 
 type ListUsersParams struct {
-	Limit  int32 `json:"limit"`
-	Offset int32 `json:"offset"`
+	BeforeID    sql.NullInt64 `json:"before_id"`
+	CreatedFrom sql.NullTime  `json:"created_from"`
+	CreatedTo   sql.NullTime  `json:"created_to"`
+	PageLimit   int32         `json:"page_limit"`
 }
 
 func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error) {
-	rows, err := q.db.QueryContext(ctx, listUsers, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, listUsers, arg.BeforeID, arg.CreatedFrom, arg.CreatedTo, arg.PageLimit)
 	if err != nil {
 		return nil, err
 	}
@@ -112,6 +122,7 @@ func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, e
 			&i.HashedPassword,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.EmailVerifiedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -126,9 +137,28 @@ func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, e
 	return items, nil
 }
 
+const markUserEmailVerified = `-- name: MarkUserEmailVerified :one
+UPDATE users SET email_verified_at = now(), updated_at = now()
+WHERE id = $1 RETURNING id, email, hashed_password, created_at, updated_at, email_verified_at
+`
+
+func (q *Queries) MarkUserEmailVerified(ctx context.Context, id int64) (User, error) {
+	row := q.db.QueryRowContext(ctx, markUserEmailVerified, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.HashedPassword,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
 const updateUserPassword = `-- name: UpdateUserPassword :one
 UPDATE users SET hashed_password = $1, updated_at = $2 
-WHERE id = $3 RETURNING id, email, hashed_password, created_at, updated_at
+WHERE id = $3 RETURNING id, email, hashed_password, created_at, updated_at, email_verified_at
 `
 
 type UpdateUserPasswordParams struct {
@@ -146,6 +176,7 @@ func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPassword
 		&i.HashedPassword,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
@@ -0,0 +1,181 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func changeStatus(account db.Account, status string) (db.Account, error) {
+	return testStore.ChangeAccountStatusTx(context.Background(), db.ChangeAccountStatusParams{
+		AccountID: account.ID,
+		Status:    status,
+		Reason:    "test",
+	})
+}
+
+func TestCanTransition(t *testing.T) {
+	assert.True(t, db.CanTransition(db.AccountStatusActive, db.AccountStatusFrozen))
+	assert.True(t, db.CanTransition(db.AccountStatusFrozen, db.AccountStatusActive))
+	assert.True(t, db.CanTransition(db.AccountStatusDormant, db.AccountStatusActive))
+	assert.True(t, db.CanTransition(db.AccountStatusClosed, db.AccountStatusActive))
+
+	assert.False(t, db.CanTransition(db.AccountStatusFrozen, db.AccountStatusClosed))
+	assert.False(t, db.CanTransition(db.AccountStatusClosed, db.AccountStatusFrozen))
+	assert.False(t, db.CanTransition(db.AccountStatusActive, db.AccountStatusActive))
+}
+
+func TestFrozenAccountBlocksDebits(t *testing.T) {
+	defer clean_up_ledger()
+
+	account1 := createFundedAccount(t, "USD", 10000)
+	account2 := createFundedAccount(t, "USD", 10000)
+
+	frozen, err := changeStatus(account1, db.AccountStatusFrozen)
+	require.NoError(t, err)
+	assert.Equal(t, db.AccountStatusFrozen, frozen.Status)
+	assert.Equal(t, "test", frozen.StatusReason)
+
+	_, err = testStore.TransferTx(context.Background(), db.TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        utils.NewMoney(1000, "USD"),
+	})
+	require.ErrorIs(t, err, db.ErrAccountFrozen)
+
+	// Money can still come in.
+	result, err := testStore.TransferTx(context.Background(), db.TransferTxParams{
+		FromAccountID: account2.ID,
+		ToAccountID:   account1.ID,
+		Amount:        utils.NewMoney(1000, "USD"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, utils.Amount(11000), result.ToAccount.Balance)
+
+	// A frozen account has to be unfrozen before it can be closed.
+	_, err = changeStatus(account1, db.AccountStatusClosed)
+	require.ErrorIs(t, err, db.ErrInvalidTransition)
+
+	_, err = changeStatus(account1, db.AccountStatusActive)
+	require.NoError(t, err)
+
+	changes, err := testQuery.ListAccountStatusChanges(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, db.AccountStatusFrozen, changes[0].FromStatus)
+	assert.Equal(t, db.AccountStatusActive, changes[0].ToStatus)
+	assert.Equal(t, db.AccountStatusActive, changes[1].FromStatus)
+	assert.Equal(t, db.AccountStatusFrozen, changes[1].ToStatus)
+}
+
+func TestCloseAccount(t *testing.T) {
+	defer clean_up_ledger()
+
+	account := createFundedAccount(t, "USD", 5000)
+	other := createFundedAccount(t, "USD", 0)
+
+	_, err := changeStatus(account, db.AccountStatusClosed)
+	require.ErrorIs(t, err, db.ErrAccountNotEmpty)
+
+	_, err = testStore.TransferTx(context.Background(), db.TransferTxParams{
+		FromAccountID: account.ID,
+		ToAccountID:   other.ID,
+		Amount:        utils.NewMoney(5000, "USD"),
+	})
+	require.NoError(t, err)
+
+	closed, err := changeStatus(account, db.AccountStatusClosed)
+	require.NoError(t, err)
+	assert.Equal(t, db.AccountStatusClosed, closed.Status)
+	assert.True(t, closed.ClosedAt.Valid)
+
+	// Closed accounts take nothing, not even credits.
+	_, err = testStore.TransferTx(context.Background(), db.TransferTxParams{
+		FromAccountID: other.ID,
+		ToAccountID:   account.ID,
+		Amount:        utils.NewMoney(100, "USD"),
+	})
+	require.ErrorIs(t, err, db.ErrAccountClosed)
+
+	// The owner can open a new account in the same currency, after which the
+	// old one can no longer be reopened.
+	replacement, err := testQuery.CreateAccount(context.Background(), db.CreateAccountParams{
+		UserID:   account.UserID,
+		Currency: "USD",
+	})
+	require.NoError(t, err)
+
+	_, err = changeStatus(account, db.AccountStatusActive)
+	require.Error(t, err)
+
+	_, err = changeStatus(replacement, db.AccountStatusClosed)
+	require.NoError(t, err)
+
+	reopened, err := changeStatus(account, db.AccountStatusActive)
+	require.NoError(t, err)
+	assert.Equal(t, db.AccountStatusActive, reopened.Status)
+	assert.False(t, reopened.ClosedAt.Valid)
+}
+
+func TestCloseAccountWithPendingFunding(t *testing.T) {
+	defer clean_up_ledger()
+
+	account := createFundedAccount(t, "USD", 0)
+
+	_, err := testStore.CreateFundingTx(context.Background(), db.CreateFundingTxParams{
+		AccountID: account.ID,
+		Direction: db.FundingDeposit,
+		Amount:    utils.NewMoney(5000, "USD"),
+		Provider:  "fake",
+	})
+	require.NoError(t, err)
+
+	_, err = changeStatus(account, db.AccountStatusClosed)
+	require.ErrorIs(t, err, db.ErrAccountBusy)
+}
+
+func TestSystemAccountStatus(t *testing.T) {
+	defer clean_up_ledger()
+
+	equity, err := testQuery.UpsertSystemAccount(context.Background(), db.UpsertSystemAccountParams{
+		Kind:     db.AccountKindEquity,
+		Currency: "USD",
+	})
+	require.NoError(t, err)
+
+	_, err = testStore.ChangeAccountStatusTx(context.Background(), db.ChangeAccountStatusParams{
+		AccountID: equity.ID,
+		Status:    db.AccountStatusFrozen,
+		Reason:    "test",
+		ActorID:   sql.NullInt64{},
+	})
+	require.ErrorIs(t, err, db.ErrSystemAccountState)
+}
+
+func TestChangeAccountStatusFromStatuses(t *testing.T) {
+	defer clean_up_ledger()
+
+	account := createFundedAccount(t, "USD", 0)
+
+	_, err := changeStatus(account, db.AccountStatusFrozen)
+	require.NoError(t, err)
+
+	// An owner's reopen is checked against the locked row, so it can't undo
+	// a freeze that landed first.
+	_, err = testStore.ChangeAccountStatusTx(context.Background(), db.ChangeAccountStatusParams{
+		AccountID:    account.ID,
+		Status:       db.AccountStatusActive,
+		Reason:       "reopened by owner",
+		FromStatuses: []string{db.AccountStatusActive, db.AccountStatusDormant, db.AccountStatusClosed},
+	})
+	require.ErrorIs(t, err, db.ErrAccountFrozen)
+
+	current, err := testQuery.GetAccountByID(context.Background(), account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.AccountStatusFrozen, current.Status)
+}
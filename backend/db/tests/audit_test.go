@@ -0,0 +1,149 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github/kasho/backend/audit"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lastAuditEvent(t *testing.T, arg db.ListAuditEventsParams) db.AuditEvent {
+	arg.PageLimit = 1
+
+	events, err := testQuery.ListAuditEvents(context.Background(), arg)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	return events[0]
+}
+
+func auditTarget(targetType string, targetID int64) db.ListAuditEventsParams {
+	return db.ListAuditEventsParams{
+		TargetType: sql.NullString{String: targetType, Valid: true},
+		TargetID:   sql.NullString{String: strconv.FormatInt(targetID, 10), Valid: true},
+	}
+}
+
+func TestTransferIsAudited(t *testing.T) {
+	defer clean_up_ledger()
+
+	account1 := createFundedAccount(t, "USD", 10000)
+	account2 := createFundedAccount(t, "USD", 0)
+
+	ctx := audit.WithActor(context.Background(), audit.Actor{
+		UserID:    int64(account1.UserID.Int32),
+		IP:        "10.0.0.1",
+		UserAgent: "kasho-test",
+		RequestID: "req-1",
+	})
+
+	result, err := testStore.TransferTx(ctx, db.TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        utils.NewMoney(1000, "USD"),
+	})
+	require.NoError(t, err)
+
+	event := lastAuditEvent(t, auditTarget("transfer", result.Transfer.ID))
+	assert.Equal(t, "transfer.create", event.Action)
+	assert.Equal(t, int64(account1.UserID.Int32), event.ActorID.Int64)
+	assert.Equal(t, "10.0.0.1", event.Ip)
+	assert.Equal(t, "kasho-test", event.UserAgent)
+	assert.Equal(t, "req-1", event.RequestID)
+	assert.JSONEq(t, "null", string(event.BeforeState))
+
+	var after db.Transfer
+	require.NoError(t, json.Unmarshal(event.AfterState, &after))
+	assert.Equal(t, result.Transfer.ID, after.ID)
+	assert.Equal(t, result.Transfer.Amount, after.Amount)
+
+	// The opening balances were posted without an actor, by the system.
+	opening := lastAuditEvent(t, db.ListAuditEventsParams{
+		Action: sql.NullString{String: "journal.post", Valid: true},
+	})
+	assert.False(t, opening.ActorID.Valid)
+
+	report, err := testStore.VerifyAuditChain(context.Background())
+	require.NoError(t, err)
+	assert.True(t, report.OK(), report.Breaks)
+	assert.NotZero(t, report.Checked)
+}
+
+func TestAuditChainIsLinear(t *testing.T) {
+	defer clean_up_ledger()
+
+	account1 := createFundedAccount(t, "USD", 10000)
+	account2 := createFundedAccount(t, "USD", 10000)
+
+	n := 10
+	errs := make(chan error)
+
+	for i := 0; i < n; i++ {
+		fromAccountID, toAccountID := account1.ID, account2.ID
+		if i%2 == 1 {
+			fromAccountID, toAccountID = account2.ID, account1.ID
+		}
+
+		go func() {
+			_, err := testStore.TransferTx(context.Background(), db.TransferTxParams{
+				FromAccountID: fromAccountID,
+				ToAccountID:   toAccountID,
+				Amount:        utils.NewMoney(100, "USD"),
+			})
+			errs <- err
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	report, err := testStore.VerifyAuditChain(context.Background())
+	require.NoError(t, err)
+	assert.True(t, report.OK(), report.Breaks)
+}
+
+func TestAuditEventsAreAppendOnly(t *testing.T) {
+	defer clean_up_ledger()
+
+	createFundedAccount(t, "USD", 1000)
+
+	event := lastAuditEvent(t, db.ListAuditEventsParams{})
+
+	_, err := testDB.Exec("UPDATE audit_events SET action = 'tampered' WHERE id = $1", event.ID)
+	assert.ErrorContains(t, err, "append-only")
+
+	_, err = testDB.Exec("DELETE FROM audit_events WHERE id = $1", event.ID)
+	assert.ErrorContains(t, err, "append-only")
+
+	_, err = testDB.Exec("TRUNCATE audit_events")
+	assert.ErrorContains(t, err, "append-only")
+}
+
+func TestFailedChangeIsNotAudited(t *testing.T) {
+	defer clean_up_ledger()
+
+	account1 := createFundedAccount(t, "USD", 500)
+	account2 := createFundedAccount(t, "USD", 0)
+
+	before, err := testStore.VerifyAuditChain(context.Background())
+	require.NoError(t, err)
+
+	_, err = testStore.TransferTx(context.Background(), db.TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        utils.NewMoney(1000, "USD"),
+	})
+	require.ErrorIs(t, err, db.ErrInsufficientFunds)
+
+	after, err := testStore.VerifyAuditChain(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, before.Checked, after.Checked)
+}
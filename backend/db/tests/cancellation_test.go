@@ -0,0 +1,69 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatementTimeout(t *testing.T) {
+	config, err := utils.LoadConfig("../..")
+	require.NoError(t, err)
+
+	source, err := utils.WithStatementTimeout(config.DB_source, 100*time.Millisecond)
+	require.NoError(t, err)
+
+	conn, err := sql.Open(config.DBdriver, source)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.ExecContext(context.Background(), "SELECT pg_sleep(1)")
+	require.Error(t, err)
+
+	var pqErr *pq.Error
+	require.True(t, errors.As(err, &pqErr))
+	assert.Equal(t, pq.ErrorCode("57014"), pqErr.Code)
+}
+
+func TestQueryStopsAtDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := testDB.ExecContext(ctx, "SELECT pg_sleep(5)")
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestTransferTxCanceled(t *testing.T) {
+	defer clean_up_ledger()
+
+	account1 := createFundedAccount(t, "USD", 10000)
+	account2 := createFundedAccount(t, "USD", 10000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := testStore.TransferTx(ctx, db.TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        utils.NewMoney(1000, "USD"),
+	})
+	require.ErrorIs(t, err, context.Canceled)
+
+	updated1, err := testQuery.GetAccountByID(context.Background(), account1.ID)
+	require.NoError(t, err)
+	updated2, err := testQuery.GetAccountByID(context.Background(), account2.ID)
+	require.NoError(t, err)
+
+	assert.Equal(t, account1.Balance, updated1.Balance)
+	assert.Equal(t, account2.Balance, updated2.Balance)
+}
@@ -0,0 +1,76 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCurrencies(t *testing.T) {
+	currencies, err := testStore.LoadCurrencies(context.Background())
+	require.NoError(t, err)
+
+	registry := utils.NewCurrencyRegistry(currencies)
+
+	usd, ok := registry.Lookup("USD")
+	require.True(t, ok)
+	assert.True(t, usd.Enabled)
+	assert.Equal(t, 2, usd.Exponent)
+	assert.Equal(t, "840", usd.Numeric)
+
+	jpy, ok := registry.Lookup("JPY")
+	require.True(t, ok)
+	assert.False(t, jpy.Enabled)
+	assert.Equal(t, 0, jpy.Exponent)
+
+	kwd, ok := registry.Lookup("KWD")
+	require.True(t, ok)
+	assert.Equal(t, 3, kwd.Exponent)
+}
+
+func TestSyncCurrencies(t *testing.T) {
+	original, err := testQuery.GetCurrency(context.Background(), "KES")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, testStore.SyncCurrencies(context.Background(), []utils.Currency{original.Registry()}))
+	}()
+
+	kes := original.Registry()
+	kes.Enabled = true
+	kes.MinAmount = 100
+	kes.MaxAmount = 10000000
+
+	require.NoError(t, testStore.SyncCurrencies(context.Background(), []utils.Currency{kes}))
+
+	synced, err := testQuery.GetCurrency(context.Background(), "KES")
+	require.NoError(t, err)
+	assert.Equal(t, kes, synced.Registry())
+
+	disabled, err := testQuery.SetCurrencyEnabled(context.Background(), db.SetCurrencyEnabledParams{Enabled: false, Code: "KES"})
+	require.NoError(t, err)
+	assert.False(t, disabled.Enabled)
+
+	// A maximum below the minimum is rejected by the table.
+	_, err = testQuery.SetCurrencyLimits(context.Background(), db.SetCurrencyLimitsParams{MinAmount: 500, MaxAmount: 100, Code: "KES"})
+	assert.Error(t, err)
+
+	_, err = testQuery.SetCurrencyEnabled(context.Background(), db.SetCurrencyEnabledParams{Enabled: true, Code: "XXX"})
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestAccountCurrencyMustExist(t *testing.T) {
+	defer clean_up_ledger()
+
+	user := createRandomUser(t)
+
+	_, err := testQuery.CreateAccount(context.Background(), db.CreateAccountParams{
+		UserID:   sql.NullInt32{Int32: int32(user.ID), Valid: true},
+		Currency: "XXX",
+	})
+	assert.Error(t, err)
+}
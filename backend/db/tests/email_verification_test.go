@@ -0,0 +1,84 @@
+package db_test
+
+import (
+	"context"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func registerRandomUser(t *testing.T, expiresAt time.Time) (db.User, string) {
+	token, hash, err := utils.NewOpaqueToken()
+	require.NoError(t, err)
+
+	user, err := testStore.RegisterUserTx(context.Background(), db.RegisterUserParams{
+		Email:                 utils.RandomEmail(),
+		HashedPassword:        "hash",
+		VerificationTokenHash: hash,
+		VerificationExpiresAt: expiresAt,
+	})
+	require.NoError(t, err)
+
+	return user, token
+}
+
+func TestVerifyEmail(t *testing.T) {
+	defer clean_up()
+
+	user, token := registerRandomUser(t, time.Now().Add(time.Hour))
+	assert.False(t, user.EmailVerifiedAt.Valid)
+
+	verified, err := testStore.VerifyEmailTx(context.Background(), utils.HashOpaqueToken(token))
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, verified.ID)
+	assert.True(t, verified.EmailVerifiedAt.Valid)
+
+	// A token works once.
+	_, err = testStore.VerifyEmailTx(context.Background(), utils.HashOpaqueToken(token))
+	assert.ErrorIs(t, err, db.ErrVerificationTokenInvalid)
+
+	_, err = testStore.RequestEmailVerificationTx(context.Background(), db.RequestEmailVerificationParams{
+		UserID:    user.ID,
+		TokenHash: utils.HashOpaqueToken("another"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	assert.ErrorIs(t, err, db.ErrEmailAlreadyVerified)
+}
+
+func TestVerifyEmailRejectsBadTokens(t *testing.T) {
+	defer clean_up()
+
+	user, expired := registerRandomUser(t, time.Now().Add(-time.Minute))
+
+	_, err := testStore.VerifyEmailTx(context.Background(), utils.HashOpaqueToken(expired))
+	assert.ErrorIs(t, err, db.ErrVerificationTokenInvalid)
+
+	_, err = testStore.VerifyEmailTx(context.Background(), utils.HashOpaqueToken("unknown"))
+	assert.ErrorIs(t, err, db.ErrVerificationTokenInvalid)
+
+	// A resent token works where the expired one didn't.
+	token, hash, err := utils.NewOpaqueToken()
+	require.NoError(t, err)
+
+	_, err = testStore.RequestEmailVerificationTx(context.Background(), db.RequestEmailVerificationParams{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	recent, err := testQuery.CountRecentEmailVerificationTokens(context.Background(), db.CountRecentEmailVerificationTokensParams{
+		UserID:    user.ID,
+		CreatedAt: time.Now().Add(-time.Hour),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), recent)
+
+	verified, err := testStore.VerifyEmailTx(context.Background(), utils.HashOpaqueToken(token))
+	require.NoError(t, err)
+	assert.True(t, verified.EmailVerifiedAt.Valid)
+}
@@ -0,0 +1,105 @@
+package db_test
+
+import (
+	"context"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDepositLifecycle(t *testing.T) {
+	defer clean_up_ledger()
+
+	account := createFundedAccount(t, "USD", 0)
+
+	request, err := testStore.CreateFundingTx(context.Background(), db.CreateFundingTxParams{
+		AccountID: account.ID,
+		Direction: db.FundingDeposit,
+		Amount:    utils.NewMoney(5000, "USD"),
+		Provider:  "fake",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, db.FundingStatusPending, request.Status)
+	assert.False(t, request.JournalID.Valid)
+
+	// Nothing is credited while the deposit is pending.
+	pending, err := testQuery.GetAccountByID(context.Background(), account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, utils.Amount(0), pending.Balance)
+
+	settled, err := testStore.SettleFundingTx(context.Background(), request.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.FundingStatusSettled, settled.Status)
+	assert.True(t, settled.JournalID.Valid)
+	assert.True(t, settled.SettledAt.Valid)
+
+	credited, err := testQuery.GetAccountByID(context.Background(), account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, utils.Amount(5000), credited.Balance)
+
+	// A settled request cannot be settled or failed again.
+	_, err = testStore.SettleFundingTx(context.Background(), request.ID)
+	assert.ErrorIs(t, err, db.ErrFundingNotPending)
+	_, err = testStore.FailFundingTx(context.Background(), request.ID, "late decline")
+	assert.ErrorIs(t, err, db.ErrFundingNotPending)
+
+	report, err := testStore.VerifyLedger(context.Background())
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+}
+
+func TestWithdrawalLifecycle(t *testing.T) {
+	defer clean_up_ledger()
+
+	account := createFundedAccount(t, "USD", 10000)
+
+	withdraw := func(amount utils.Amount) (db.FundingRequest, error) {
+		return testStore.CreateFundingTx(context.Background(), db.CreateFundingTxParams{
+			AccountID: account.ID,
+			Direction: db.FundingWithdrawal,
+			Amount:    utils.NewMoney(amount, "USD"),
+			Provider:  "fake",
+		})
+	}
+
+	_, err := withdraw(20000)
+	assert.ErrorIs(t, err, db.ErrInsufficientFunds)
+
+	// Funds are held as soon as the withdrawal is requested.
+	failed, err := withdraw(4000)
+	require.NoError(t, err)
+	assert.True(t, failed.JournalID.Valid)
+
+	held, err := testQuery.GetAccountByID(context.Background(), account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, utils.Amount(6000), held.Balance)
+
+	// A failed withdrawal gives the money back.
+	failed, err = testStore.FailFundingTx(context.Background(), failed.ID, "declined by provider")
+	require.NoError(t, err)
+	assert.Equal(t, db.FundingStatusFailed, failed.Status)
+	assert.Equal(t, "declined by provider", failed.FailureReason)
+	assert.True(t, failed.ReversalJournalID.Valid)
+
+	restored, err := testQuery.GetAccountByID(context.Background(), account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, utils.Amount(10000), restored.Balance)
+
+	settled, err := withdraw(2500)
+	require.NoError(t, err)
+
+	settled, err = testStore.SettleFundingTx(context.Background(), settled.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.FundingStatusSettled, settled.Status)
+
+	final, err := testQuery.GetAccountByID(context.Background(), account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, utils.Amount(7500), final.Balance)
+
+	report, err := testStore.VerifyLedger(context.Background())
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+}
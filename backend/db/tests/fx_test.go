@@ -0,0 +1,114 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createQuote quotes selling 100.00 USD from usd for 154,225.00 NGN into ngn,
+// keeping a 1.00 USD fee.
+func createQuote(t *testing.T, usd db.Account, ngn db.Account, expiresAt time.Time) db.FxQuote {
+	rate, err := testQuery.CreateFxRate(context.Background(), db.CreateFxRateParams{
+		BaseCurrency:  "USD",
+		QuoteCurrency: "NGN",
+		Rate:          "1550",
+		Source:        "static",
+	})
+	require.NoError(t, err)
+
+	quote, err := testQuery.CreateFxQuote(context.Background(), db.CreateFxQuoteParams{
+		UserID:        int64(usd.UserID.Int32),
+		FromAccountID: usd.ID,
+		ToAccountID:   ngn.ID,
+		RateID:        rate.ID,
+		SellAmount:    10000,
+		SellCurrency:  "USD",
+		BuyAmount:     15268275,
+		BuyCurrency:   "NGN",
+		FeeAmount:     100,
+		SpreadAmount:  76725,
+		Rate:          "1542.25",
+		ExpiresAt:     expiresAt,
+	})
+	require.NoError(t, err)
+
+	return quote
+}
+
+func TestConvertTx(t *testing.T) {
+	defer clean_up_ledger()
+
+	usd := createFundedAccount(t, "USD", 50000)
+	ngn, err := testQuery.CreateAccount(context.Background(), db.CreateAccountParams{
+		UserID:   usd.UserID,
+		Currency: "NGN",
+	})
+	require.NoError(t, err)
+
+	quote := createQuote(t, usd, ngn, time.Now().Add(time.Minute))
+
+	// Only the user the quote was issued to can execute it.
+	_, err = testStore.ConvertTx(context.Background(), db.ConvertTxParams{QuoteID: quote.ID, UserID: quote.UserID + 1})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	result, err := testStore.ConvertTx(context.Background(), db.ConvertTxParams{QuoteID: quote.ID, UserID: quote.UserID})
+	require.NoError(t, err)
+
+	assert.Equal(t, db.JournalKindFXConversion, result.Journal.Kind)
+	assert.Equal(t, sql.NullInt64{Int64: result.Journal.ID, Valid: true}, result.Quote.JournalID)
+	assert.True(t, result.Quote.ExecutedAt.Valid)
+	assert.Equal(t, utils.Amount(40000), result.FromAccount.Balance)
+	assert.Equal(t, utils.Amount(15268275), result.ToAccount.Balance)
+
+	fees, err := testQuery.UpsertSystemAccount(context.Background(), db.UpsertSystemAccountParams{Kind: db.AccountKindFee, Currency: "USD"})
+	require.NoError(t, err)
+	assert.Equal(t, utils.Amount(100), fees.Balance)
+
+	usdHouse, err := testQuery.UpsertSystemAccount(context.Background(), db.UpsertSystemAccountParams{Kind: db.AccountKindFX, Currency: "USD"})
+	require.NoError(t, err)
+	assert.Equal(t, utils.Amount(9900), usdHouse.Balance)
+
+	ngnHouse, err := testQuery.UpsertSystemAccount(context.Background(), db.UpsertSystemAccountParams{Kind: db.AccountKindFX, Currency: "NGN"})
+	require.NoError(t, err)
+	assert.Equal(t, utils.Amount(-15268275), ngnHouse.Balance)
+
+	// A quote is good for one conversion only.
+	_, err = testStore.ConvertTx(context.Background(), db.ConvertTxParams{QuoteID: quote.ID, UserID: quote.UserID})
+	require.ErrorIs(t, err, db.ErrQuoteUsed)
+
+	expired := createQuote(t, usd, ngn, time.Now().Add(-time.Second))
+	_, err = testStore.ConvertTx(context.Background(), db.ConvertTxParams{QuoteID: expired.ID, UserID: expired.UserID})
+	require.ErrorIs(t, err, db.ErrQuoteExpired)
+
+	report, err := testStore.VerifyLedger(context.Background())
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+}
+
+func TestConvertTxInsufficientFunds(t *testing.T) {
+	defer clean_up_ledger()
+
+	usd := createFundedAccount(t, "USD", 5000)
+	ngn, err := testQuery.CreateAccount(context.Background(), db.CreateAccountParams{
+		UserID:   usd.UserID,
+		Currency: "NGN",
+	})
+	require.NoError(t, err)
+
+	quote := createQuote(t, usd, ngn, time.Now().Add(time.Minute))
+
+	_, err = testStore.ConvertTx(context.Background(), db.ConvertTxParams{QuoteID: quote.ID, UserID: quote.UserID})
+	require.ErrorIs(t, err, db.ErrInsufficientFunds)
+
+	// The quote stays usable once the account is topped up.
+	unused, err := testQuery.GetFxQuoteByID(context.Background(), quote.ID)
+	require.NoError(t, err)
+	assert.False(t, unused.ExecutedAt.Valid)
+}
@@ -0,0 +1,67 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountHistory(t *testing.T) {
+	defer clean_up_ledger()
+
+	account1 := createFundedAccount(t, "USD", 10000)
+	account2 := createFundedAccount(t, "USD", 5000)
+
+	transfer := func(from, to db.Account, amount utils.Amount) {
+		_, err := testStore.TransferTx(context.Background(), db.TransferTxParams{
+			FromAccountID: from.ID,
+			ToAccountID:   to.ID,
+			Amount:        utils.NewMoney(amount, "USD"),
+		})
+		require.NoError(t, err)
+	}
+
+	transfer(account1, account2, 1000)
+	transfer(account2, account1, 300)
+	transfer(account1, account2, 2500)
+
+	history, err := testStore.AccountHistory(context.Background(), db.AccountHistoryParams{
+		AccountID: account1.ID,
+		Limit:     10,
+	})
+	require.NoError(t, err)
+	require.Len(t, history, 4)
+
+	// Newest first, each with the balance right after it.
+	assert.Equal(t, utils.Amount(-2500), history[0].Amount)
+	assert.Equal(t, utils.Amount(6800), history[0].BalanceAfter)
+	assert.Equal(t, utils.Amount(300), history[1].Amount)
+	assert.Equal(t, utils.Amount(9300), history[1].BalanceAfter)
+	assert.Equal(t, utils.Amount(9000), history[2].BalanceAfter)
+	assert.Equal(t, utils.Amount(10000), history[3].BalanceAfter)
+
+	assert.True(t, history[0].TransferID.Valid)
+	assert.Equal(t, int32(account2.ID), history[0].CounterpartyAccountID.Int32)
+	assert.Equal(t, db.AccountKindUser, history[0].CounterpartyKind.String)
+
+	// The opening balance came from the equity account, not a transfer.
+	assert.False(t, history[3].TransferID.Valid)
+	assert.Equal(t, db.JournalKindOpeningBalance, history[3].Kind)
+	assert.Equal(t, db.AccountKindEquity, history[3].CounterpartyKind.String)
+
+	// A later page carries on from where the first one stopped.
+	page, err := testStore.AccountHistory(context.Background(), db.AccountHistoryParams{
+		AccountID: account1.ID,
+		BeforeID:  sql.NullInt64{Int64: history[1].ID, Valid: true},
+		Limit:     10,
+	})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, history[2], page[0])
+	assert.Equal(t, history[3], page[1])
+}
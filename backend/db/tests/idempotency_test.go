@@ -0,0 +1,63 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimIdempotencyKey(t *testing.T) {
+	defer clean_up()
+
+	user := createRandomUser(t)
+	key := utils.RandomString(16)
+
+	arg := db.ClaimIdempotencyKeyParams{
+		UserID:        user.ID,
+		Key:           key,
+		RequestHash:   "hash-1",
+		ExpiredBefore: time.Now().Add(-time.Hour),
+	}
+
+	claimed, err := testQuery.ClaimIdempotencyKey(context.Background(), arg)
+	assert.NoError(t, err)
+	assert.False(t, claimed.ResponseStatus.Valid)
+
+	// A second claim of a live key must not succeed.
+	_, err = testQuery.ClaimIdempotencyKey(context.Background(), arg)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	err = testQuery.CompleteIdempotencyKey(context.Background(), db.CompleteIdempotencyKeyParams{
+		ID:             claimed.ID,
+		ResponseStatus: sql.NullInt32{Int32: 201, Valid: true},
+		ResponseBody:   []byte(`{"id":1}`),
+	})
+	assert.NoError(t, err)
+
+	stored, err := testQuery.GetIdempotencyKey(context.Background(), db.GetIdempotencyKeyParams{
+		UserID: user.ID,
+		Key:    key,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(201), stored.ResponseStatus.Int32)
+	assert.Equal(t, `{"id":1}`, string(stored.ResponseBody))
+
+	// Once the key is past its retention window it can be claimed again.
+	arg.RequestHash = "hash-2"
+	arg.ExpiredBefore = time.Now().Add(time.Hour)
+
+	reclaimed, err := testQuery.ClaimIdempotencyKey(context.Background(), arg)
+	assert.NoError(t, err)
+	assert.Equal(t, claimed.ID, reclaimed.ID)
+	assert.Equal(t, "hash-2", reclaimed.RequestHash)
+	assert.False(t, reclaimed.ResponseStatus.Valid)
+
+	n, err := testQuery.DeleteExpiredIdempotencyKeys(context.Background(), time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+}
@@ -0,0 +1,94 @@
+package db_test
+
+import (
+	"context"
+	db "github/kasho/backend/db/sqlc"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostJournal(t *testing.T) {
+	defer clean_up_ledger()
+
+	account1 := createFundedAccount(t, "USD", 1000)
+	account2 := createFundedAccount(t, "USD", 0)
+	account3 := createFundedAccount(t, "USD", 0)
+
+	result, err := testStore.PostJournal(context.Background(), db.JournalParams{
+		Kind: db.JournalKindTransfer,
+		Postings: []db.Posting{
+			{AccountID: account1.ID, Amount: -600},
+			{AccountID: account2.ID, Amount: 400},
+			{AccountID: account3.ID, Amount: 200},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, result.Entries, 3)
+	assert.Equal(t, db.EntryTypeDebit, result.Entries[0].Type)
+	assert.Equal(t, db.EntryTypeCredit, result.Entries[1].Type)
+
+	assert.EqualValues(t, 400, result.Accounts[account1.ID].Balance)
+	assert.EqualValues(t, 400, result.Accounts[account2.ID].Balance)
+	assert.EqualValues(t, 200, result.Accounts[account3.ID].Balance)
+
+	entries, err := testQuery.GetEntriesByJournalID(context.Background(), result.Journal.ID)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	report, err := testStore.VerifyLedger(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, report.OK())
+}
+
+func TestPostJournalRejected(t *testing.T) {
+	defer clean_up_ledger()
+
+	usd := createFundedAccount(t, "USD", 1000)
+	usd2 := createFundedAccount(t, "USD", 0)
+	ngn := createFundedAccount(t, "NGN", 1000)
+
+	tests := []struct {
+		name     string
+		postings []db.Posting
+		err      error
+	}{
+		{"single posting", []db.Posting{{AccountID: usd.ID, Amount: 100}}, db.ErrInvalidPosting},
+		{"zero posting", []db.Posting{{AccountID: usd.ID, Amount: 0}, {AccountID: usd2.ID, Amount: 0}}, db.ErrInvalidPosting},
+		{"unbalanced", []db.Posting{{AccountID: usd.ID, Amount: -100}, {AccountID: usd2.ID, Amount: 99}}, db.ErrUnbalancedJournal},
+		{"cross currency", []db.Posting{{AccountID: usd.ID, Amount: -100}, {AccountID: ngn.ID, Amount: 100}}, db.ErrUnbalancedJournal},
+		{"overdraft", []db.Posting{{AccountID: usd.ID, Amount: -1001}, {AccountID: usd2.ID, Amount: 1001}}, db.ErrInsufficientFunds},
+	}
+
+	for _, tc := range tests {
+		_, err := testStore.PostJournal(context.Background(), db.JournalParams{
+			Kind:     db.JournalKindTransfer,
+			Postings: tc.postings,
+		})
+		assert.ErrorIs(t, err, tc.err, tc.name)
+	}
+
+	unchanged, err := testQuery.GetAccountByID(context.Background(), usd.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, usd.Balance, unchanged.Balance)
+}
+
+func TestVerifyLedgerDetectsDrift(t *testing.T) {
+	defer clean_up_ledger()
+
+	account := createFundedAccount(t, "USD", 1000)
+
+	// Bypass the journal to simulate a balance written by hand.
+	_, err := testQuery.AddAccountBalance(context.Background(), db.AddAccountBalanceParams{
+		ID:     account.ID,
+		Amount: 1,
+	})
+	assert.NoError(t, err)
+
+	report, err := testStore.VerifyLedger(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Len(t, report.UnreconciledAccounts, 1)
+	assert.Equal(t, account.ID, report.UnreconciledAccounts[0].ID)
+	assert.EqualValues(t, 1000, report.UnreconciledAccounts[0].EntriesTotal)
+}
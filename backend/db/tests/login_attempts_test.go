@@ -0,0 +1,78 @@
+package db_test
+
+import (
+	"context"
+	"github/kasho/backend/throttle"
+	"github/kasho/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresThrottleStore(t *testing.T) {
+	store := throttle.Postgres{Queries: testQuery}
+	ctx := context.Background()
+	key := "email:" + utils.RandomEmail()
+	defer store.Reset(ctx, key)
+
+	record, err := store.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Zero(t, record.Failures)
+
+	now := time.Now().Truncate(time.Second)
+
+	_, err = store.AddFailure(ctx, key, now, time.Hour)
+	require.NoError(t, err)
+	record, err = store.AddFailure(ctx, key, now.Add(time.Minute), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 2, record.Failures)
+	assert.WithinDuration(t, now.Add(time.Minute), record.LastFailure, time.Second)
+
+	// A failure after the window starts counting again.
+	record, err = store.AddFailure(ctx, key, now.Add(3*time.Hour), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, record.Failures)
+
+	until := now.Add(4 * time.Hour)
+	require.NoError(t, store.Lock(ctx, key, until))
+
+	record, err = store.Get(ctx, key)
+	require.NoError(t, err)
+	assert.WithinDuration(t, until, record.LockedUntil, time.Second)
+
+	require.NoError(t, store.Reset(ctx, key))
+
+	record, err = store.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Zero(t, record.Failures)
+}
+
+func TestThrottleLocksWithPostgres(t *testing.T) {
+	th := throttle.New(throttle.Postgres{Queries: testQuery}, throttle.Policy{
+		FreeAttempts:    1,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		MaxFailures:     3,
+		LockoutDuration: time.Minute,
+		Window:          time.Hour,
+	})
+	ctx := context.Background()
+	key := "ip:" + utils.RandomString(12)
+	defer th.Reset(ctx, key)
+
+	for i := 0; i < 2; i++ {
+		until, err := th.Fail(ctx, key)
+		require.NoError(t, err)
+		assert.True(t, until.IsZero())
+	}
+
+	until, err := th.Fail(ctx, key)
+	require.NoError(t, err)
+	assert.False(t, until.IsZero())
+
+	wait, err := th.Wait(ctx, key)
+	require.NoError(t, err)
+	assert.Greater(t, wait, 50*time.Second)
+}
@@ -12,7 +12,9 @@ import (
 	"github/kasho/backend/utils"
 )
 
+var testDB *sql.DB
 var testQuery *db.Queries
+var testStore *db.Store
 
 func TestMain(m *testing.M) {
 	config, err := utils.LoadConfig("../..")
@@ -25,7 +27,9 @@ func TestMain(m *testing.M) {
 		log.Fatal("Could not connect to database", err)
 	}
 
-	testQuery = db.New(conn)
+	testDB = conn
+	testStore = db.NewStore(conn)
+	testQuery = testStore.Queries
 
 	os.Exit(m.Run())
 }
\ No newline at end of file
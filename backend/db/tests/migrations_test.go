@@ -0,0 +1,139 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github/kasho/backend/db/migrations"
+	"github/kasho/backend/utils"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// schemaQuery lists the tables, columns, indexes, constraints, functions,
+// triggers and types of the public schema, so two states of the schema can
+// be compared. Column order is left out, as re-adding a column puts it last.
+const schemaQuery = `
+SELECT 'column ' || table_name || '.' || column_name || ' ' || data_type || ' ' || is_nullable || ' ' || coalesce(column_default, '')
+FROM information_schema.columns
+WHERE table_schema = 'public' AND table_name <> 'schema_migrations'
+UNION ALL
+SELECT 'index ' || indexdef
+FROM pg_indexes
+WHERE schemaname = 'public' AND tablename <> 'schema_migrations'
+UNION ALL
+SELECT 'constraint ' || conrelid::regclass || ' ' || conname || ' ' || pg_get_constraintdef(oid)
+FROM pg_constraint
+WHERE connamespace = 'public'::regnamespace
+UNION ALL
+SELECT 'function ' || proname || ' ' || md5(prosrc)
+FROM pg_proc
+WHERE pronamespace = 'public'::regnamespace
+UNION ALL
+SELECT 'trigger ' || tgrelid::regclass || ' ' || tgname
+FROM pg_trigger
+WHERE NOT tgisinternal
+UNION ALL
+SELECT 'type ' || typname
+FROM pg_type
+WHERE typnamespace = 'public'::regnamespace AND typtype = 'e'
+ORDER BY 1`
+
+func schema(t *testing.T) []string {
+	rows, err := testDB.QueryContext(context.Background(), schemaQuery)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var items []string
+	for rows.Next() {
+		var item string
+		require.NoError(t, rows.Scan(&item))
+		items = append(items, item)
+	}
+	require.NoError(t, rows.Err())
+
+	return items
+}
+
+// TestMigrationsRoundTrip applies every migration from an empty database,
+// checking that its down migration restores the schema exactly and that it
+// applies again afterwards. It leaves the test database at the latest
+// version, but without any data.
+func TestMigrationsRoundTrip(t *testing.T) {
+	config, err := utils.LoadConfig("../..")
+	require.NoError(t, err)
+
+	conn, err := sql.Open(config.DBdriver, config.DB_source)
+	require.NoError(t, err)
+
+	m, err := migrations.New(conn, nil)
+	require.NoError(t, err)
+	defer m.Close()
+
+	if err := m.Down(); !errors.Is(err, migrate.ErrNoChange) {
+		require.NoError(t, err)
+	}
+	assert.Empty(t, schema(t))
+
+	latest, err := migrations.Latest()
+	require.NoError(t, err)
+
+	for version := uint(1); version <= latest; version++ {
+		before := schema(t)
+
+		require.NoError(t, m.Steps(1), "up %d", version)
+		after := schema(t)
+
+		require.NoError(t, m.Steps(-1), "down %d", version)
+		assert.Equal(t, before, schema(t), "down %d does not undo up %d", version, version)
+
+		require.NoError(t, m.Steps(1), "up %d again", version)
+		assert.Equal(t, after, schema(t), "up %d differs the second time", version)
+	}
+
+	version, dirty, err := m.Version()
+	require.NoError(t, err)
+	assert.Equal(t, latest, version)
+	assert.False(t, dirty)
+}
+
+// TestLedgerMigrationDropsZeroEntries checks that 000004 migrates a database
+// holding zero-amount entries, which the schema before it allowed.
+func TestLedgerMigrationDropsZeroEntries(t *testing.T) {
+	config, err := utils.LoadConfig("../..")
+	require.NoError(t, err)
+
+	conn, err := sql.Open(config.DBdriver, config.DB_source)
+	require.NoError(t, err)
+
+	m, err := migrations.New(conn, nil)
+	require.NoError(t, err)
+	defer m.Close()
+
+	require.NoError(t, m.Migrate(3))
+	defer func() { require.NoError(t, m.Up()) }()
+
+	ctx := context.Background()
+
+	var accountID int64
+	err = testDB.QueryRowContext(ctx, `
+		WITH u AS (
+			INSERT INTO users (email, hashed_password) VALUES ($1, 'x') RETURNING id
+		)
+		INSERT INTO accounts (user_id, balance, currency) SELECT id, 0, 'USD' FROM u RETURNING id`,
+		utils.RandomEmail(),
+	).Scan(&accountID)
+	require.NoError(t, err)
+
+	_, err = testDB.ExecContext(ctx, `INSERT INTO entries (account_id, amount, type) VALUES ($1, 0, 'credit')`, accountID)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Migrate(4))
+
+	var count int
+	require.NoError(t, testDB.QueryRowContext(ctx, `SELECT count(*) FROM entries WHERE account_id = $1`, accountID).Scan(&count))
+	assert.Zero(t, count)
+}
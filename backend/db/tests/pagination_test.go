@@ -0,0 +1,96 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListTransfersByAccountIDs(t *testing.T) {
+	defer clean_up_ledger()
+
+	account1 := createFundedAccount(t, "USD", 10000)
+	account2 := createFundedAccount(t, "USD", 0)
+	other := createFundedAccount(t, "USD", 10000)
+
+	for _, amount := range []utils.Amount{100, 200, 300, 400, 500} {
+		_, err := testStore.TransferTx(context.Background(), db.TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        utils.NewMoney(amount, "USD"),
+		})
+		require.NoError(t, err)
+	}
+
+	// Transfers between other accounts stay out of the listing.
+	_, err := testStore.TransferTx(context.Background(), db.TransferTxParams{
+		FromAccountID: other.ID,
+		ToAccountID:   account1.ID,
+		Amount:        utils.NewMoney(50, "USD"),
+	})
+	require.NoError(t, err)
+
+	page, err := testQuery.ListTransfersByAccountIDs(context.Background(), db.ListTransfersByAccountIDsParams{
+		AccountIds: []int32{int32(account2.ID)},
+		PageLimit:  3,
+	})
+	require.NoError(t, err)
+	require.Len(t, page, 3)
+	assert.Equal(t, utils.Amount(500), page[0].Amount)
+
+	rest, err := testQuery.ListTransfersByAccountIDs(context.Background(), db.ListTransfersByAccountIDsParams{
+		AccountIds: []int32{int32(account2.ID)},
+		BeforeID:   sql.NullInt64{Int64: page[2].ID, Valid: true},
+		PageLimit:  3,
+	})
+	require.NoError(t, err)
+	require.Len(t, rest, 2)
+	assert.Equal(t, utils.Amount(200), rest[0].Amount)
+
+	filtered, err := testQuery.ListTransfersByAccountIDs(context.Background(), db.ListTransfersByAccountIDsParams{
+		AccountIds: []int32{int32(account2.ID)},
+		MinAmount:  sql.NullInt64{Int64: 200, Valid: true},
+		MaxAmount:  sql.NullInt64{Int64: 400, Valid: true},
+		PageLimit:  10,
+	})
+	require.NoError(t, err)
+	assert.Len(t, filtered, 3)
+}
+
+func TestListEntriesByAccountID(t *testing.T) {
+	defer clean_up_ledger()
+
+	account1 := createFundedAccount(t, "USD", 10000)
+	account2 := createFundedAccount(t, "USD", 0)
+
+	_, err := testStore.TransferTx(context.Background(), db.TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        utils.NewMoney(2500, "USD"),
+	})
+	require.NoError(t, err)
+
+	// The opening balance credit and the transfer debit.
+	entries, err := testQuery.ListEntriesByAccountID(context.Background(), db.ListEntriesByAccountIDParams{
+		AccountID: int32(account1.ID),
+		PageLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, utils.Amount(-2500), entries[0].Amount)
+
+	// Amount bounds match debits by their size.
+	entries, err = testQuery.ListEntriesByAccountID(context.Background(), db.ListEntriesByAccountIDParams{
+		AccountID: int32(account1.ID),
+		MaxAmount: sql.NullInt64{Int64: 5000, Valid: true},
+		PageLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, utils.Amount(-2500), entries[0].Amount)
+}
@@ -0,0 +1,126 @@
+package db_test
+
+import (
+	"context"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createSessionFor(t *testing.T, user db.User) db.Session {
+	_, hash, err := utils.NewRefreshToken()
+	require.NoError(t, err)
+
+	session, err := testStore.CreateSessionTx(context.Background(), db.CreateSessionTxParams{
+		UserID:           user.ID,
+		RefreshTokenHash: hash,
+		ExpiresAt:        time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	return session.Session
+}
+
+func requestPasswordReset(t *testing.T, user db.User, expiresAt time.Time) string {
+	token, hash, err := utils.NewOpaqueToken()
+	require.NoError(t, err)
+
+	_, err = testStore.RequestPasswordResetTx(context.Background(), db.RequestPasswordResetParams{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: expiresAt,
+	})
+	require.NoError(t, err)
+
+	return token
+}
+
+func TestChangePasswordRevokesSessions(t *testing.T) {
+	defer clean_up()
+
+	user := createRandomUser(t)
+	session := createSessionFor(t, user)
+
+	updated, err := testStore.ChangePasswordTx(context.Background(), db.ChangePasswordParams{
+		UserID:         user.ID,
+		HashedPassword: "new-hash",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "new-hash", updated.HashedPassword)
+
+	revoked, err := testQuery.GetSessionByID(context.Background(), session.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked.RevokedAt.Valid)
+}
+
+func TestResetPassword(t *testing.T) {
+	defer clean_up()
+
+	user := createRandomUser(t)
+	session := createSessionFor(t, user)
+	token := requestPasswordReset(t, user, time.Now().Add(time.Hour))
+
+	updated, err := testStore.ResetPasswordTx(context.Background(), db.ResetPasswordParams{
+		TokenHash:      utils.HashOpaqueToken(token),
+		HashedPassword: "reset-hash",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, updated.ID)
+	assert.Equal(t, "reset-hash", updated.HashedPassword)
+
+	revoked, err := testQuery.GetSessionByID(context.Background(), session.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked.RevokedAt.Valid)
+
+	// A token works once.
+	_, err = testStore.ResetPasswordTx(context.Background(), db.ResetPasswordParams{
+		TokenHash:      utils.HashOpaqueToken(token),
+		HashedPassword: "again",
+	})
+	assert.ErrorIs(t, err, db.ErrResetTokenInvalid)
+}
+
+func TestResetPasswordRejectsBadTokens(t *testing.T) {
+	defer clean_up()
+
+	user := createRandomUser(t)
+	expired := requestPasswordReset(t, user, time.Now().Add(-time.Minute))
+
+	_, err := testStore.ResetPasswordTx(context.Background(), db.ResetPasswordParams{
+		TokenHash:      utils.HashOpaqueToken(expired),
+		HashedPassword: "hash",
+	})
+	assert.ErrorIs(t, err, db.ErrResetTokenInvalid)
+
+	_, err = testStore.ResetPasswordTx(context.Background(), db.ResetPasswordParams{
+		TokenHash:      utils.HashOpaqueToken("unknown"),
+		HashedPassword: "hash",
+	})
+	assert.ErrorIs(t, err, db.ErrResetTokenInvalid)
+
+	// Changing the password retires tokens issued before it.
+	pending := requestPasswordReset(t, user, time.Now().Add(time.Hour))
+
+	_, err = testStore.ChangePasswordTx(context.Background(), db.ChangePasswordParams{
+		UserID:         user.ID,
+		HashedPassword: "changed",
+	})
+	require.NoError(t, err)
+
+	_, err = testStore.ResetPasswordTx(context.Background(), db.ResetPasswordParams{
+		TokenHash:      utils.HashOpaqueToken(pending),
+		HashedPassword: "hash",
+	})
+	assert.ErrorIs(t, err, db.ErrResetTokenInvalid)
+
+	recent, err := testQuery.CountRecentPasswordResetTokens(context.Background(), db.CountRecentPasswordResetTokensParams{
+		UserID:    user.ID,
+		CreatedAt: time.Now().Add(-time.Hour),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), recent)
+}
@@ -0,0 +1,13 @@
+package db_test
+
+import (
+	db "github/kasho/backend/db/sqlc"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryName(t *testing.T) {
+	assert.Equal(t, "GetAccount", db.QueryName("-- name: GetAccount :one\nSELECT 1"))
+	assert.Equal(t, "other", db.QueryName("SELECT version FROM schema_migrations"))
+}
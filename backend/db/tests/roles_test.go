@@ -0,0 +1,104 @@
+package db_test
+
+import (
+	"context"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func grantRole(t *testing.T, user db.User, role string) {
+	_, err := testStore.GrantRoleTx(context.Background(), db.UserRoleParams{UserID: user.ID, Role: role})
+	require.NoError(t, err)
+}
+
+func TestUserAccess(t *testing.T) {
+	defer clean_up()
+
+	user := createRandomUser(t)
+
+	access, err := testStore.UserAccess(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, access.Roles)
+	assert.Empty(t, access.Permissions)
+
+	grantRole(t, user, db.RoleSupport)
+	// Granting a role twice changes nothing.
+	grantRole(t, user, db.RoleSupport)
+
+	access, err = testStore.UserAccess(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{db.RoleSupport}, access.Roles)
+	assert.Equal(t, []string{
+		db.PermissionAccountsRead,
+		db.PermissionAuditRead,
+		db.PermissionTransfersRead,
+		db.PermissionUsersRead,
+	}, access.Permissions)
+
+	_, err = testStore.GrantRoleTx(context.Background(), db.UserRoleParams{UserID: user.ID, Role: "superuser"})
+	assert.ErrorIs(t, err, db.ErrUnknownRole)
+}
+
+func TestRolesWithPermissions(t *testing.T) {
+	roles, err := testStore.RolesWithPermissions(context.Background())
+	require.NoError(t, err)
+
+	byName := map[string][]string{}
+	for _, role := range roles {
+		byName[role.Name] = role.Permissions
+	}
+
+	assert.Contains(t, byName[db.RoleAdmin], db.PermissionRolesManage)
+	assert.NotContains(t, byName[db.RoleSupport], db.PermissionRolesManage)
+}
+
+func TestRevokeRoleRevokesSessions(t *testing.T) {
+	defer clean_up()
+
+	user := createRandomUser(t)
+	grantRole(t, user, db.RoleSupport)
+
+	_, hash, err := utils.NewRefreshToken()
+	require.NoError(t, err)
+
+	session, err := testStore.CreateSessionTx(context.Background(), db.CreateSessionTxParams{
+		UserID:           user.ID,
+		RefreshTokenHash: hash,
+		ExpiresAt:        time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	err = testStore.RevokeRoleTx(context.Background(), db.UserRoleParams{UserID: user.ID, Role: db.RoleSupport})
+	require.NoError(t, err)
+
+	revoked, err := testQuery.GetSessionByID(context.Background(), session.Session.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked.RevokedAt.Valid)
+
+	err = testStore.RevokeRoleTx(context.Background(), db.UserRoleParams{UserID: user.ID, Role: db.RoleSupport})
+	assert.ErrorIs(t, err, db.ErrRoleNotHeld)
+}
+
+func TestLastAdminKeepsRole(t *testing.T) {
+	defer clean_up()
+
+	admin1 := createRandomUser(t)
+	admin2 := createRandomUser(t)
+	grantRole(t, admin1, db.RoleAdmin)
+	grantRole(t, admin2, db.RoleAdmin)
+
+	err := testStore.RevokeRoleTx(context.Background(), db.UserRoleParams{UserID: admin1.ID, Role: db.RoleAdmin})
+	require.NoError(t, err)
+
+	err = testStore.RevokeRoleTx(context.Background(), db.UserRoleParams{UserID: admin2.ID, Role: db.RoleAdmin})
+	assert.ErrorIs(t, err, db.ErrLastAdmin)
+
+	access, err := testStore.UserAccess(context.Background(), admin2.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{db.RoleAdmin}, access.Roles)
+}
@@ -0,0 +1,87 @@
+package db_test
+
+import (
+	"context"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	defer clean_up()
+
+	user := createRandomUser(t)
+
+	_, hash1, err := utils.NewRefreshToken()
+	assert.NoError(t, err)
+
+	session, err := testStore.CreateSessionTx(context.Background(), db.CreateSessionTxParams{
+		UserID:           user.ID,
+		RefreshTokenHash: hash1,
+		ExpiresAt:        time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, session.Session.UserID)
+
+	_, hash2, err := utils.NewRefreshToken()
+	assert.NoError(t, err)
+
+	rotated, err := testStore.RotateRefreshTokenTx(context.Background(), db.RotateRefreshTokenTxParams{
+		TokenHash:    hash1,
+		NewTokenHash: hash2,
+		ExpiresAt:    time.Now().Add(2 * time.Hour),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, session.Session.ID, rotated.Session.ID)
+	assert.Equal(t, hash2, rotated.RefreshToken.TokenHash)
+	assert.WithinDuration(t, rotated.RefreshToken.ExpiresAt, rotated.Session.ExpiresAt, time.Second)
+	assert.True(t, rotated.Session.ExpiresAt.After(session.Session.ExpiresAt))
+
+	// Replaying the first token revokes the whole family, including hash2.
+	_, hash3, err := utils.NewRefreshToken()
+	assert.NoError(t, err)
+
+	_, err = testStore.RotateRefreshTokenTx(context.Background(), db.RotateRefreshTokenTxParams{
+		TokenHash:    hash1,
+		NewTokenHash: hash3,
+		ExpiresAt:    time.Now().Add(time.Hour),
+	})
+	assert.ErrorIs(t, err, db.ErrRefreshTokenReused)
+
+	revoked, err := testQuery.GetSessionByID(context.Background(), session.Session.ID)
+	assert.NoError(t, err)
+	assert.True(t, revoked.RevokedAt.Valid)
+
+	_, err = testStore.RotateRefreshTokenTx(context.Background(), db.RotateRefreshTokenTxParams{
+		TokenHash:    hash2,
+		NewTokenHash: hash3,
+		ExpiresAt:    time.Now().Add(time.Hour),
+	})
+	assert.ErrorIs(t, err, db.ErrRefreshTokenInvalid)
+}
+
+func TestRotateExpiredRefreshToken(t *testing.T) {
+	defer clean_up()
+
+	user := createRandomUser(t)
+
+	_, hash1, err := utils.NewRefreshToken()
+	assert.NoError(t, err)
+
+	_, err = testStore.CreateSessionTx(context.Background(), db.CreateSessionTxParams{
+		UserID:           user.ID,
+		RefreshTokenHash: hash1,
+		ExpiresAt:        time.Now().Add(-time.Minute),
+	})
+	assert.NoError(t, err)
+
+	_, err = testStore.RotateRefreshTokenTx(context.Background(), db.RotateRefreshTokenTxParams{
+		TokenHash:    hash1,
+		NewTokenHash: "unused",
+		ExpiresAt:    time.Now().Add(time.Hour),
+	})
+	assert.ErrorIs(t, err, db.ErrRefreshTokenInvalid)
+}
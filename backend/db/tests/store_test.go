@@ -0,0 +1,201 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func clean_up_ledger() {
+	ctx := context.Background()
+
+	if err := testQuery.DeleteAllFundingRequests(ctx); err != nil {
+		log.Fatal("Failed to delete all funding requests", err)
+	}
+	if err := testQuery.DeleteAllFxQuotes(ctx); err != nil {
+		log.Fatal("Failed to delete all fx quotes", err)
+	}
+	if err := testQuery.DeleteAllFxRates(ctx); err != nil {
+		log.Fatal("Failed to delete all fx rates", err)
+	}
+	if err := testQuery.DeleteAllEntries(ctx); err != nil {
+		log.Fatal("Failed to delete all entries", err)
+	}
+	if err := testQuery.DeleteAllTransfers(ctx); err != nil {
+		log.Fatal("Failed to delete all transfers", err)
+	}
+	if err := testQuery.DeleteAllJournals(ctx); err != nil {
+		log.Fatal("Failed to delete all journals", err)
+	}
+	if err := testQuery.DeleteAllAccountStatusChanges(ctx); err != nil {
+		log.Fatal("Failed to delete all account status changes", err)
+	}
+	if err := testQuery.DeleteAllAccounts(ctx); err != nil {
+		log.Fatal("Failed to delete all accounts", err)
+	}
+
+	clean_up()
+}
+
+func createFundedAccount(t *testing.T, currency string, balance utils.Amount) db.Account {
+	user := createRandomUser(t)
+
+	account, err := testQuery.CreateAccount(context.Background(), db.CreateAccountParams{
+		UserID:   sql.NullInt32{Int32: int32(user.ID), Valid: true},
+		Currency: currency,
+	})
+	assert.NoError(t, err)
+
+	if balance == 0 {
+		return account
+	}
+
+	equity, err := testQuery.UpsertSystemAccount(context.Background(), db.UpsertSystemAccountParams{
+		Kind:     db.AccountKindEquity,
+		Currency: currency,
+	})
+	assert.NoError(t, err)
+
+	result, err := testStore.PostJournal(context.Background(), db.JournalParams{
+		Kind: db.JournalKindOpeningBalance,
+		Postings: []db.Posting{
+			{AccountID: equity.ID, Amount: -balance},
+			{AccountID: account.ID, Amount: balance},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, balance, result.Accounts[account.ID].Balance)
+
+	return result.Accounts[account.ID]
+}
+
+func TestTransferTx(t *testing.T) {
+	defer clean_up_ledger()
+
+	account1 := createFundedAccount(t, "USD", 10000)
+	account2 := createFundedAccount(t, "USD", 10000)
+
+	n := 5
+	amount := utils.NewMoney(1000, "USD")
+
+	errs := make(chan error)
+	results := make(chan db.TransferTxResult)
+
+	for i := 0; i < n; i++ {
+		go func() {
+			result, err := testStore.TransferTx(context.Background(), db.TransferTxParams{
+				FromAccountID: account1.ID,
+				ToAccountID:   account2.ID,
+				Amount:        amount,
+			})
+
+			errs <- err
+			results <- result
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		err := <-errs
+		assert.NoError(t, err)
+
+		result := <-results
+		assert.NotEmpty(t, result.Transfer)
+		assert.Equal(t, int32(account1.ID), result.Transfer.FromAccountID)
+		assert.Equal(t, int32(account2.ID), result.Transfer.ToAccountID)
+		assert.Equal(t, amount.Amount, result.Transfer.Amount)
+
+		assert.Equal(t, -amount.Amount, result.FromEntry.Amount)
+		assert.Equal(t, db.EntryTypeDebit, result.FromEntry.Type)
+		assert.Equal(t, amount.Amount, result.ToEntry.Amount)
+		assert.Equal(t, db.EntryTypeCredit, result.ToEntry.Type)
+
+		assert.Equal(t, result.Transfer.JournalID, result.FromEntry.JournalID)
+		assert.Equal(t, result.Transfer.JournalID, result.ToEntry.JournalID)
+
+		_, err = testQuery.GetTransferByID(context.Background(), result.Transfer.ID)
+		assert.NoError(t, err)
+	}
+
+	updated1, err := testQuery.GetAccountByID(context.Background(), account1.ID)
+	assert.NoError(t, err)
+	updated2, err := testQuery.GetAccountByID(context.Background(), account2.ID)
+	assert.NoError(t, err)
+
+	assert.Equal(t, account1.Balance-utils.Amount(n)*amount.Amount, updated1.Balance)
+	assert.Equal(t, account2.Balance+utils.Amount(n)*amount.Amount, updated2.Balance)
+
+	report, err := testStore.VerifyLedger(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, report.OK())
+}
+
+func TestTransferTxDeadlock(t *testing.T) {
+	defer clean_up_ledger()
+
+	account1 := createFundedAccount(t, "USD", 10000)
+	account2 := createFundedAccount(t, "USD", 10000)
+
+	n := 10
+	amount := utils.NewMoney(1000, "USD")
+	errs := make(chan error)
+
+	for i := 0; i < n; i++ {
+		fromAccountID, toAccountID := account1.ID, account2.ID
+		if i%2 == 1 {
+			fromAccountID, toAccountID = account2.ID, account1.ID
+		}
+
+		go func() {
+			_, err := testStore.TransferTx(context.Background(), db.TransferTxParams{
+				FromAccountID: fromAccountID,
+				ToAccountID:   toAccountID,
+				Amount:        amount,
+			})
+
+			errs <- err
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		assert.NoError(t, <-errs)
+	}
+
+	updated1, err := testQuery.GetAccountByID(context.Background(), account1.ID)
+	assert.NoError(t, err)
+	updated2, err := testQuery.GetAccountByID(context.Background(), account2.ID)
+	assert.NoError(t, err)
+
+	assert.Equal(t, account1.Balance, updated1.Balance)
+	assert.Equal(t, account2.Balance, updated2.Balance)
+}
+
+func TestTransferTxRejected(t *testing.T) {
+	defer clean_up_ledger()
+
+	account1 := createFundedAccount(t, "USD", 500)
+	account2 := createFundedAccount(t, "USD", 0)
+	account3 := createFundedAccount(t, "NGN", 10000)
+
+	_, err := testStore.TransferTx(context.Background(), db.TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        utils.NewMoney(1000, "USD"),
+	})
+	assert.ErrorIs(t, err, db.ErrInsufficientFunds)
+
+	_, err = testStore.TransferTx(context.Background(), db.TransferTxParams{
+		FromAccountID: account3.ID,
+		ToAccountID:   account1.ID,
+		Amount:        utils.NewMoney(1000, "NGN"),
+	})
+	assert.ErrorIs(t, err, db.ErrCurrencyMismatch)
+
+	entries, err := testQuery.GetEntriesByAccountID(context.Background(), int32(account1.ID))
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,166 @@
+package db_test
+
+import (
+	"context"
+	db "github/kasho/backend/db/sqlc"
+	"github/kasho/backend/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The store only sees sealed secrets, so the tests store them in the clear
+// and check codes with the real TOTP.
+func totpCheck(code string) db.TOTPCheck {
+	return func(secret string, lastUsedStep int64) (int64, bool) {
+		return utils.ValidateTOTP(secret, code, time.Now(), lastUsedStep)
+	}
+}
+
+func currentCode(t *testing.T, secret string, offset int64) string {
+	code, err := utils.TOTPCode(secret, utils.TOTPStep(time.Now())+offset)
+	require.NoError(t, err)
+	return code
+}
+
+func enableTwoFactor(t *testing.T, user db.User) (secret string, recoveryCodes []string) {
+	secret, err := utils.NewTOTPSecret()
+	require.NoError(t, err)
+	require.NoError(t, testStore.EnrolTOTP(context.Background(), user.ID, secret))
+
+	codes, hashes, err := utils.NewRecoveryCodes(3)
+	require.NoError(t, err)
+
+	// Confirm with the previous step's code, leaving the current one for
+	// the test.
+	err = testStore.ConfirmTOTPTx(context.Background(), db.ConfirmTOTPParams{
+		UserID:             user.ID,
+		TOTP:               totpCheck(currentCode(t, secret, -1)),
+		RecoveryCodeHashes: hashes,
+	})
+	require.NoError(t, err)
+
+	return secret, codes
+}
+
+func TestEnrolTOTP(t *testing.T) {
+	defer clean_up()
+
+	user := createRandomUser(t)
+
+	err := testStore.ConfirmTOTPTx(context.Background(), db.ConfirmTOTPParams{UserID: user.ID, TOTP: totpCheck("000000")})
+	assert.ErrorIs(t, err, db.ErrTwoFactorNotEnabled)
+
+	// An unconfirmed secret can be replaced.
+	require.NoError(t, testStore.EnrolTOTP(context.Background(), user.ID, "JBSWY3DPEHPK3PXP"))
+	secret, _ := enableTwoFactor(t, user)
+
+	assert.ErrorIs(t, testStore.EnrolTOTP(context.Background(), user.ID, secret), db.ErrTwoFactorEnabled)
+
+	left, err := testQuery.CountUnusedRecoveryCodes(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), left)
+}
+
+func TestCompleteLogin(t *testing.T) {
+	defer clean_up()
+
+	user := createRandomUser(t)
+	secret, _ := enableTwoFactor(t, user)
+
+	challenge, challengeHash, err := utils.NewOpaqueToken()
+	require.NoError(t, err)
+
+	_, err = testQuery.CreateLoginChallenge(context.Background(), db.CreateLoginChallengeParams{
+		UserID:    user.ID,
+		TokenHash: challengeHash,
+		ExpiresAt: time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+
+	arg := db.CompleteLoginParams{
+		ChallengeHash:    utils.HashOpaqueToken(challenge),
+		Factor:           db.SecondFactor{TOTP: totpCheck("000000")},
+		RefreshTokenHash: utils.HashOpaqueToken("refresh"),
+		ExpiresAt:        time.Now().Add(time.Hour),
+	}
+
+	if currentCode(t, secret, 0) != "000000" {
+		_, err = testStore.CompleteLoginTx(context.Background(), arg)
+		assert.ErrorIs(t, err, db.ErrSecondFactorInvalid)
+	}
+
+	arg.Factor = db.SecondFactor{TOTP: totpCheck(currentCode(t, secret, 0))}
+	session, err := testStore.CompleteLoginTx(context.Background(), arg)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, session.Session.UserID)
+	assert.True(t, session.Session.StepUpAt.Valid)
+
+	// Neither the challenge nor the code works twice.
+	_, err = testStore.CompleteLoginTx(context.Background(), arg)
+	assert.ErrorIs(t, err, db.ErrLoginChallengeInvalid)
+}
+
+func TestRecoveryCodesWorkOnce(t *testing.T) {
+	defer clean_up()
+
+	user := createRandomUser(t)
+	_, codes := enableTwoFactor(t, user)
+	session := createSessionFor(t, user)
+
+	stepUp := func(code string) error {
+		return testStore.StepUpTx(context.Background(), db.StepUpParams{
+			UserID:    user.ID,
+			SessionID: session.ID,
+			Factor:    db.SecondFactor{RecoveryCodeHash: utils.HashRecoveryCode(code)},
+		})
+	}
+
+	require.NoError(t, stepUp(codes[0]))
+	assert.ErrorIs(t, stepUp(codes[0]), db.ErrSecondFactorInvalid)
+
+	steppedUp, err := testQuery.GetSessionByID(context.Background(), session.ID)
+	require.NoError(t, err)
+	assert.True(t, steppedUp.StepUpAt.Valid)
+
+	left, err := testQuery.CountUnusedRecoveryCodes(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), left)
+}
+
+func TestSecondFactorLockout(t *testing.T) {
+	defer clean_up()
+
+	user := createRandomUser(t)
+	_, codes := enableTwoFactor(t, user)
+
+	wrong := db.SecondFactor{RecoveryCodeHash: utils.HashRecoveryCode("wrong-code")}
+	for i := 0; i < db.MaxSecondFactorFailures; i++ {
+		err := testStore.DisableTOTPTx(context.Background(), user.ID, wrong)
+		require.ErrorIs(t, err, db.ErrSecondFactorInvalid)
+	}
+
+	// Even a right code is refused while locked.
+	right := db.SecondFactor{RecoveryCodeHash: utils.HashRecoveryCode(codes[0])}
+	err := testStore.DisableTOTPTx(context.Background(), user.ID, right)
+	assert.ErrorIs(t, err, db.ErrSecondFactorLocked)
+}
+
+func TestDisableTOTP(t *testing.T) {
+	defer clean_up()
+
+	user := createRandomUser(t)
+	_, codes := enableTwoFactor(t, user)
+
+	err := testStore.DisableTOTPTx(context.Background(), user.ID, db.SecondFactor{RecoveryCodeHash: utils.HashRecoveryCode(codes[1])})
+	require.NoError(t, err)
+
+	_, err = testQuery.GetTOTPCredential(context.Background(), user.ID)
+	assert.Error(t, err)
+
+	left, err := testQuery.CountUnusedRecoveryCodes(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.Zero(t, left)
+}
@@ -2,6 +2,7 @@ package db_test
 
 import (
 	"context"
+	"database/sql"
 	db "github/kasho/backend/db/sqlc"
 	"github/kasho/backend/utils"
 	"log"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func clean_up() {
@@ -21,7 +23,7 @@ func clean_up() {
 }
 
 func createRandomUser(t *testing.T) db.User {
-	hashedPassword, err := utils.GenerateHashPassword(utils.RandomString(6))
+	hashedPassword, err := utils.GenerateHashPassword(context.Background(), utils.RandomString(6))
 
 	if err != nil {
 		t.Fatal("Failed to generate hash password", err)
@@ -65,7 +67,7 @@ func TestUpdateUser(t *testing.T) {
 	
 	user :=createRandomUser(t)
 
-	newPassword, err := utils.GenerateHashPassword(utils.RandomString(6))
+	newPassword, err := utils.GenerateHashPassword(context.Background(), utils.RandomString(6))
 
 	if err != nil {
 		log.Fatal("Failed to generate hash password", err)
@@ -146,8 +148,7 @@ func TestListUsers(t *testing.T) {
 	wg.Wait()
 	
 	arg := db.ListUsersParams{
-		Offset: 0,
-		Limit: int32(limit),
+		PageLimit: int32(limit),
 	}
 
 	users, err := testQuery.ListUsers(context.Background(), arg)
@@ -157,4 +158,62 @@ func TestListUsers(t *testing.T) {
 
 	assert.Equal(t, len(users), limit)
 
-}
\ No newline at end of file
+	// Paging with the last id seen continues where the first page ended.
+	first, err := testQuery.ListUsers(context.Background(), db.ListUsersParams{PageLimit: 10})
+	require.NoError(t, err)
+	require.Len(t, first, 10)
+
+	next, err := testQuery.ListUsers(context.Background(), db.ListUsersParams{
+		BeforeID:  sql.NullInt64{Int64: first[9].ID, Valid: true},
+		PageLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, next, 10)
+
+	assert.Equal(t, users[10].ID, next[0].ID)
+	for i := 1; i < len(users); i++ {
+		assert.Greater(t, users[i-1].ID, users[i].ID)
+	}
+}
+
+// TestListUsersCreatedRange filters with bounds in zones other than UTC.
+// users.created_at has no time zone, so the bounds must be compared as
+// instants rather than by their wall clock time.
+func TestListUsersCreatedRange(t *testing.T) {
+	defer clean_up()
+
+	user := createRandomUser(t)
+	now := time.Now()
+	east := time.FixedZone("UTC+5", 5*60*60)
+	west := time.FixedZone("UTC-7", -7*60*60)
+
+	testCases := []struct {
+		name  string
+		from  time.Time
+		to    time.Time
+		found bool
+	}{
+		{"around now, east of UTC", now.Add(-time.Minute).In(east), now.Add(time.Minute).In(east), true},
+		{"around now, west of UTC", now.Add(-time.Minute).In(west), now.Add(time.Minute).In(west), true},
+		{"after now, east of UTC", now.Add(time.Minute).In(east), time.Time{}, false},
+		{"before now, west of UTC", time.Time{}, now.Add(-time.Minute).In(west), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			users, err := testQuery.ListUsers(context.Background(), db.ListUsersParams{
+				CreatedFrom: sql.NullTime{Time: tc.from, Valid: !tc.from.IsZero()},
+				CreatedTo:   sql.NullTime{Time: tc.to, Valid: !tc.to.IsZero()},
+				PageLimit:   10,
+			})
+			require.NoError(t, err)
+
+			if tc.found {
+				require.Len(t, users, 1)
+				assert.Equal(t, user.ID, users[0].ID)
+			} else {
+				assert.Empty(t, users)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package funding
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DeclinedMinorUnits makes the fake provider fail any amount whose minor
+// units end in it, e.g. 10.13 USD, so declines can be tried by hand.
+const DeclinedMinorUnits = 13
+
+// Fake is an in-process provider for local development and tests. Requests
+// settle once SettleAfter has passed since they were submitted, straight
+// away when it is zero, unless the amount is a declined one.
+type Fake struct {
+	SettleAfter time.Duration
+	// SubmitErr, when set, is returned by Submit after the request was
+	// recorded, as when the connection drops after the provider accepted
+	// it.
+	SubmitErr error
+
+	mu       sync.Mutex
+	requests map[string]fakeRequest
+	now      func() time.Time
+}
+
+type fakeRequest struct {
+	submittedAt time.Time
+	declined    bool
+}
+
+func NewFake(settleAfter time.Duration) *Fake {
+	return &Fake{
+		SettleAfter: settleAfter,
+		requests:    map[string]fakeRequest{},
+		now:         time.Now,
+	}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) Submit(ctx context.Context, req Request) (Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	reference := fmt.Sprintf("fake-%s-%d", req.Direction, req.ID)
+
+	if _, ok := f.requests[reference]; !ok {
+		f.requests[reference] = fakeRequest{
+			submittedAt: f.now(),
+			declined:    req.Amount.Amount%100 == DeclinedMinorUnits,
+		}
+	}
+
+	if f.SubmitErr != nil {
+		return Result{}, f.SubmitErr
+	}
+
+	return f.result(reference), nil
+}
+
+func (f *Fake) Status(ctx context.Context, reference string) (Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.requests[reference]; !ok {
+		return Result{}, ErrUnknownReference
+	}
+
+	return f.result(reference), nil
+}
+
+func (f *Fake) result(reference string) Result {
+	req := f.requests[reference]
+
+	switch {
+	case req.declined:
+		return Result{Reference: reference, Status: StatusFailed, FailureReason: "declined by provider"}
+	case f.now().Sub(req.submittedAt) >= f.SettleAfter:
+		return Result{Reference: reference, Status: StatusSettled}
+	}
+
+	return Result{Reference: reference, Status: StatusPending}
+}
@@ -0,0 +1,61 @@
+package funding
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github/kasho/backend/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeProvider(t *testing.T) {
+	now := time.Now()
+	fake := NewFake(time.Minute)
+	fake.now = func() time.Time { return now }
+
+	var provider Provider = fake
+
+	result, err := provider.Submit(context.Background(), Request{ID: 1, Direction: Deposit, Amount: utils.NewMoney(1000, "USD")})
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, result.Status)
+
+	// Submitting again is idempotent.
+	again, err := provider.Submit(context.Background(), Request{ID: 1, Direction: Deposit, Amount: utils.NewMoney(1000, "USD")})
+	require.NoError(t, err)
+	assert.Equal(t, result, again)
+
+	now = now.Add(time.Minute)
+
+	result, err = provider.Status(context.Background(), result.Reference)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSettled, result.Status)
+
+	declined, err := provider.Submit(context.Background(), Request{ID: 2, Direction: Withdrawal, Amount: utils.NewMoney(1013, "USD")})
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, declined.Status)
+	assert.NotEmpty(t, declined.FailureReason)
+
+	_, err = provider.Status(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrUnknownReference)
+}
+
+func TestFakeProviderLostAnswer(t *testing.T) {
+	fake := NewFake(0)
+	fake.SubmitErr = errors.New("connection reset")
+
+	req := Request{ID: 3, Direction: Withdrawal, Amount: utils.NewMoney(1000, "USD")}
+
+	_, err := fake.Submit(context.Background(), req)
+	require.Error(t, err)
+
+	// The request was taken, so submitting it again finds it.
+	fake.SubmitErr = nil
+	result, err := fake.Submit(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSettled, result.Status)
+	assert.Len(t, fake.requests, 1)
+}
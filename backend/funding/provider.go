@@ -0,0 +1,61 @@
+// Package funding connects deposits and withdrawals to whatever moves money
+// in and out of Kasho in the real world: a bank, a card processor, a mobile
+// money operator. The ledger only sees a Provider.
+package funding
+
+import (
+	"context"
+	"errors"
+
+	"github/kasho/backend/utils"
+)
+
+var ErrUnknownReference = errors.New("unknown provider reference")
+
+// ErrRejected is wrapped by Submit errors that mean the provider refused the
+// request and did nothing with it. Any other error leaves the outcome
+// unknown: the provider may have accepted the request before the answer was
+// lost.
+var ErrRejected = errors.New("rejected by provider")
+
+type Direction string
+
+const (
+	Deposit    Direction = "deposit"
+	Withdrawal Direction = "withdrawal"
+)
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusSettled Status = "settled"
+	StatusFailed  Status = "failed"
+)
+
+// Request is what the provider is asked to do. ID is the funding request id
+// and doubles as the provider's idempotency key.
+type Request struct {
+	ID        int64
+	AccountID int64
+	Direction Direction
+	Amount    utils.Money
+}
+
+// Result is the provider's view of a request. FailureReason is only set
+// when Status is StatusFailed.
+type Result struct {
+	Reference     string
+	Status        Status
+	FailureReason string
+}
+
+// Provider submits funding requests and reports on them. Submit may settle
+// or fail a request at once or leave it pending; pending requests are polled
+// with Status until they reach a final state. Submit must be idempotent on
+// Request.ID, since a request whose answer was lost is submitted again.
+type Provider interface {
+	Name() string
+	Submit(ctx context.Context, req Request) (Result, error)
+	Status(ctx context.Context, reference string) (Result, error)
+}
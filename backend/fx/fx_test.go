@@ -0,0 +1,102 @@
+package fx
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github/kasho/backend/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticProvider(t *testing.T) {
+	var provider Provider
+	provider, err := NewStatic("static", map[string]string{"USD/NGN": "1550"})
+	require.NoError(t, err)
+
+	rate, err := provider.Rate(context.Background(), "USD", "NGN")
+	require.NoError(t, err)
+	assert.Equal(t, "1550", rate.Value.RatString())
+
+	// Inverse pairs are derived.
+	rate, err = provider.Rate(context.Background(), "NGN", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, "1/1550", rate.Value.RatString())
+
+	_, err = provider.Rate(context.Background(), "USD", "ZAR")
+	require.ErrorIs(t, err, ErrUnknownPair)
+
+	for _, rates := range []map[string]string{
+		{"USDNGN": "1550"},
+		{"USD/USD": "1"},
+		{"USD/NGN": "abc"},
+		{"USD/NGN": "0"},
+	} {
+		_, err := NewStatic("static", rates)
+		assert.Error(t, err, rates)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	provider, err := LoadFile("")
+	require.NoError(t, err)
+	assert.Equal(t, "static", provider.Name())
+
+	_, err = provider.Rate(context.Background(), "ZAR", "USD")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "rates.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"USD/ZAR": "18.25"}`), 0o600))
+
+	provider, err = LoadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "file", provider.Name())
+
+	rate, err := provider.Rate(context.Background(), "USD", "ZAR")
+	require.NoError(t, err)
+	assert.Equal(t, "73/4", rate.Value.RatString())
+}
+
+func TestPrice(t *testing.T) {
+	mid := big.NewRat(1550, 1)
+
+	testCases := []struct {
+		name    string
+		pricing Pricing
+		sell    utils.Money
+		buy     utils.Amount
+		fee     utils.Amount
+		spread  utils.Amount
+	}{
+		{"at mid", Pricing{}, utils.NewMoney(10000, "USD"), 15500000, 0, 0},
+		{"spread", Pricing{SpreadBps: 50}, utils.NewMoney(10000, "USD"), 15422500, 0, 77500},
+		{"spread and fee", Pricing{SpreadBps: 50, FeeBps: 100}, utils.NewMoney(10000, "USD"), 15268275, 100, 76725},
+		{"fee rounds half up", Pricing{FeeBps: 50}, utils.NewMoney(100, "USD"), 153450, 1, 0},
+		{"buy rounds down", Pricing{SpreadBps: 33}, utils.NewMoney(1, "USD"), 1544, 0, 6},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			quote, err := tc.pricing.Price(tc.sell, "NGN", mid)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.sell, quote.Sell)
+			assert.Equal(t, utils.NewMoney(tc.buy, "NGN"), quote.Buy)
+			assert.Equal(t, utils.NewMoney(tc.fee, "USD"), quote.Fee)
+			assert.Equal(t, utils.NewMoney(tc.spread, "NGN"), quote.Spread)
+		})
+	}
+
+	_, err := Pricing{SpreadBps: 50}.Price(utils.NewMoney(1, "NGN"), "USD", new(big.Rat).Inv(mid))
+	require.ErrorIs(t, err, ErrAmountTooSmall)
+
+	_, err = Pricing{}.Price(utils.NewMoney(100, "XYZ"), "USD", mid)
+	require.ErrorIs(t, err, utils.ErrUnsupportedCurrency)
+
+	_, err = Pricing{SpreadBps: 10000}.Price(utils.NewMoney(100, "USD"), "NGN", mid)
+	require.Error(t, err)
+}
@@ -0,0 +1,105 @@
+package fx
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github/kasho/backend/utils"
+)
+
+var ErrAmountTooSmall = errors.New("amount is too small to convert")
+
+const basisPoints = 10000
+
+// Pricing is what Kasho charges for a conversion: a spread taken off the mid
+// rate and a fee taken off the amount sold, both in basis points.
+type Pricing struct {
+	SpreadBps int64
+	FeeBps    int64
+}
+
+// Quote is a priced conversion. The customer sells Sell, of which Fee is
+// kept and the rest is converted at Rate into Buy. Spread is what converting
+// at Rate instead of the mid rate kept back, in the buy currency.
+type Quote struct {
+	Sell   utils.Money
+	Fee    utils.Money
+	Buy    utils.Money
+	Spread utils.Money
+	Rate   *big.Rat
+}
+
+// Price quotes selling sell for buyCurrency at the given mid rate. The fee is
+// rounded to the nearest minor unit and the amount bought is rounded down.
+func (p Pricing) Price(sell utils.Money, buyCurrency string, mid *big.Rat) (Quote, error) {
+	if p.SpreadBps < 0 || p.SpreadBps >= basisPoints || p.FeeBps < 0 || p.FeeBps >= basisPoints {
+		return Quote{}, fmt.Errorf("invalid fx pricing: spread %d bps, fee %d bps", p.SpreadBps, p.FeeBps)
+	}
+
+	sellExp, ok := utils.CurrencyExponent(sell.Currency)
+	if !ok {
+		return Quote{}, utils.ErrUnsupportedCurrency
+	}
+	buyExp, ok := utils.CurrencyExponent(buyCurrency)
+	if !ok {
+		return Quote{}, utils.ErrUnsupportedCurrency
+	}
+
+	fee := new(big.Rat).Mul(new(big.Rat).SetInt64(int64(sell.Amount)), big.NewRat(p.FeeBps, basisPoints))
+	feeAmount := roundHalfUp(fee)
+
+	net := new(big.Int).Sub(big.NewInt(int64(sell.Amount)), feeAmount)
+
+	rate := p.CustomerRate(mid)
+
+	// Minor units of the sell currency to minor units of the buy currency.
+	scale := new(big.Rat).SetFrac(pow10(buyExp), pow10(sellExp))
+
+	atMid := new(big.Rat).Mul(new(big.Rat).SetInt(net), mid)
+	atMid.Mul(atMid, scale)
+
+	atRate := new(big.Rat).Mul(new(big.Rat).SetInt(net), rate)
+	atRate.Mul(atRate, scale)
+
+	buy := floor(atRate)
+	spread := new(big.Int).Sub(floor(atMid), buy)
+
+	if buy.Sign() <= 0 {
+		return Quote{}, ErrAmountTooSmall
+	}
+	if !buy.IsInt64() {
+		return Quote{}, utils.ErrAmountOverflow
+	}
+
+	return Quote{
+		Sell:   sell,
+		Fee:    utils.NewMoney(utils.Amount(feeAmount.Int64()), sell.Currency),
+		Buy:    utils.NewMoney(utils.Amount(buy.Int64()), buyCurrency),
+		Spread: utils.NewMoney(utils.Amount(spread.Int64()), buyCurrency),
+		Rate:   rate,
+	}, nil
+}
+
+// CustomerRate is the mid rate less the spread.
+func (p Pricing) CustomerRate(mid *big.Rat) *big.Rat {
+	return new(big.Rat).Mul(mid, big.NewRat(basisPoints-p.SpreadBps, basisPoints))
+}
+
+// FormatRate renders a rate the way it is stored, with 12 decimals.
+func FormatRate(rate *big.Rat) string {
+	return rate.FloatString(12)
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func floor(r *big.Rat) *big.Int {
+	q, _ := new(big.Int).DivMod(r.Num(), r.Denom(), new(big.Int))
+	return q
+}
+
+func roundHalfUp(r *big.Rat) *big.Int {
+	return floor(new(big.Rat).Add(r, big.NewRat(1, 2)))
+}
@@ -0,0 +1,97 @@
+// Package fx prices currency conversions. A Provider supplies mid-market
+// rates and Pricing turns a rate into what the customer gets.
+package fx
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+var ErrUnknownPair = errors.New("no rate for currency pair")
+
+// Rate is a mid-market rate: one major unit of Base is worth Value major
+// units of Quote.
+type Rate struct {
+	Base   string
+	Quote  string
+	Value  *big.Rat
+	Source string
+}
+
+type Provider interface {
+	Name() string
+	Rate(ctx context.Context, base, quote string) (Rate, error)
+}
+
+//go:embed default_rates.json
+var defaultRates []byte
+
+// Static serves a fixed set of rates keyed "BASE/QUOTE". The inverse of every
+// pair is served too, so each pair only needs to be listed once.
+type Static struct {
+	source string
+	rates  map[string]*big.Rat
+}
+
+// NewStatic parses rates given as decimal strings, e.g. {"USD/NGN": "1550.25"}.
+func NewStatic(source string, rates map[string]string) (*Static, error) {
+	s := &Static{source: source, rates: map[string]*big.Rat{}}
+
+	for pair, value := range rates {
+		base, quote, ok := strings.Cut(pair, "/")
+		if !ok || base == "" || quote == "" || base == quote {
+			return nil, fmt.Errorf("invalid currency pair %q", pair)
+		}
+
+		rate, ok := new(big.Rat).SetString(value)
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid rate %q for %s", value, pair)
+		}
+
+		s.rates[base+"/"+quote] = rate
+		if _, ok := rates[quote+"/"+base]; !ok {
+			s.rates[quote+"/"+base] = new(big.Rat).Inv(rate)
+		}
+	}
+
+	return s, nil
+}
+
+// LoadFile reads a JSON object of pair to rate. An empty path loads the
+// rates bundled with the binary, which are only meant for development.
+func LoadFile(path string) (*Static, error) {
+	data, source := defaultRates, "static"
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+		source = "file"
+	}
+
+	var rates map[string]string
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("fx rates %s: %w", path, err)
+	}
+
+	return NewStatic(source, rates)
+}
+
+func (s *Static) Name() string {
+	return s.source
+}
+
+func (s *Static) Rate(ctx context.Context, base, quote string) (Rate, error) {
+	rate, ok := s.rates[base+"/"+quote]
+	if !ok {
+		return Rate{}, fmt.Errorf("%w: %s/%s", ErrUnknownPair, base, quote)
+	}
+
+	return Rate{Base: base, Quote: quote, Value: new(big.Rat).Set(rate), Source: s.source}, nil
+}
@@ -0,0 +1,75 @@
+// Package mail sends the emails Kasho needs, such as password reset links.
+// Handlers only see a Mailer; the ones that ship are for development and
+// record messages instead of delivering them.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers a message. Send returning nil means the message was
+// handed over, not that it reached the inbox.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Log writes every message to the logger, body included. The application
+// logger masks the tokens in links, so use File to follow them.
+type Log struct {
+	Logger *slog.Logger
+}
+
+func (l Log) Send(ctx context.Context, msg Message) error {
+	l.Logger.InfoContext(ctx, "email sent",
+		"from", msg.From,
+		"to", msg.To,
+		"subject", msg.Subject,
+		"body", msg.Body,
+	)
+	return nil
+}
+
+// File writes every message as an .eml file in Dir, which most mail
+// clients can open.
+type File struct {
+	Dir string
+
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+func NewFile(dir string) (*File, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &File{Dir: dir, now: time.Now}, nil
+}
+
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9@._-]+`)
+
+func (f *File) Send(ctx context.Context, msg Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000Z"), unsafeName.ReplaceAllString(msg.To, "_"))
+
+	content := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		msg.From, msg.To, msg.Subject, now.Format(time.RFC1123Z), msg.Body)
+
+	return os.WriteFile(filepath.Join(f.Dir, name), []byte(content), 0o600)
+}
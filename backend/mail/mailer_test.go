@@ -0,0 +1,46 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+
+	mailer, err := NewFile(dir)
+	require.NoError(t, err)
+	mailer.now = func() time.Time { return time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC) }
+
+	var m Mailer = mailer
+
+	msg := PasswordReset("no-reply@kasho.test", "ada/../x@example.com", "http://localhost/reset?token=abc", time.Hour)
+	require.NoError(t, m.Send(context.Background(), msg))
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	// The recipient can't steer the file out of the directory.
+	assert.Equal(t, "20250601T100000.000000000Z-ada_.._x@example.com.eml", files[0].Name())
+
+	content, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), "From: no-reply@kasho.test\r\nTo: ada/../x@example.com\r\n"))
+	assert.Contains(t, string(content), "http://localhost/reset?token=abc")
+	assert.Contains(t, string(content), "within 1 hour")
+}
+
+func TestFormatDuration(t *testing.T) {
+	assert.Equal(t, "1 hour", formatDuration(time.Hour))
+	assert.Equal(t, "2 hours", formatDuration(2*time.Hour))
+	assert.Equal(t, "30 minutes", formatDuration(30*time.Minute))
+	assert.Equal(t, "90 minutes", formatDuration(90*time.Minute))
+	assert.Equal(t, "45s", formatDuration(45*time.Second))
+}
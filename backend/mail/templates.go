@@ -0,0 +1,88 @@
+package mail
+
+import (
+	"fmt"
+	"time"
+)
+
+// EmailVerification is the email with a link proving the user owns the
+// address they registered with.
+func EmailVerification(from string, to string, link string, ttl time.Duration) Message {
+	return Message{
+		From:    from,
+		To:      to,
+		Subject: "Verify your Kasho email",
+		Body: fmt.Sprintf(`Welcome to Kasho.
+
+Confirm this is your email address within %s:
+
+%s
+
+Until you do, you can sign in but not open accounts or move money.
+
+If you didn't sign up, ignore this email.`, formatDuration(ttl), link),
+	}
+}
+
+// PasswordReset is the email with a link to choose a new password.
+func PasswordReset(from string, to string, link string, ttl time.Duration) Message {
+	return Message{
+		From:    from,
+		To:      to,
+		Subject: "Reset your Kasho password",
+		Body: fmt.Sprintf(`Someone asked to reset the password of your Kasho account.
+
+Choose a new password here within %s:
+
+%s
+
+If it wasn't you, ignore this email. Your password stays the same.`, formatDuration(ttl), link),
+	}
+}
+
+// PasswordChanged tells the user their password was changed, so they notice
+// if it wasn't them.
+func PasswordChanged(from string, to string) Message {
+	return Message{
+		From:    from,
+		To:      to,
+		Subject: "Your Kasho password was changed",
+		Body: `The password of your Kasho account was just changed and every device was signed out.
+
+If it wasn't you, reset your password straight away and contact support.`,
+	}
+}
+
+// LoginLocked tells the user that wrong passwords locked their login, in
+// case someone is trying to guess it.
+func LoginLocked(from string, to string, lockout time.Duration) Message {
+	return Message{
+		From:    from,
+		To:      to,
+		Subject: "Sign-in to your Kasho account was locked",
+		Body: fmt.Sprintf(`There were too many attempts to sign in to your Kasho account with a wrong password, so signing in is blocked for %s.
+
+If it was you, wait and try again, or reset your password.
+
+If it wasn't, someone may be trying to guess your password. Your account is safe as long as they don't know it; consider turning on two-factor authentication.`, formatDuration(lockout)),
+	}
+}
+
+// formatDuration renders whole hours and minutes the way people write them.
+func formatDuration(d time.Duration) string {
+	unit, n := "", int64(0)
+
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		unit, n = "hour", int64(d/time.Hour)
+	case d >= time.Minute && d%time.Minute == 0:
+		unit, n = "minute", int64(d/time.Minute)
+	default:
+		return d.String()
+	}
+
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
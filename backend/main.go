@@ -1,10 +1,36 @@
 package main
 
-import "github/kasho/backend/api"
+import (
+	"fmt"
+	"github/kasho/backend/api"
+	"log/slog"
+	"os"
+)
 
 func main() {
 	// api.NewServer(3000)
 
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	server := api.NewServer(".")
-	server.Start(3000)
+	if err := server.Start(3000); err != nil {
+		slog.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+// runCommand runs a maintenance command instead of the server and returns
+// the exit code.
+func runCommand(args []string) int {
+	switch {
+	case len(args) == 2 && args[0] == "audit" && args[1] == "verify":
+		return auditVerify()
+	case len(args) >= 1 && args[0] == "migrate":
+		return migrateCommand(args[1:])
+	}
+
+	fmt.Fprintln(os.Stderr, "usage: kasho [audit verify | migrate <command>]")
+	return 2
 }
\ No newline at end of file
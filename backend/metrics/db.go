@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	db "github/kasho/backend/db/sqlc"
+)
+
+// WrapDBTX times every query run through next. Pass it to db.NewStore so
+// queries inside transactions are timed too.
+func (m *Metrics) WrapDBTX(next db.DBTX) db.DBTX {
+	return timedDBTX{next: next, metrics: m}
+}
+
+type timedDBTX struct {
+	next    db.DBTX
+	metrics *Metrics
+}
+
+func (t timedDBTX) observe(query string, start time.Time) {
+	t.metrics.queryDuration.WithLabelValues(db.QueryName(query)).Observe(time.Since(start).Seconds())
+}
+
+func (t timedDBTX) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer t.observe(query, time.Now())
+	return t.next.ExecContext(ctx, query, args...)
+}
+
+func (t timedDBTX) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return t.next.PrepareContext(ctx, query)
+}
+
+func (t timedDBTX) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer t.observe(query, time.Now())
+	return t.next.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext is timed until the row is available. database/sql runs
+// the query before returning, so only the Scan is left out.
+func (t timedDBTX) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer t.observe(query, time.Now())
+	return t.next.QueryRowContext(ctx, query, args...)
+}
@@ -0,0 +1,128 @@
+// Package metrics holds the Prometheus metrics of the server. Everything is
+// registered on the registry passed to New, so tests can use their own and
+// read the values back.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github/kasho/backend/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "kasho"
+
+// Results of a login attempt.
+const (
+	LoginSuccess   = "success"
+	LoginFailure   = "failure"
+	LoginThrottled = "throttled"
+)
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests  *prometheus.HistogramVec
+	queryDuration *prometheus.HistogramVec
+
+	registrations   prometheus.Counter
+	logins          *prometheus.CounterVec
+	accountsCreated *prometheus.CounterVec
+	transfers       *prometheus.CounterVec
+	transferVolume  *prometheus.CounterVec
+}
+
+// New creates the metrics and registers them on registry.
+func New(registry *prometheus.Registry) *Metrics {
+	m := &Metrics{
+		registry: registry,
+		httpRequests: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to serve HTTP requests, by route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Time taken by database queries, by sqlc query name.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"query"}),
+		registrations: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "registrations_total",
+			Help:      "Users registered.",
+		}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Login attempts, by result: success, failure or throttled.",
+		}, []string{"result"}),
+		accountsCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "accounts_created_total",
+			Help:      "Accounts opened, by currency.",
+		}, []string{"currency"}),
+		transfers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transfers_total",
+			Help:      "Transfers made, by currency.",
+		}, []string{"currency"}),
+		transferVolume: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transfer_volume_total",
+			Help:      "Amount transferred, by currency, in major units such as dollars.",
+		}, []string{"currency"}),
+	}
+
+	registry.MustRegister(
+		m.httpRequests,
+		m.queryDuration,
+		m.registrations,
+		m.logins,
+		m.accountsCreated,
+		m.transfers,
+		m.transferVolume,
+	)
+
+	return m
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveRequest records one served HTTP request. route is the route
+// pattern, not the path, so IDs in paths don't each make a new series.
+func (m *Metrics) ObserveRequest(method string, route string, status int, elapsed time.Duration) {
+	m.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Observe(elapsed.Seconds())
+}
+
+func (m *Metrics) Registered() {
+	m.registrations.Inc()
+}
+
+// Login counts a login attempt with one of the Login* results.
+func (m *Metrics) Login(result string) {
+	m.logins.WithLabelValues(result).Inc()
+}
+
+func (m *Metrics) AccountCreated(currency string) {
+	m.accountsCreated.WithLabelValues(currency).Inc()
+}
+
+func (m *Metrics) Transferred(amount utils.Money) {
+	// String gives the amount in major units, exact to the minor unit.
+	volume, err := strconv.ParseFloat(amount.String(), 64)
+	if err != nil {
+		return
+	}
+
+	m.transfers.WithLabelValues(amount.Currency).Inc()
+	m.transferVolume.WithLabelValues(amount.Currency).Add(volume)
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github/kasho/backend/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBusinessCounters(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+
+	m.Registered()
+	m.Login(LoginSuccess)
+	m.Login(LoginFailure)
+	m.Login(LoginFailure)
+	m.AccountCreated("USD")
+	m.Transferred(utils.NewMoney(1250, "USD"))
+	m.Transferred(utils.NewMoney(1, "USD"))
+	m.Transferred(utils.NewMoney(500, "ZAR"))
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.registrations))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.logins.WithLabelValues(LoginSuccess)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.logins.WithLabelValues(LoginFailure)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.accountsCreated.WithLabelValues("USD")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.transfers.WithLabelValues("USD")))
+	assert.InDelta(t, 12.51, testutil.ToFloat64(m.transferVolume.WithLabelValues("USD")), 1e-9)
+	assert.Equal(t, 5.0, testutil.ToFloat64(m.transferVolume.WithLabelValues("ZAR")))
+}
+
+func TestHandler(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+	m.ObserveRequest(http.MethodGet, "/accounts/:id", http.StatusOK, 20*time.Millisecond)
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `kasho_http_request_duration_seconds_count{method="GET",route="/accounts/:id",status="200"} 1`)
+}
+
+// fakeDBTX answers every query without a database.
+type fakeDBTX struct {
+	calls int
+}
+
+func (f *fakeDBTX) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	f.calls++
+	return nil, nil
+}
+
+func (f *fakeDBTX) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, nil
+}
+
+func (f *fakeDBTX) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	f.calls++
+	return nil, nil
+}
+
+func (f *fakeDBTX) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	f.calls++
+	return nil
+}
+
+func TestWrapDBTX(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+	fake := &fakeDBTX{}
+	conn := m.WrapDBTX(fake)
+
+	conn.ExecContext(context.Background(), "-- name: DeleteAccount :exec\nDELETE FROM accounts")
+	conn.QueryRowContext(context.Background(), "-- name: GetAccount :one\nSELECT 1")
+	conn.QueryRowContext(context.Background(), "-- name: GetAccount :one\nSELECT 1")
+
+	assert.Equal(t, 3, fake.calls)
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Contains(t, recorder.Body.String(), `kasho_db_query_duration_seconds_count{query="GetAccount"} 2`)
+	assert.Contains(t, recorder.Body.String(), `kasho_db_query_duration_seconds_count{query="DeleteAccount"} 1`)
+}
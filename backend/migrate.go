@@ -0,0 +1,201 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github/kasho/backend/db/migrations"
+	"github/kasho/backend/utils"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	_ "github.com/lib/pq"
+)
+
+const migrateUsage = `usage: kasho migrate [-test] <command>
+
+commands:
+  up [N]          apply all pending migrations, or the next N
+  down [N|all]    roll back the last migration, the last N, or all of them
+  status          print the current version; exits 1 if behind or dirty
+  force VERSION   record VERSION as applied and clear the dirty flag;
+                  -1 records that none are
+  create NAME     add empty up and down files to db/migrations`
+
+// migrationsDir is where create writes new migrations, relative to the
+// backend directory.
+const migrationsDir = "db/migrations"
+
+// migrateCommand runs the migrate subcommand against DB_SOURCE_LIVE, or
+// DB_SOURCE with -test, and returns the exit code.
+func migrateCommand(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprintln(os.Stderr, migrateUsage) }
+	test := flags.Bool("test", false, "migrate the test database (DB_SOURCE)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	args = flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	if args[0] == "create" {
+		if len(args) != 2 {
+			flags.Usage()
+			return 2
+		}
+
+		up, down, err := migrations.Create(migrationsDir, args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not create migration: %v\n", err)
+			return 1
+		}
+
+		fmt.Println(up)
+		fmt.Println(down)
+		return 0
+	}
+
+	config, err := utils.LoadConfig(".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not load config: %v\n", err)
+		return 2
+	}
+
+	source := config.DB_source_live
+	if *test {
+		source = config.DB_source
+	}
+
+	conn, err := sql.Open(config.DBdriver, source)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not connect to database: %v\n", err)
+		return 2
+	}
+
+	m, err := migrations.New(conn, slog.Default())
+	if err != nil {
+		conn.Close()
+		fmt.Fprintf(os.Stderr, "could not open migrations: %v\n", err)
+		return 2
+	}
+	defer m.Close()
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		return migrateResult(m, m.Up())
+
+	case args[0] == "up" && len(args) == 2:
+		n, ok := parseSteps(args[1])
+		if !ok {
+			return 2
+		}
+		return migrateResult(m, m.Steps(n))
+
+	// A bare down rolls back one migration, so a slip can't empty the
+	// database.
+	case args[0] == "down" && len(args) == 1:
+		return migrateResult(m, m.Steps(-1))
+
+	case args[0] == "down" && len(args) == 2 && args[1] == "all":
+		return migrateResult(m, m.Down())
+
+	case args[0] == "down" && len(args) == 2:
+		n, ok := parseSteps(args[1])
+		if !ok {
+			return 2
+		}
+		return migrateResult(m, m.Steps(-n))
+
+	case args[0] == "status" && len(args) == 1:
+		status, current, err := migrateStatus(m)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not read version: %v\n", err)
+			return 2
+		}
+		fmt.Println(status)
+		if !current {
+			return 1
+		}
+		return 0
+
+	case args[0] == "force" && len(args) == 2:
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < database.NilVersion {
+			fmt.Fprintf(os.Stderr, "invalid version %q\n", args[1])
+			return 2
+		}
+		return migrateResult(m, m.Force(version))
+	}
+
+	flags.Usage()
+	return 2
+}
+
+func parseSteps(arg string) (int, bool) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		fmt.Fprintf(os.Stderr, "invalid step count %q\n", arg)
+		return 0, false
+	}
+
+	return n, true
+}
+
+// migrateResult reports the outcome of a migration and the version it
+// left the database at.
+func migrateResult(m *migrate.Migrate, err error) int {
+	if errors.Is(err, migrate.ErrNoChange) {
+		fmt.Println("no change")
+		err = nil
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migration failed: %v\n", err)
+		return 1
+	}
+
+	status, _, err := migrateStatus(m)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not read version: %v\n", err)
+		return 1
+	}
+
+	fmt.Println(status)
+	return 0
+}
+
+// migrateStatus describes the version of the database and reports whether
+// it is the newest one built in and not dirty.
+func migrateStatus(m *migrate.Migrate) (string, bool, error) {
+	latest, err := migrations.Latest()
+	if err != nil {
+		return "", false, err
+	}
+
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return fmt.Sprintf("no migrations applied, %d pending", latest), false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	switch {
+	case dirty:
+		return fmt.Sprintf("version %d is dirty: a migration failed part way. Repair the schema, then force the last version that fully applied", version), false, nil
+	case version < latest:
+		return fmt.Sprintf("version %d of %d, %d pending", version, latest, latest-version), false, nil
+	case version > latest:
+		return fmt.Sprintf("version %d is newer than this build, which ends at %d", version, latest), false, nil
+	}
+
+	return fmt.Sprintf("version %d, up to date", version), true, nil
+}
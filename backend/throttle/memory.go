@@ -0,0 +1,60 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory keeps records in the process. Each instance counts on its own and
+// forgets everything on restart, so it suits development and tests.
+type Memory struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func NewMemory() *Memory {
+	return &Memory{records: map[string]Record{}}
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.records[key], nil
+}
+
+func (m *Memory) AddFailure(ctx context.Context, key string, now time.Time, window time.Duration) (Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record := m.records[key]
+	if record.LastFailure.Before(now.Add(-window)) {
+		record.Failures = 0
+	}
+
+	record.Failures++
+	record.LastFailure = now
+	m.records[key] = record
+
+	return record, nil
+}
+
+func (m *Memory) Lock(ctx context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record := m.records[key]
+	record.LockedUntil = until
+	m.records[key] = record
+
+	return nil
+}
+
+func (m *Memory) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.records, key)
+	return nil
+}
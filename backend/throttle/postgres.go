@@ -0,0 +1,59 @@
+package throttle
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	db "github/kasho/backend/db/sqlc"
+)
+
+// Postgres keeps records in the login_attempts table, shared by every
+// instance.
+type Postgres struct {
+	Queries *db.Queries
+}
+
+func (p Postgres) Get(ctx context.Context, key string) (Record, error) {
+	attempt, err := p.Queries.GetLoginAttempt(ctx, key)
+	if err == sql.ErrNoRows {
+		return Record{}, nil
+	}
+	if err != nil {
+		return Record{}, err
+	}
+
+	return record(attempt), nil
+}
+
+func (p Postgres) AddFailure(ctx context.Context, key string, now time.Time, window time.Duration) (Record, error) {
+	attempt, err := p.Queries.RecordLoginFailure(ctx, db.RecordLoginFailureParams{
+		Key:         key,
+		FailedAt:    now,
+		WindowStart: now.Add(-window),
+	})
+	if err != nil {
+		return Record{}, err
+	}
+
+	return record(attempt), nil
+}
+
+func (p Postgres) Lock(ctx context.Context, key string, until time.Time) error {
+	return p.Queries.LockLoginAttempt(ctx, db.LockLoginAttemptParams{
+		LockedUntil: sql.NullTime{Time: until, Valid: true},
+		Key:         key,
+	})
+}
+
+func (p Postgres) Reset(ctx context.Context, key string) error {
+	return p.Queries.DeleteLoginAttempt(ctx, key)
+}
+
+func record(attempt db.LoginAttempt) Record {
+	return Record{
+		Failures:    int(attempt.Failures),
+		LastFailure: attempt.LastFailedAt,
+		LockedUntil: attempt.LockedUntil.Time,
+	}
+}
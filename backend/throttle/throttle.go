@@ -0,0 +1,126 @@
+// Package throttle slows down and then locks out repeated failures, such as
+// wrong passwords, per key. Failures are kept in a Store so every instance
+// of the server sees the same counts.
+package throttle
+
+import (
+	"context"
+	"time"
+)
+
+// Record is what a Store knows about a key. The zero Record means no
+// failures.
+type Record struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+type Store interface {
+	Get(ctx context.Context, key string) (Record, error)
+	// AddFailure counts a failure at now. Failures before now-window are
+	// forgotten first, so the count restarts at one.
+	AddFailure(ctx context.Context, key string, now time.Time, window time.Duration) (Record, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+// Policy is how a Throttle treats failures.
+type Policy struct {
+	// FreeAttempts failures in a row go without delay. Each failure after
+	// them doubles the wait before the next attempt, from BaseDelay up to
+	// MaxDelay.
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	// MaxFailures failures within Window lock the key for LockoutDuration.
+	// Zero never locks.
+	MaxFailures     int
+	LockoutDuration time.Duration
+	Window          time.Duration
+}
+
+// Delay is how long to wait after the given number of failures in a row.
+func (p Policy) Delay(failures int) time.Duration {
+	extra := failures - p.FreeAttempts
+	if extra <= 0 || p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < extra && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+type Throttle struct {
+	Store  Store
+	Policy Policy
+	// Now is the clock, time.Now when nil.
+	Now func() time.Time
+}
+
+func New(store Store, policy Policy) *Throttle {
+	return &Throttle{Store: store, Policy: policy}
+}
+
+func (t *Throttle) now() time.Time {
+	if t.Now != nil {
+		return t.Now()
+	}
+	return time.Now()
+}
+
+// Wait returns how long key has to wait before its next attempt, zero when
+// it may try now. Attempts made while waiting should be refused without
+// being checked, so they don't count as failures either.
+func (t *Throttle) Wait(ctx context.Context, key string) (time.Duration, error) {
+	record, err := t.Store.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	now := t.now()
+
+	if now.Before(record.LockedUntil) {
+		return record.LockedUntil.Sub(now), nil
+	}
+
+	if record.Failures == 0 || now.Sub(record.LastFailure) > t.Policy.Window {
+		return 0, nil
+	}
+
+	next := record.LastFailure.Add(t.Policy.Delay(record.Failures))
+	if now.Before(next) {
+		return next.Sub(now), nil
+	}
+	return 0, nil
+}
+
+// Fail records a failed attempt. It reports the lockout it started, or the
+// zero time when it didn't lock the key.
+func (t *Throttle) Fail(ctx context.Context, key string) (time.Time, error) {
+	now := t.now()
+
+	record, err := t.Store.AddFailure(ctx, key, now, t.Policy.Window)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if t.Policy.MaxFailures == 0 || record.Failures < t.Policy.MaxFailures || now.Before(record.LockedUntil) {
+		return time.Time{}, nil
+	}
+
+	until := now.Add(t.Policy.LockoutDuration)
+	return until, t.Store.Lock(ctx, key, until)
+}
+
+// Reset forgets the failures of key, after a successful attempt.
+func (t *Throttle) Reset(ctx context.Context, key string) error {
+	return t.Store.Reset(ctx, key)
+}
@@ -0,0 +1,133 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time { return c.now }
+
+func (c *clock) advance(d time.Duration) { c.now = c.now.Add(d) }
+
+var policy = Policy{
+	FreeAttempts:    2,
+	BaseDelay:       time.Second,
+	MaxDelay:        4 * time.Second,
+	MaxFailures:     6,
+	LockoutDuration: 15 * time.Minute,
+	Window:          time.Hour,
+}
+
+func newThrottle() (*Throttle, *clock) {
+	c := &clock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	t := New(NewMemory(), policy)
+	t.Now = c.Now
+	return t, c
+}
+
+func TestPolicyDelay(t *testing.T) {
+	delays := []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}
+	for failures, want := range delays {
+		assert.Equal(t, want, policy.Delay(failures), "after %d failures", failures)
+	}
+}
+
+func TestThrottleBacksOff(t *testing.T) {
+	ctx := context.Background()
+	throttle, clock := newThrottle()
+
+	fail := func() {
+		until, err := throttle.Fail(ctx, "k")
+		require.NoError(t, err)
+		require.True(t, until.IsZero())
+	}
+	wait := func() time.Duration {
+		d, err := throttle.Wait(ctx, "k")
+		require.NoError(t, err)
+		return d
+	}
+
+	fail()
+	fail()
+	assert.Zero(t, wait())
+
+	fail()
+	assert.Equal(t, time.Second, wait())
+
+	clock.advance(time.Second)
+	assert.Zero(t, wait())
+
+	fail()
+	assert.Equal(t, 2*time.Second, wait())
+
+	// Other keys are unaffected.
+	d, err := throttle.Wait(ctx, "other")
+	require.NoError(t, err)
+	assert.Zero(t, d)
+
+	// A success starts over.
+	require.NoError(t, throttle.Reset(ctx, "k"))
+	assert.Zero(t, wait())
+}
+
+func TestThrottleLocksOut(t *testing.T) {
+	ctx := context.Background()
+	throttle, clock := newThrottle()
+
+	for i := 1; i < policy.MaxFailures; i++ {
+		until, err := throttle.Fail(ctx, "k")
+		require.NoError(t, err)
+		require.True(t, until.IsZero(), "failure %d", i)
+	}
+
+	until, err := throttle.Fail(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, clock.now.Add(policy.LockoutDuration), until)
+
+	d, err := throttle.Wait(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, policy.LockoutDuration, d)
+
+	// Failing while locked doesn't extend the lockout.
+	until, err = throttle.Fail(ctx, "k")
+	require.NoError(t, err)
+	assert.True(t, until.IsZero())
+
+	clock.advance(policy.LockoutDuration)
+	d, err = throttle.Wait(ctx, "k")
+	require.NoError(t, err)
+	assert.Zero(t, d)
+
+	// The failures are still counted, so the next one locks again.
+	until, err = throttle.Fail(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, clock.now.Add(policy.LockoutDuration), until)
+}
+
+func TestThrottleForgetsOldFailures(t *testing.T) {
+	ctx := context.Background()
+	throttle, clock := newThrottle()
+
+	for i := 0; i < policy.MaxFailures-1; i++ {
+		_, err := throttle.Fail(ctx, "k")
+		require.NoError(t, err)
+	}
+
+	clock.advance(policy.Window + time.Second)
+
+	d, err := throttle.Wait(ctx, "k")
+	require.NoError(t, err)
+	assert.Zero(t, d)
+
+	until, err := throttle.Fail(ctx, "k")
+	require.NoError(t, err)
+	assert.True(t, until.IsZero())
+}
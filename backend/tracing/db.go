@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	db "github/kasho/backend/db/sqlc"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WrapDBTX puts a span around every query run through next. Pass it to
+// db.NewStore so queries inside transactions are traced too.
+//
+// Queries are only traced as part of a trace that is already going, such
+// as a request's. A background job's queries would otherwise each start a
+// trace of their own.
+func WrapDBTX(next db.DBTX) db.DBTX {
+	return tracedDBTX{next: next}
+}
+
+type tracedDBTX struct {
+	next db.DBTX
+}
+
+func (t tracedDBTX) start(ctx context.Context, query string) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+
+	name := db.QueryName(query)
+
+	return otel.Tracer("github/kasho/backend/db").Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "postgresql"),
+			attribute.String("db.operation.name", name),
+			attribute.String("db.query.text", query),
+		),
+	)
+}
+
+func end(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (t tracedDBTX) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := t.start(ctx, query)
+	result, err := t.next.ExecContext(ctx, query, args...)
+	end(span, err)
+	return result, err
+}
+
+func (t tracedDBTX) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return t.next.PrepareContext(ctx, query)
+}
+
+func (t tracedDBTX) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := t.start(ctx, query)
+	rows, err := t.next.QueryContext(ctx, query, args...)
+	end(span, err)
+	return rows, err
+}
+
+// QueryRowContext ends its span once the row is available. Its error only
+// shows at Scan, so a failed query isn't marked on the span.
+func (t tracedDBTX) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := t.start(ctx, query)
+	row := t.next.QueryRowContext(ctx, query, args...)
+	end(span, nil)
+	return row
+}
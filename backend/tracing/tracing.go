@@ -0,0 +1,97 @@
+// Package tracing sets up OpenTelemetry tracing. Spans are created with the
+// global tracer provider, so packages only need otel.Tracer; Setup decides
+// where the spans go.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+)
+
+const ServiceName = "kasho"
+
+// Options says where spans are exported and how many are kept.
+type Options struct {
+	// Exporter is "otlp", "stdout" or "none". "auto" picks otlp when
+	// OTLPEndpoint is set and stdout otherwise.
+	Exporter string
+	// OTLPEndpoint is the collector's OTLP/HTTP URL, such as
+	// http://localhost:4318.
+	OTLPEndpoint string
+	// SampleRatio is the share of new traces recorded, from 0 to 1. Traces
+	// started by a caller follow the caller's decision.
+	SampleRatio float64
+	// Stdout receives spans for the stdout exporter.
+	Stdout io.Writer
+}
+
+// Setup installs the global tracer provider and the W3C trace context and
+// baggage propagators. The returned function flushes buffered spans and
+// must be called before the process exits.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporter, err := newExporter(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if exporter == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults.
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(ServiceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, opts Options) (sdktrace.SpanExporter, error) {
+	exporter := opts.Exporter
+	if exporter == "auto" {
+		exporter = "stdout"
+		if opts.OTLPEndpoint != "" {
+			exporter = "otlp"
+		}
+	}
+
+	switch exporter {
+	case "otlp":
+		var options []otlptracehttp.Option
+		if opts.OTLPEndpoint != "" {
+			options = append(options, otlptracehttp.WithEndpointURL(opts.OTLPEndpoint))
+		}
+		return otlptracehttp.New(ctx, options...)
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(opts.Stdout))
+	case "none":
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unknown TRACE_EXPORTER %q", opts.Exporter)
+}
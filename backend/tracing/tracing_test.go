@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestNewExporter(t *testing.T) {
+	exporter, err := newExporter(context.Background(), Options{Exporter: "auto", Stdout: &bytes.Buffer{}})
+	require.NoError(t, err)
+	assert.IsType(t, &stdouttrace.Exporter{}, exporter)
+
+	exporter, err = newExporter(context.Background(), Options{Exporter: "none"})
+	require.NoError(t, err)
+	assert.Nil(t, exporter)
+
+	_, err = newExporter(context.Background(), Options{Exporter: "jaeger"})
+	assert.ErrorContains(t, err, `unknown TRACE_EXPORTER "jaeger"`)
+}
+
+// fakeDBTX answers every query without a database.
+type fakeDBTX struct {
+	err error
+}
+
+func (f fakeDBTX) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, f.err
+}
+
+func (f fakeDBTX) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, nil
+}
+
+func (f fakeDBTX) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, f.err
+}
+
+func (f fakeDBTX) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return nil
+}
+
+func TestWrapDBTX(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+
+	// Without a trace going on, queries make no spans.
+	WrapDBTX(fakeDBTX{}).ExecContext(context.Background(), "-- name: DeleteAccount :exec\nDELETE FROM accounts")
+	assert.Empty(t, recorder.Ended())
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+	WrapDBTX(fakeDBTX{}).QueryRowContext(ctx, "-- name: GetAccount :one\nSELECT 1")
+	WrapDBTX(fakeDBTX{err: errors.New("boom")}).ExecContext(ctx, "-- name: DeleteAccount :exec\nDELETE FROM accounts")
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	assert.Equal(t, "GetAccount", spans[0].Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+
+	assert.Equal(t, "DeleteAccount", spans[1].Name())
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+}
@@ -1,12 +1,118 @@
 package utils
 
-import "github.com/spf13/viper"
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
 
 type Config struct {
 	DBdriver string `mapstructure:"DB_DRIVER"`
 	DB_source string `mapstructure:"DB_SOURCE"`
 	DB_source_live string `mapstructure:"DB_SOURCE_LIVE"`
 	Signing_key string `mapstructure:"SIGNING_KEY"`
+	// Directory of <kid>.pem RSA or Ed25519 keys. When set, access tokens are
+	// signed asymmetrically with Jwt_active_kid instead of Signing_key.
+	Jwt_keys_dir string `mapstructure:"JWT_KEYS_DIR"`
+	Jwt_active_kid string `mapstructure:"JWT_ACTIVE_KID"`
+	// Keep accepting HS256 tokens without a kid, signed with Signing_key,
+	// next to Jwt_active_kid until Jwt_legacy_hs256_cutoff (RFC 3339). The
+	// cutoff can be at most Access_token_ttl after the server starts.
+	Jwt_accept_legacy_hs256 bool `mapstructure:"JWT_ACCEPT_LEGACY_HS256"`
+	Jwt_legacy_hs256_cutoff string `mapstructure:"JWT_LEGACY_HS256_CUTOFF"`
+	Access_token_ttl time.Duration `mapstructure:"ACCESS_TOKEN_TTL"`
+	// Refresh tokens slide: each rotation issues a token valid for this long.
+	Refresh_token_ttl time.Duration `mapstructure:"REFRESH_TOKEN_TTL"`
+	// One of debug, info, warn, error.
+	Log_level string `mapstructure:"LOG_LEVEL"`
+	// json or text.
+	Log_format string `mapstructure:"LOG_FORMAT"`
+	// How long a stored Idempotency-Key response is replayed for.
+	Idempotency_key_ttl time.Duration `mapstructure:"IDEMPOTENCY_KEY_TTL"`
+	// How often expired idempotency keys are purged.
+	Idempotency_purge_interval time.Duration `mapstructure:"IDEMPOTENCY_PURGE_INTERVAL"`
+	// Which funding provider backs deposits and withdrawals. Only "fake" ships.
+	Funding_provider string `mapstructure:"FUNDING_PROVIDER"`
+	// How long the fake provider keeps requests pending before settling them.
+	Funding_fake_settle_after time.Duration `mapstructure:"FUNDING_FAKE_SETTLE_AFTER"`
+	// How often pending funding requests are checked with the provider.
+	Funding_poll_interval time.Duration `mapstructure:"FUNDING_POLL_INTERVAL"`
+	// JSON file of "BASE/QUOTE": "rate" pairs. Empty uses the bundled
+	// development rates.
+	Fx_rates_file string `mapstructure:"FX_RATES_FILE"`
+	// Taken off the mid rate, in basis points.
+	Fx_spread_bps int64 `mapstructure:"FX_SPREAD_BPS"`
+	// Taken off the amount sold, in basis points.
+	Fx_fee_bps int64 `mapstructure:"FX_FEE_BPS"`
+	// How long an FX quote can be executed for.
+	Fx_quote_ttl time.Duration `mapstructure:"FX_QUOTE_TTL"`
+	// JSON array of currencies written to the currencies table at startup.
+	// Empty leaves the table to the admin API.
+	Currencies_file string `mapstructure:"CURRENCIES_FILE"`
+	// How often the currency registry is reloaded, to pick up changes made
+	// through another instance.
+	Currencies_refresh_interval time.Duration `mapstructure:"CURRENCIES_REFRESH_INTERVAL"`
+	// Where links in emails point, e.g. the frontend's origin.
+	App_url string `mapstructure:"APP_URL"`
+	// How emails are sent: "file" writes .eml files to Mail_dir, "log"
+	// writes them to the log with the tokens in their links masked.
+	Mailer string `mapstructure:"MAILER"`
+	Mail_dir string `mapstructure:"MAIL_DIR"`
+	Mail_from string `mapstructure:"MAIL_FROM"`
+	// How long a password reset link works for.
+	Password_reset_ttl time.Duration `mapstructure:"PASSWORD_RESET_TTL"`
+	// How long an email verification link works for.
+	Email_verification_ttl time.Duration `mapstructure:"EMAIL_VERIFICATION_TTL"`
+	// 32 bytes in hex that seal TOTP secrets in the database. Changing it
+	// makes every enrolled authenticator unusable.
+	Totp_encryption_key string `mapstructure:"TOTP_ENCRYPTION_KEY"`
+	// How long a user has to enter their code after their password.
+	Login_challenge_ttl time.Duration `mapstructure:"LOGIN_CHALLENGE_TTL"`
+	// How long a two-factor step-up counts as recent.
+	Step_up_ttl time.Duration `mapstructure:"STEP_UP_TTL"`
+	// Where failed logins are counted: "postgres", shared by every
+	// instance, or "memory".
+	Login_throttle_store string `mapstructure:"LOGIN_THROTTLE_STORE"`
+	// Wrong passwords for one email that go without delay. Each one after
+	// doubles the wait, from Login_backoff_base up to Login_backoff_max.
+	Login_free_attempts int `mapstructure:"LOGIN_FREE_ATTEMPTS"`
+	Login_backoff_base time.Duration `mapstructure:"LOGIN_BACKOFF_BASE"`
+	Login_backoff_max time.Duration `mapstructure:"LOGIN_BACKOFF_MAX"`
+	// Wrong passwords for one email, or from one IP, within
+	// Login_failure_window that lock it out for Login_lockout_duration.
+	Login_max_failures int `mapstructure:"LOGIN_MAX_FAILURES"`
+	Login_ip_max_failures int `mapstructure:"LOGIN_IP_MAX_FAILURES"`
+	Login_lockout_duration time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION"`
+	Login_failure_window time.Duration `mapstructure:"LOGIN_FAILURE_WINDOW"`
+	// Comma-separated IPs or CIDRs of the proxies whose X-Forwarded-For
+	// gives the client IP. Empty trusts none and uses the connection's
+	// address.
+	Trusted_proxies []string `mapstructure:"TRUSTED_PROXIES"`
+	// Limits on reading a whole request, writing a response, and keeping an
+	// idle keep-alive connection open.
+	Http_read_timeout time.Duration `mapstructure:"HTTP_READ_TIMEOUT"`
+	Http_write_timeout time.Duration `mapstructure:"HTTP_WRITE_TIMEOUT"`
+	Http_idle_timeout time.Duration `mapstructure:"HTTP_IDLE_TIMEOUT"`
+	// How long in-flight requests get to finish after SIGTERM.
+	Shutdown_timeout time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+	// Bearer token Prometheus must send to scrape /metrics. Empty leaves
+	// the endpoint open, for when it is only reachable internally.
+	Metrics_token string `mapstructure:"METRICS_TOKEN"`
+	// Where spans go: "otlp", "stdout", "none", or "auto" for otlp when an
+	// OTLP endpoint is set and stdout otherwise.
+	Trace_exporter string `mapstructure:"TRACE_EXPORTER"`
+	// OTLP/HTTP collector URL, e.g. http://localhost:4318.
+	Otel_exporter_otlp_endpoint string `mapstructure:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	// Share of new traces recorded, from 0 to 1.
+	Trace_sample_ratio float64 `mapstructure:"TRACE_SAMPLE_RATIO"`
+	// How long a request may run before its queries are cancelled.
+	Request_timeout time.Duration `mapstructure:"REQUEST_TIMEOUT"`
+	// Postgres cancels any statement running longer, whatever the caller's
+	// context says.
+	Db_statement_timeout time.Duration `mapstructure:"DB_STATEMENT_TIMEOUT"`
+	// Apply pending migrations to DB_SOURCE_LIVE when the server starts.
+	Auto_migrate bool `mapstructure:"AUTO_MIGRATE"`
 }
 
 func LoadConfig(path string) (config *Config, err error) {
@@ -14,6 +120,55 @@ func LoadConfig(path string) (config *Config, err error) {
 	viper.SetConfigName("env")
 	viper.SetConfigType("env")
 
+	viper.SetDefault("LOG_LEVEL", "info")
+	viper.SetDefault("LOG_FORMAT", "json")
+	viper.SetDefault("JWT_KEYS_DIR", "")
+	viper.SetDefault("JWT_ACTIVE_KID", "")
+	viper.SetDefault("JWT_ACCEPT_LEGACY_HS256", false)
+	viper.SetDefault("JWT_LEGACY_HS256_CUTOFF", "")
+	viper.SetDefault("ACCESS_TOKEN_TTL", "30m")
+	viper.SetDefault("REFRESH_TOKEN_TTL", "720h")
+	viper.SetDefault("IDEMPOTENCY_KEY_TTL", "24h")
+	viper.SetDefault("IDEMPOTENCY_PURGE_INTERVAL", "1h")
+	viper.SetDefault("FUNDING_PROVIDER", "fake")
+	viper.SetDefault("FUNDING_FAKE_SETTLE_AFTER", "10s")
+	viper.SetDefault("FUNDING_POLL_INTERVAL", "5s")
+	viper.SetDefault("FX_RATES_FILE", "")
+	viper.SetDefault("FX_SPREAD_BPS", 50)
+	viper.SetDefault("FX_FEE_BPS", 0)
+	viper.SetDefault("FX_QUOTE_TTL", "30s")
+	viper.SetDefault("CURRENCIES_FILE", "")
+	viper.SetDefault("CURRENCIES_REFRESH_INTERVAL", "1m")
+	viper.SetDefault("APP_URL", "http://localhost:3000")
+	viper.SetDefault("MAILER", "file")
+	viper.SetDefault("MAIL_DIR", "tmp/mail")
+	viper.SetDefault("MAIL_FROM", "Kasho <no-reply@kasho.local>")
+	viper.SetDefault("PASSWORD_RESET_TTL", "1h")
+	viper.SetDefault("EMAIL_VERIFICATION_TTL", "24h")
+	viper.SetDefault("TOTP_ENCRYPTION_KEY", "")
+	viper.SetDefault("LOGIN_CHALLENGE_TTL", "5m")
+	viper.SetDefault("STEP_UP_TTL", "5m")
+	viper.SetDefault("LOGIN_THROTTLE_STORE", "postgres")
+	viper.SetDefault("LOGIN_FREE_ATTEMPTS", 3)
+	viper.SetDefault("LOGIN_BACKOFF_BASE", "1s")
+	viper.SetDefault("LOGIN_BACKOFF_MAX", "1m")
+	viper.SetDefault("LOGIN_MAX_FAILURES", 10)
+	viper.SetDefault("LOGIN_IP_MAX_FAILURES", 100)
+	viper.SetDefault("LOGIN_LOCKOUT_DURATION", "15m")
+	viper.SetDefault("LOGIN_FAILURE_WINDOW", "1h")
+	viper.SetDefault("TRUSTED_PROXIES", "")
+	viper.SetDefault("HTTP_READ_TIMEOUT", "15s")
+	viper.SetDefault("HTTP_WRITE_TIMEOUT", "30s")
+	viper.SetDefault("HTTP_IDLE_TIMEOUT", "2m")
+	viper.SetDefault("SHUTDOWN_TIMEOUT", "20s")
+	viper.SetDefault("METRICS_TOKEN", "")
+	viper.SetDefault("TRACE_EXPORTER", "auto")
+	viper.SetDefault("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	viper.SetDefault("TRACE_SAMPLE_RATIO", 1)
+	viper.SetDefault("REQUEST_TIMEOUT", "10s")
+	viper.SetDefault("DB_STATEMENT_TIMEOUT", "5s")
+	viper.SetDefault("AUTO_MIGRATE", false)
+
 	viper.AutomaticEnv()
 
 	err = viper.ReadInConfig()
@@ -26,5 +181,17 @@ func LoadConfig(path string) (config *Config, err error) {
 		return nil, err
 	}
 
+	// These drive the server's periodic jobs, which need a positive interval.
+	for key, interval := range map[string]time.Duration{
+		"IDEMPOTENCY_PURGE_INTERVAL":  config.Idempotency_purge_interval,
+		"LOGIN_FAILURE_WINDOW":        config.Login_failure_window,
+		"FUNDING_POLL_INTERVAL":       config.Funding_poll_interval,
+		"CURRENCIES_REFRESH_INTERVAL": config.Currencies_refresh_interval,
+	} {
+		if interval <= 0 {
+			return nil, fmt.Errorf("%s must be positive, got %s", key, interval)
+		}
+	}
+
 	return config, nil
 }
\ No newline at end of file
@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigRejectsZeroJobInterval(t *testing.T) {
+	config, err := LoadConfig("..")
+	require.NoError(t, err)
+	assert.Positive(t, config.Funding_poll_interval)
+
+	t.Setenv("FUNDING_POLL_INTERVAL", "0s")
+
+	_, err = LoadConfig("..")
+	assert.ErrorContains(t, err, "FUNDING_POLL_INTERVAL")
+}
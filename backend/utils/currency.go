@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+var ErrAmountLimit = errors.New("amount is outside the limits of the currency")
+
+// Currency is one ISO 4217 currency as known to Kasho.
+type Currency struct {
+	Code string `json:"code"`
+	// Numeric is the ISO 4217 numeric code, e.g. "840" for USD.
+	Numeric string `json:"numeric_code"`
+	Name    string `json:"name"`
+	// Exponent is the number of minor-unit digits, e.g. 2 for cents.
+	Exponent int    `json:"exponent"`
+	Symbol   string `json:"symbol"`
+	// Only enabled currencies can be used for new accounts and movements.
+	Enabled bool `json:"enabled"`
+	// MinAmount and MaxAmount bound a single movement, in minor units. Zero
+	// means no bound.
+	MinAmount Amount `json:"min_amount"`
+	MaxAmount Amount `json:"max_amount"`
+	// Movements of at least StepUpAmount need a recent two-factor check.
+	// Zero means never.
+	StepUpAmount Amount `json:"step_up_amount"`
+}
+
+// CurrencyRegistry holds the currencies the process knows about. It is
+// safe for concurrent use and can be reloaded while serving.
+type CurrencyRegistry struct {
+	mu         sync.RWMutex
+	currencies map[string]Currency
+}
+
+func NewCurrencyRegistry(currencies []Currency) *CurrencyRegistry {
+	r := &CurrencyRegistry{}
+	r.Replace(currencies)
+	return r
+}
+
+// Currencies is the registry that validation, parsing and formatting read.
+// It starts with the currencies Kasho launched with and is replaced with the
+// currencies table when the server starts.
+var Currencies = NewCurrencyRegistry([]Currency{
+	{Code: "NGN", Numeric: "566", Name: "Naira", Exponent: 2, Symbol: "₦", Enabled: true},
+	{Code: "USD", Numeric: "840", Name: "US Dollar", Exponent: 2, Symbol: "$", Enabled: true},
+	{Code: "ZAR", Numeric: "710", Name: "Rand", Exponent: 2, Symbol: "R", Enabled: true},
+})
+
+func (r *CurrencyRegistry) Lookup(code string) (Currency, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.currencies[code]
+	return c, ok
+}
+
+// Replace swaps the whole registry for currencies.
+func (r *CurrencyRegistry) Replace(currencies []Currency) {
+	m := make(map[string]Currency, len(currencies))
+	for _, c := range currencies {
+		m[c.Code] = c
+	}
+
+	r.mu.Lock()
+	r.currencies = m
+	r.mu.Unlock()
+}
+
+// Set adds or updates a single currency.
+func (r *CurrencyRegistry) Set(c Currency) {
+	r.mu.Lock()
+	r.currencies[c.Code] = c
+	r.mu.Unlock()
+}
+
+// List returns every currency ordered by code.
+func (r *CurrencyRegistry) List() []Currency {
+	r.mu.RLock()
+	list := make([]Currency, 0, len(r.currencies))
+	for _, c := range r.currencies {
+		list = append(list, c)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+// IsSupportedCurrency reports whether currency is known and enabled.
+func IsSupportedCurrency(currency string) bool {
+	c, ok := Currencies.Lookup(currency)
+	return ok && c.Enabled
+}
+
+// CurrencyExponent knows disabled currencies too, so balances held in a
+// currency that was switched off can still be read and formatted.
+func CurrencyExponent(currency string) (int, bool) {
+	c, ok := Currencies.Lookup(currency)
+	return c.Exponent, ok
+}
+
+// CheckLimits reports whether m may be moved in a single operation.
+func (c Currency) CheckLimits(m Money) error {
+	if m.Currency != c.Code {
+		return ErrMoneyCurrency
+	}
+	if c.MinAmount > 0 && m.Amount < c.MinAmount {
+		return fmt.Errorf("%w: minimum is %s", ErrAmountLimit, NewMoney(c.MinAmount, c.Code))
+	}
+	if c.MaxAmount > 0 && m.Amount > c.MaxAmount {
+		return fmt.Errorf("%w: maximum is %s", ErrAmountLimit, NewMoney(c.MaxAmount, c.Code))
+	}
+	return nil
+}
+
+// CheckAmountLimits checks m against the limits of its currency.
+func CheckAmountLimits(m Money) error {
+	c, ok := Currencies.Lookup(m.Currency)
+	if !ok {
+		return ErrUnsupportedCurrency
+	}
+	return c.CheckLimits(m)
+}
+
+// LoadCurrencyFile reads a JSON array of currencies, for deployments that
+// manage currencies in config rather than through the admin API.
+func LoadCurrencyFile(path string) ([]Currency, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var currencies []Currency
+	if err := json.Unmarshal(data, &currencies); err != nil {
+		return nil, fmt.Errorf("currencies %s: %w", path, err)
+	}
+
+	for _, c := range currencies {
+		if len(c.Code) != 3 || c.Exponent < 0 || c.Exponent > 4 {
+			return nil, fmt.Errorf("currencies %s: invalid currency %q", path, c.Code)
+		}
+	}
+
+	return currencies, nil
+}
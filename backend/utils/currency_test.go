@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCurrencyRegistry(t *testing.T) {
+	registry := NewCurrencyRegistry([]Currency{
+		{Code: "USD", Exponent: 2, Enabled: true},
+		{Code: "JPY", Exponent: 0},
+	})
+
+	usd, ok := registry.Lookup("USD")
+	require.True(t, ok)
+	assert.Equal(t, 2, usd.Exponent)
+
+	_, ok = registry.Lookup("EUR")
+	assert.False(t, ok)
+
+	registry.Set(Currency{Code: "EUR", Exponent: 2, Enabled: true})
+	registry.Set(Currency{Code: "JPY", Exponent: 0, Enabled: true})
+
+	list := registry.List()
+	require.Len(t, list, 3)
+	assert.Equal(t, []string{"EUR", "JPY", "USD"}, []string{list[0].Code, list[1].Code, list[2].Code})
+	assert.True(t, list[1].Enabled)
+
+	registry.Replace([]Currency{{Code: "KWD", Exponent: 3}})
+	_, ok = registry.Lookup("USD")
+	assert.False(t, ok)
+	assert.Len(t, registry.List(), 1)
+}
+
+func TestDisabledCurrency(t *testing.T) {
+	usd, _ := Currencies.Lookup("USD")
+	t.Cleanup(func() { Currencies.Set(usd) })
+
+	disabled := usd
+	disabled.Enabled = false
+	Currencies.Set(disabled)
+
+	// Disabled currencies cannot be used, but their amounts still format.
+	assert.False(t, IsSupportedCurrency("USD"))
+	assert.Equal(t, "12.50", NewMoney(1250, "USD").String())
+
+	exponent, ok := CurrencyExponent("USD")
+	assert.True(t, ok)
+	assert.Equal(t, 2, exponent)
+}
+
+func TestCheckLimits(t *testing.T) {
+	currency := Currency{Code: "USD", Exponent: 2, MinAmount: 100, MaxAmount: 100000}
+
+	assert.NoError(t, currency.CheckLimits(NewMoney(100, "USD")))
+	assert.NoError(t, currency.CheckLimits(NewMoney(100000, "USD")))
+	assert.ErrorIs(t, currency.CheckLimits(NewMoney(99, "USD")), ErrAmountLimit)
+	assert.ErrorIs(t, currency.CheckLimits(NewMoney(100001, "USD")), ErrAmountLimit)
+	assert.ErrorIs(t, currency.CheckLimits(NewMoney(500, "NGN")), ErrMoneyCurrency)
+
+	unbounded := Currency{Code: "USD", Exponent: 2}
+	assert.NoError(t, unbounded.CheckLimits(NewMoney(1, "USD")))
+
+	assert.ErrorIs(t, CheckAmountLimits(NewMoney(1, "XXX")), ErrUnsupportedCurrency)
+}
+
+func TestMoneyDisplay(t *testing.T) {
+	assert.Equal(t, "$12.50", NewMoney(1250, "USD").Display())
+	assert.Equal(t, "-₦0.05", NewMoney(-5, "NGN").Display())
+	assert.Equal(t, "XXX 12", NewMoney(12, "XXX").Display())
+}
+
+func TestLoadCurrencyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "currencies.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"code": "KES", "numeric_code": "404", "name": "Kenyan Shilling", "exponent": 2, "symbol": "KSh", "enabled": true, "max_amount": 10000000}]`), 0o600))
+
+	currencies, err := LoadCurrencyFile(path)
+	require.NoError(t, err)
+	require.Len(t, currencies, 1)
+	assert.Equal(t, "KES", currencies[0].Code)
+	assert.Equal(t, Amount(10000000), currencies[0].MaxAmount)
+
+	require.NoError(t, os.WriteFile(path, []byte(`[{"code": "KES", "exponent": 9}]`), 0o600))
+	_, err = LoadCurrencyFile(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithStatementTimeout adds a statement_timeout to a lib/pq connection
+// string, in URL or key=value form. lib/pq passes settings it doesn't know
+// to Postgres, which then applies the timeout to every statement on the
+// connection. A zero timeout leaves source as it is.
+func WithStatementTimeout(source string, timeout time.Duration) (string, error) {
+	if timeout <= 0 {
+		return source, nil
+	}
+
+	ms := strconv.FormatInt(timeout.Milliseconds(), 10)
+
+	if strings.HasPrefix(source, "postgres://") || strings.HasPrefix(source, "postgresql://") {
+		u, err := url.Parse(source)
+		if err != nil {
+			return "", err
+		}
+
+		query := u.Query()
+		query.Set("statement_timeout", ms)
+		u.RawQuery = query.Encode()
+
+		return u.String(), nil
+	}
+
+	return strings.TrimSpace(source) + " statement_timeout=" + ms, nil
+}
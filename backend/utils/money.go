@@ -0,0 +1,228 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrAmountOverflow      = errors.New("amount overflows int64 minor units")
+	ErrInvalidAmount       = errors.New("invalid amount")
+	ErrMoneyCurrency       = errors.New("money currencies do not match")
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+)
+
+// Amount is a quantity of money in the minor unit of its currency (cents for
+// USD, kobo for NGN). It is what the balance and amount columns are stored as;
+// the currency lives on the owning account.
+type Amount int64
+
+// Add returns a + b, failing instead of wrapping around on overflow.
+func (a Amount) Add(b Amount) (Amount, error) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, ErrAmountOverflow
+	}
+	return a + b, nil
+}
+
+// Sub returns a - b, failing instead of wrapping around on overflow.
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if (b < 0 && a > math.MaxInt64+b) || (b > 0 && a < math.MinInt64+b) {
+		return 0, ErrAmountOverflow
+	}
+	return a - b, nil
+}
+
+// Neg returns -a. The smallest int64 has no positive counterpart.
+func (a Amount) Neg() (Amount, error) {
+	if a == math.MinInt64 {
+		return 0, ErrAmountOverflow
+	}
+	return -a, nil
+}
+
+// Money is an exact amount of a single currency.
+type Money struct {
+	Amount   Amount
+	Currency string
+}
+
+func NewMoney(amount Amount, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney reads a decimal string such as "12.50" into minor units using
+// the exponent of the currency. More fractional digits than the currency
+// supports is an error rather than a silent rounding.
+func ParseMoney(value string, currency string) (Money, error) {
+	exponent, ok := CurrencyExponent(currency)
+	if !ok {
+		return Money{}, ErrUnsupportedCurrency
+	}
+
+	s := value
+	negative := false
+	if strings.HasPrefix(s, "-") {
+		negative = true
+		s = s[1:]
+	}
+
+	whole, frac, hasPoint := strings.Cut(s, ".")
+	if whole == "" || (hasPoint && frac == "") || len(frac) > exponent {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+	}
+
+	digits := whole + frac + strings.Repeat("0", exponent-len(frac))
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+		}
+	}
+
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return Money{}, ErrAmountOverflow
+		}
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+	}
+
+	if negative {
+		n = -n
+	}
+
+	return NewMoney(Amount(n), currency), nil
+}
+
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, ErrMoneyCurrency
+	}
+	sum, err := m.Amount.Add(o.Amount)
+	if err != nil {
+		return Money{}, err
+	}
+	return NewMoney(sum, m.Currency), nil
+}
+
+func (m Money) Sub(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, ErrMoneyCurrency
+	}
+	diff, err := m.Amount.Sub(o.Amount)
+	if err != nil {
+		return Money{}, err
+	}
+	return NewMoney(diff, m.Currency), nil
+}
+
+func (m Money) Neg() (Money, error) {
+	neg, err := m.Amount.Neg()
+	if err != nil {
+		return Money{}, err
+	}
+	return NewMoney(neg, m.Currency), nil
+}
+
+// Cmp returns -1, 0 or +1 depending on whether m is less than, equal to or
+// greater than o.
+func (m Money) Cmp(o Money) (int, error) {
+	if m.Currency != o.Currency {
+		return 0, ErrMoneyCurrency
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// String formats the amount as a plain decimal string, e.g. "-12.50".
+func (m Money) String() string {
+	exponent, ok := CurrencyExponent(m.Currency)
+	if !ok {
+		exponent = 0
+	}
+
+	// Work on the magnitude as uint64 so that math.MinInt64 formats correctly.
+	magnitude := uint64(m.Amount)
+	sign := ""
+	if m.Amount < 0 {
+		magnitude = -magnitude
+		sign = "-"
+	}
+
+	digits := strconv.FormatUint(magnitude, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+
+	point := len(digits) - exponent
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// Display formats m for people, with the symbol of its currency, e.g.
+// "-$12.50". Unknown currencies fall back to the code.
+func (m Money) Display() string {
+	symbol := m.Currency + " "
+	if c, ok := Currencies.Lookup(m.Currency); ok && c.Symbol != "" {
+		symbol = c.Symbol
+	}
+
+	s := m.String()
+	if m.Amount < 0 {
+		return "-" + symbol + s[1:]
+	}
+	return symbol + s
+}
+
+type moneyJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON emits the amount as a decimal string so that clients never have
+// to round-trip money through a float.
+func (m Money) MarshalJSON() ([]byte, error) {
+	if _, ok := CurrencyExponent(m.Currency); !ok {
+		return nil, ErrUnsupportedCurrency
+	}
+	return json.Marshal(moneyJSON{Amount: m.String(), Currency: m.Currency})
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v moneyJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	parsed, err := ParseMoney(v.Amount, v.Currency)
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input    string
+		currency string
+		want     Amount
+		err      error
+	}{
+		{"12.34", "USD", 1234, nil},
+		{"12.3", "USD", 1230, nil},
+		{"12", "USD", 1200, nil},
+		{"0.05", "NGN", 5, nil},
+		{"-7.50", "ZAR", -750, nil},
+		{"12.345", "USD", 0, ErrInvalidAmount},
+		{"12.", "USD", 0, ErrInvalidAmount},
+		{".5", "USD", 0, ErrInvalidAmount},
+		{"1e3", "USD", 0, ErrInvalidAmount},
+		{"", "USD", 0, ErrInvalidAmount},
+		{"92233720368547758.08", "USD", 0, ErrAmountOverflow},
+		{"1.00", "XXX", 0, ErrUnsupportedCurrency},
+	}
+
+	for _, tc := range tests {
+		got, err := ParseMoney(tc.input, tc.currency)
+		if tc.err != nil {
+			assert.ErrorIs(t, err, tc.err, tc.input)
+			continue
+		}
+		assert.NoError(t, err, tc.input)
+		assert.Equal(t, tc.want, got.Amount, tc.input)
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	assert.Equal(t, "12.34", NewMoney(1234, "USD").String())
+	assert.Equal(t, "0.05", NewMoney(5, "USD").String())
+	assert.Equal(t, "-0.05", NewMoney(-5, "USD").String())
+	assert.Equal(t, "0.00", NewMoney(0, "USD").String())
+	assert.Equal(t, "-92233720368547758.08", NewMoney(math.MinInt64, "USD").String())
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	a := NewMoney(1000, "USD")
+	b := NewMoney(250, "USD")
+
+	sum, err := a.Add(b)
+	assert.NoError(t, err)
+	assert.Equal(t, Amount(1250), sum.Amount)
+
+	diff, err := b.Sub(a)
+	assert.NoError(t, err)
+	assert.Equal(t, Amount(-750), diff.Amount)
+
+	_, err = a.Add(NewMoney(1, "NGN"))
+	assert.ErrorIs(t, err, ErrMoneyCurrency)
+
+	_, err = NewMoney(math.MaxInt64, "USD").Add(NewMoney(1, "USD"))
+	assert.ErrorIs(t, err, ErrAmountOverflow)
+
+	_, err = NewMoney(math.MinInt64, "USD").Sub(NewMoney(1, "USD"))
+	assert.ErrorIs(t, err, ErrAmountOverflow)
+
+	_, err = NewMoney(math.MinInt64, "USD").Neg()
+	assert.ErrorIs(t, err, ErrAmountOverflow)
+}
+
+func TestMoneyJSON(t *testing.T) {
+	data, err := json.Marshal(NewMoney(123456, "NGN"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"1234.56","currency":"NGN"}`, string(data))
+
+	var m Money
+	assert.NoError(t, json.Unmarshal(data, &m))
+	assert.Equal(t, NewMoney(123456, "NGN"), m)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"amount":1234.56,"currency":"NGN"}`), &m))
+}
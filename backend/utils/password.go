@@ -1,10 +1,16 @@
 package utils
 
 import (
+	"context"
+	"sync"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
-func GenerateHashPassword(password string) (string, error) {
+func GenerateHashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracer().Start(ctx, "utils.GenerateHashPassword")
+	defer span.End()
+
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return "", err
@@ -13,6 +19,21 @@ func GenerateHashPassword(password string) (string, error) {
 	return string(hash), nil
 }
 
-func VerifyPassword(password, hashedPassword string) error {
+func VerifyPassword(ctx context.Context, password, hashedPassword string) error {
+	_, span := tracer().Start(ctx, "utils.VerifyPassword")
+	defer span.End()
+
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+}
+
+var dummyHash = sync.OnceValue(func() string {
+	hash, _ := GenerateHashPassword(context.Background(), "dummy password")
+	return hash
+})
+
+// VerifyNoPassword takes as long as VerifyPassword, for when there is no
+// user to check the password of. Answering faster would tell that the user
+// doesn't exist.
+func VerifyNoPassword(ctx context.Context, password string) {
+	VerifyPassword(ctx, password, dummyHash())
 }
\ No newline at end of file
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var ErrSealed = errors.New("sealed value cannot be opened")
+
+// Sealer encrypts secrets the server has to read back, such as TOTP
+// secrets, before they are stored. It uses AES-256-GCM.
+type Sealer struct {
+	aead cipher.AEAD
+}
+
+// NewSealer takes a 32 byte key in hex.
+func NewSealer(hexKey string) (*Sealer, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes in hex")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sealer{aead: aead}, nil
+}
+
+// Seal returns plaintext encrypted under a random nonce, in base64.
+func (s *Sealer) Seal(plaintext string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := s.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open reverses Seal. It fails if the value was sealed with another key or
+// changed since.
+func (s *Sealer) Open(sealed string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(b) < s.aead.NonceSize() {
+		return "", ErrSealed
+	}
+
+	nonce, ciphertext := b[:s.aead.NonceSize()], b[s.aead.NonceSize():]
+
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrSealed
+	}
+
+	return string(plaintext), nil
+}
@@ -1,37 +1,140 @@
 package utils
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/golang-jwt/jwt"
+	"go.opentelemetry.io/otel/codes"
 )
 
 type JWTToken struct {
 	config *Config
+	logger *slog.Logger
+	// keys holds every asymmetric key tokens may be verified with, by kid.
+	keys map[string]*signingKey
+	// active signs new tokens. When it is nil tokens are signed with the
+	// shared HS256 Signing_key instead.
+	active *signingKey
+	// legacyUntil is when kid-less HS256 tokens stop being accepted
+	// alongside the active key. Zero means they never are.
+	legacyUntil time.Time
 }
 
 type jwtClaim struct {
 	jwt.StandardClaims
 	UserID int64 `json:"user_id"`
+	SessionID int64 `json:"session_id"`
+	Roles []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
 	Exp int64 `json:"exp"`
 }
 
-func NewJWTToken(config *Config) *JWTToken {
-	return &JWTToken{config: config}
+// TokenPayload is what a verified access token tells us about the caller.
+// Roles and permissions are those the user held when the token was issued.
+type TokenPayload struct {
+	UserID      int64
+	SessionID   int64
+	Roles       []string
+	Permissions []string
 }
 
-func (j * JWTToken) CreateToken(userID int64) (string, error) {
+// Can reports whether the token grants permission.
+func (p *TokenPayload) Can(permission string) bool {
+	for _, granted := range p.Permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func NewJWTToken(config *Config, logger *slog.Logger) (*JWTToken, error) {
+	j := &JWTToken{config: config, logger: logger, keys: map[string]*signingKey{}}
+
+	if config.Jwt_keys_dir == "" {
+		if config.Signing_key == "" {
+			return nil, fmt.Errorf("SIGNING_KEY or JWT_KEYS_DIR must be set")
+		}
+		return j, nil
+	}
+
+	keys, err := loadKeyDir(config.Jwt_keys_dir)
+	if err != nil {
+		return nil, err
+	}
+	j.keys = keys
+
+	active, ok := keys[config.Jwt_active_kid]
+	if !ok {
+		return nil, fmt.Errorf("JWT_ACTIVE_KID %q has no key in %s", config.Jwt_active_kid, config.Jwt_keys_dir)
+	}
+
+	if active.private == nil {
+		return nil, fmt.Errorf("JWT_ACTIVE_KID %q is a public key and cannot sign", config.Jwt_active_kid)
+	}
+	j.active = active
+
+	if config.Jwt_accept_legacy_hs256 {
+		until, err := legacyCutoff(config)
+		if err != nil {
+			return nil, err
+		}
+		j.legacyUntil = until
+	}
+
+	return j, nil
+}
+
+// legacyCutoff checks the window in which HS256 tokens issued before the
+// switch to asymmetric keys are still accepted. It can end no later than
+// one access token TTL from now, when the last of them has expired.
+func legacyCutoff(config *Config) (time.Time, error) {
+	if config.Signing_key == "" {
+		return time.Time{}, fmt.Errorf("JWT_ACCEPT_LEGACY_HS256 needs SIGNING_KEY")
+	}
+
+	until, err := time.Parse(time.RFC3339, config.Jwt_legacy_hs256_cutoff)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("JWT_LEGACY_HS256_CUTOFF must be an RFC 3339 time: %w", err)
+	}
+
+	if until.After(time.Now().Add(config.Access_token_ttl)) {
+		return time.Time{}, fmt.Errorf("JWT_LEGACY_HS256_CUTOFF %s is more than ACCESS_TOKEN_TTL away", config.Jwt_legacy_hs256_cutoff)
+	}
+
+	return until, nil
+}
+
+func (j * JWTToken) CreateToken(payload TokenPayload) (string, error) {
 	claims := jwtClaim{
-		UserID: userID,
-		Exp: time.Now().Add(time.Minute * 30).Unix(),
+		UserID: payload.UserID,
+		SessionID: payload.SessionID,
+		Roles: payload.Roles,
+		Permissions: payload.Permissions,
+		Exp: time.Now().Add(j.config.Access_token_ttl).Unix(),
 		// Exp: time.Now().Add(time.Hour * 24).Unix(),
 		// Exp: time.Now().Add(time.Second * 60).Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	var tokenString string
+	var err error
+
+	if j.active != nil {
+		token := jwt.NewWithClaims(j.active.method, claims)
+		token.Header["kid"] = j.active.kid
+		tokenString, err = token.SignedString(j.active.private)
+	} else {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		tokenString, err = token.SignedString([]byte(j.config.Signing_key))
+	}
 
-	tokenString, err := token.SignedString([]byte(j.config.Signing_key))
 	if err != nil {
 		return "", err
 	}
@@ -39,38 +142,95 @@ func (j * JWTToken) CreateToken(userID int64) (string, error) {
 	return string(tokenString), nil
 }
 
-func (j *JWTToken) VerifyToken(tokenString string) (int64, error) {
-	fmt.Printf("Received token: %s\n", tokenString)
-	
+// VerifyToken checks the signature and expiry of an access token and
+// returns its payload.
+func (j *JWTToken) VerifyToken(ctx context.Context, tokenString string) (*TokenPayload, error) {
+	_, span := tracer().Start(ctx, "utils.VerifyToken")
+	defer span.End()
+
+	payload, err := j.verifyToken(tokenString)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return payload, err
+}
+
+func (j *JWTToken) verifyToken(tokenString string) (*TokenPayload, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &jwtClaim{}, func(t *jwt.Token) (interface{}, error) {
+		// The kid picks the key and the key fixes the algorithm, so a token
+		// can never get a public key used as an HMAC secret.
+		if kid, ok := t.Header["kid"].(string); ok {
+			key, ok := j.keys[kid]
+			if !ok || key.method.Alg() != t.Method.Alg() {
+				j.logger.Debug("token rejected", "reason", "unknown kid or algorithm", "kid", kid, "alg", t.Method.Alg())
+				return nil, fmt.Errorf("invalid token")
+			}
+			return key.public, nil
+		}
+
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			fmt.Printf("Invalid signing method: %v\n", t.Method)
+			j.logger.Debug("token rejected", "reason", "unexpected signing method", "alg", t.Method.Alg())
+			return nil, fmt.Errorf("invalid token")
+		}
+
+		// Once an asymmetric key signs, tokens without a kid can only be
+		// HS256 tokens from before the switch. They are accepted until the
+		// legacy cutoff, if one was opted into, and refused otherwise.
+		if j.active != nil && !time.Now().Before(j.legacyUntil) {
+			j.logger.Debug("token rejected", "reason", "legacy HS256 token not accepted")
 			return nil, fmt.Errorf("invalid token")
 		}
-		fmt.Printf("Using signing key: %s\n", j.config.Signing_key)
 		return []byte(j.config.Signing_key), nil
 	})
 
 	if err != nil {
-		fmt.Printf("Token parsing error: %v\n", err)
-		return 0, err
+		j.logger.Debug("token rejected", "reason", err)
+		return nil, err
 	}
 
 	claims, ok := token.Claims.(*jwtClaim)
-	if !ok {
-		fmt.Println("Failed to parse claims")
-		return 0, fmt.Errorf("invalid token")
-	}
-	if !token.Valid {
-		fmt.Println("Token is invalid")
-		return 0, fmt.Errorf("invalid token")
+	if !ok || !token.Valid {
+		j.logger.Debug("token rejected", "reason", "invalid claims")
+		return nil, fmt.Errorf("invalid token")
 	}
 
 	if claims.Exp < time.Now().Unix() {
-		fmt.Printf("Token expired at %v, current time: %v\n", claims.Exp, time.Now().Unix())
-		return 0, fmt.Errorf("token expired")
+		j.logger.Debug("token rejected", "reason", "expired", "user_id", claims.UserID, "expired_at", time.Unix(claims.Exp, 0))
+		return nil, fmt.Errorf("token expired")
+	}
+
+	return &TokenPayload{
+		UserID:      claims.UserID,
+		SessionID:   claims.SessionID,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+	}, nil
+}
+
+// NewOpaqueToken returns a random token together with the hash that is
+// stored in place of the token itself, for refresh tokens and emailed links.
+func NewOpaqueToken() (token string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
 	}
 
-	fmt.Printf("Token verified successfully for user ID: %v\n", claims.UserID)
-	return claims.UserID, nil
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashOpaqueToken(token), nil
+}
+
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewRefreshToken returns an opaque random refresh token together with the
+// hash that is stored in place of the token itself.
+func NewRefreshToken() (token string, hash string, err error) {
+	return NewOpaqueToken()
+}
+
+func HashRefreshToken(token string) string {
+	return HashOpaqueToken(token)
 }
\ No newline at end of file
@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePrivateKey(t *testing.T, dir, kid string, key interface{}) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0600))
+}
+
+func writePublicKey(t *testing.T, dir, kid string, key interface{}) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	data := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0644))
+}
+
+func testConfig(dir, kid string) *Config {
+	return &Config{
+		Signing_key:      "123456789012345678901234567890",
+		Jwt_keys_dir:     dir,
+		Jwt_active_kid:   kid,
+		Access_token_ttl: time.Minute,
+	}
+}
+
+func TestAsymmetricTokenRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	_, oldKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	writePrivateKey(t, dir, "2025-01", oldKey)
+
+	before, err := NewJWTToken(testConfig(dir, "2025-01"), slog.Default())
+	require.NoError(t, err)
+
+	oldToken, err := before.CreateToken(TokenPayload{UserID: 1, SessionID: 10})
+	require.NoError(t, err)
+
+	// Rotate: a new RSA key signs, the old key is kept for verification only.
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	writePrivateKey(t, dir, "2025-02", newKey)
+	writePublicKey(t, dir, "2025-01", oldKey.Public())
+
+	after, err := NewJWTToken(testConfig(dir, "2025-02"), slog.Default())
+	require.NoError(t, err)
+
+	payload, err := after.VerifyToken(context.Background(), oldToken)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), payload.UserID)
+	assert.Equal(t, int64(10), payload.SessionID)
+
+	newToken, err := after.CreateToken(TokenPayload{
+		UserID:      2,
+		SessionID:   20,
+		Roles:       []string{"support"},
+		Permissions: []string{"accounts.read", "users.read"},
+	})
+	require.NoError(t, err)
+
+	payload, err = after.VerifyToken(context.Background(), newToken)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), payload.UserID)
+	assert.Equal(t, []string{"support"}, payload.Roles)
+	assert.True(t, payload.Can("users.read"))
+	assert.False(t, payload.Can("roles.manage"))
+
+	// The instance that never saw the new key cannot verify its tokens.
+	_, err = before.VerifyToken(context.Background(), newToken)
+	assert.Error(t, err)
+
+	jwks := after.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "OKP", jwks.Keys[0].Kty)
+	assert.Equal(t, "EdDSA", jwks.Keys[0].Alg)
+	assert.Equal(t, "RSA", jwks.Keys[1].Kty)
+	assert.Equal(t, "RS256", jwks.Keys[1].Alg)
+	assert.Equal(t, "AQAB", jwks.Keys[1].E)
+}
+
+func TestPublicKeyCannotBeActive(t *testing.T) {
+	dir := t.TempDir()
+
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	writePublicKey(t, dir, "retired", public)
+
+	_, err = NewJWTToken(testConfig(dir, "retired"), slog.Default())
+	assert.Error(t, err)
+
+	_, err = NewJWTToken(testConfig(dir, "missing"), slog.Default())
+	assert.Error(t, err)
+}
+
+func TestLegacyHMACToken(t *testing.T) {
+	legacy, err := NewJWTToken(testConfig("", ""), slog.Default())
+	require.NoError(t, err)
+
+	token, err := legacy.CreateToken(TokenPayload{UserID: 3, SessionID: 30})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	writePrivateKey(t, dir, "k1", key)
+
+	// Opted in, kid-less HS256 tokens are accepted until the cutoff.
+	config := testConfig(dir, "k1")
+	config.Jwt_accept_legacy_hs256 = true
+	config.Jwt_legacy_hs256_cutoff = time.Now().Add(30 * time.Second).Format(time.RFC3339)
+	asymmetric, err := NewJWTToken(config, slog.Default())
+	require.NoError(t, err)
+
+	payload, err := asymmetric.VerifyToken(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), payload.UserID)
+
+	// After it, they are refused.
+	config.Jwt_legacy_hs256_cutoff = time.Now().Add(-time.Second).Format(time.RFC3339)
+	expired, err := NewJWTToken(config, slog.Default())
+	require.NoError(t, err)
+
+	_, err = expired.VerifyToken(context.Background(), token)
+	assert.Error(t, err)
+
+	// The cutoff can't outlast the tokens it is for.
+	config.Jwt_legacy_hs256_cutoff = time.Now().Add(time.Hour).Format(time.RFC3339)
+	_, err = NewJWTToken(config, slog.Default())
+	assert.Error(t, err)
+
+	config.Jwt_legacy_hs256_cutoff = ""
+	_, err = NewJWTToken(config, slog.Default())
+	assert.Error(t, err)
+}
+
+func TestForgedHMACTokenRejected(t *testing.T) {
+	dir := t.TempDir()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	writePrivateKey(t, dir, "k1", key)
+
+	// SIGNING_KEY is still set, as it was before the rotation, but the
+	// legacy fallback was not opted into.
+	config := testConfig(dir, "k1")
+	verifier, err := NewJWTToken(config, slog.Default())
+	require.NoError(t, err)
+
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwtClaim{
+		UserID:      1,
+		SessionID:   1,
+		Roles:       []string{"admin"},
+		Permissions: []string{"roles.manage", "users.read"},
+		Exp:         time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte(config.Signing_key))
+	require.NoError(t, err)
+
+	_, err = verifier.VerifyToken(context.Background(), forged)
+	assert.Error(t, err)
+}
+
+func TestSigningKeyRequired(t *testing.T) {
+	config := testConfig("", "")
+	config.Signing_key = ""
+
+	_, err := NewJWTToken(config, slog.Default())
+	assert.Error(t, err)
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The SHA1 test vectors of RFC 6238, cut to 6 digits.
+func TestTOTPCode(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	testCases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tc := range testCases {
+		code, err := TOTPCode(secret, TOTPStep(time.Unix(tc.unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, tc.code, code, "at %d", tc.unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	require.NoError(t, err)
+
+	now := time.Now()
+	step := TOTPStep(now)
+
+	code, err := TOTPCode(secret, step)
+	require.NoError(t, err)
+
+	matched, ok := ValidateTOTP(secret, code, now, 0)
+	require.True(t, ok)
+	assert.Equal(t, step, matched)
+
+	// A code is accepted one step late, not two.
+	_, ok = ValidateTOTP(secret, code, now.Add(30*time.Second), 0)
+	assert.True(t, ok)
+	_, ok = ValidateTOTP(secret, code, now.Add(time.Minute+time.Second), 0)
+	assert.False(t, ok)
+
+	// A used step can't be replayed.
+	_, ok = ValidateTOTP(secret, code, now, step)
+	assert.False(t, ok)
+
+	_, ok = ValidateTOTP(secret, "12345", now, 0)
+	assert.False(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("Kasho", "ada@example.com", "JBSWY3DPEHPK3PXP"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Kasho:ada@example.com", uri.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", uri.Query().Get("secret"))
+	assert.Equal(t, "Kasho", uri.Query().Get("issuer"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := NewRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+	require.Len(t, hashes, 10)
+
+	assert.Regexp(t, `^[a-z2-9]{5}-[a-z2-9]{5}$`, codes[0])
+	assert.NotEqual(t, codes[0], codes[1])
+
+	// Codes may be typed in any case, with or without the dash.
+	typed := codes[0][:5] + codes[0][6:]
+	assert.Equal(t, hashes[0], HashRecoveryCode(typed))
+	assert.Equal(t, hashes[0], HashRecoveryCode(" "+strings.ToUpper(codes[0])))
+}
+
+func TestSealer(t *testing.T) {
+	sealer, err := NewSealer("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	require.NoError(t, err)
+
+	sealed, err := sealer.Seal("JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	assert.NotContains(t, sealed, "JBSWY3DPEHPK3PXP")
+
+	opened, err := sealer.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", opened)
+
+	other, err := NewSealer("1f1e1d1c1b1a191817161514131211100f0e0d0c0b0a09080706050403020100")
+	require.NoError(t, err)
+	_, err = other.Open(sealed)
+	assert.ErrorIs(t, err, ErrSealed)
+
+	_, err = NewSealer("too short")
+	assert.Error(t, err)
+}
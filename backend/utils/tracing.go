@@ -0,0 +1,12 @@
+package utils
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer is looked up on each use, so spans go to whichever provider the
+// server or a test installed last.
+func tracer() trace.Tracer {
+	return otel.Tracer("github/kasho/backend/utils")
+}
@@ -1,37 +1,24 @@
 package utils
 
 import (
-	"fmt"
-	"net/http"
+	"errors"
 
 	"github.com/gin-gonic/gin"
 )
 
-var Currencies = map[string]string {
-	"USD": "USD",
-	"NGN": "NGN",
-	"ZAR": "ZAR",
-}
+var ErrUnauthenticated = errors.New("no authenticated user")
 
-func IsSupportedCurrency(currency string) bool {
-	if _, ok := Currencies[currency]; ok {
-		return true
-	}
-	return false
-}
-	
+// GetActiveUser returns the id AuthenticatedMiddleware stored on the request.
 func GetActiveUser(c *gin.Context) (int64, error) {
-	value, exists := c.Get("user_id")	
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
-		return 0, fmt.Errorf("Error occured")
+	userId, ok := c.Get("user_id")
+	if !ok {
+		return 0, ErrUnauthenticated
 	}
 
-	userId, ok := value.(int64)
+	id, ok := userId.(int64)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to get user id"})
-		return 0, fmt.Errorf("Error occured")
+		return 0, ErrUnauthenticated
 	}
 
-	return userId, nil
+	return id, nil
 }
@@ -1,8 +1,17 @@
 import { AxiosError } from "axios";
 import { toast } from "react-toastify";
 
+type ApiError = {
+    error?: {
+        code: string;
+        message: string;
+        request_id?: string;
+    };
+};
+
 export const errorHandler = (e: AxiosError) => {
-    toast(JSON.stringify(e.response?.data) || e.message, {
+    const data = e.response?.data as ApiError | undefined;
+    toast(data?.error?.message || e.message, {
         type: "error",
     })
 }
\ No newline at end of file
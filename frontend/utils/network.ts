@@ -3,6 +3,8 @@ const baseUrl = "http://localhost:3000";
 const authUrl = {
     register: `${baseUrl}/auth/register`,
     login: `${baseUrl}/auth/login`,
+    refresh: `${baseUrl}/auth/refresh`,
+    logout: `${baseUrl}/auth/logout`,
 }
 
 export { authUrl };
\ No newline at end of file